@@ -21,7 +21,7 @@ import (
 	"fmt"
 	"io"
 	"mysqld/stable"
-	"os"
+	"sort"
 	"strings"
 	"text/tabwriter"
 	"text/wrapper"
@@ -50,6 +50,17 @@ type Command struct {
 	SkipStable         bool
 	Flags              *flag.FlagSet
 
+	// ReadOnly is set for commands that do not change the stable
+	// or the servers in it. Such commands are not recorded in the
+	// audit log.
+	ReadOnly bool
+
+	// SideEffects is set for commands that have effects that are
+	// not reproducible from the stable alone, such as executing
+	// SQL statements on the servers. Such commands are not
+	// replayed from the audit log unless explicitly requested.
+	SideEffects bool
+
 	path []string
 }
 
@@ -58,17 +69,26 @@ type Command struct {
 // words have been removed.
 //
 // When executing the command, the stable in the stable root directory
-// will be opened automatically, unless the skipStable flag is set.
+// will be opened and locked automatically, unless the skipStable flag
+// is set. The lock is held until the configuration has been written
+// back, so that concurrent invocations do not lose updates.
 func (cmd *Command) Run(ctx *Context, args []string) error {
 	// Try to open the stable. It is OK if it cannot be opened
 	// since some commands do not need it to be open.
 	if !cmd.SkipStable {
-		stable, err := stable.OpenStable(ctx.RootDir)
+		stbl, err := stable.OpenStable(ctx.RootDir)
 		if err != nil {
 			return err
 		}
 
-		ctx.Stable = stable
+		// Re-read the configuration once the lock is held since
+		// another process might have changed it while waiting.
+		if err := stbl.Lock(stable.LOCK_TIMEOUT); err != nil {
+			return err
+		}
+		defer stbl.Unlock()
+
+		ctx.Stable = stbl
 		err = ctx.Stable.ReadConfig()
 		if err != nil {
 			return err
@@ -77,13 +97,17 @@ func (cmd *Command) Run(ctx *Context, args []string) error {
 
 	// This execute the main body of the command with the context
 	// set up properly. In case of an error, we do not write back
-	// the configuration and instead just return.
+	// the configuration and instead just return. The flags are
+	// created anew, so that flags given to an earlier run of the
+	// command, for example when replaying, are not used again.
+	cmd.initFlags()
 	if err := cmd.Flags.Parse(args); err != nil {
 		return err
 	}
 
 	err := cmd.Body(ctx, cmd, cmd.Flags.Args())
 	if err != nil {
+		cmd.audit(ctx, args, err)
 		return err
 	}
 
@@ -96,7 +120,61 @@ func (cmd *Command) Run(ctx *Context, args []string) error {
 			return err
 		}
 	}
-	return nil
+	return cmd.audit(ctx, args, nil)
+}
+
+// audit will record the command in the audit log of the stable, if
+// the command can change the stable. Passwords given as flags are not
+// recorded, see redactArgs.
+func (cmd *Command) audit(ctx *Context, args []string, result error) error {
+	if cmd.SkipStable || cmd.ReadOnly || ctx.Stable == nil {
+		return nil
+	}
+	return ctx.Stable.Audit(cmd.path, cmd.redactArgs(args), result)
+}
+
+// REDACTED is recorded in the audit log instead of passwords.
+const REDACTED = "********"
+
+// redactArgs will return a copy of the arguments where the values of
+// flags with "password" in the name are replaced with REDACTED. The
+// arguments are scanned the same way as the flag package parse them,
+// so arguments after the flags are not changed. Note that a command
+// replayed from the audit log gets REDACTED as the password.
+func (cmd *Command) redactArgs(args []string) []string {
+	result := append([]string{}, args...)
+	for i := 0; i < len(result); i++ {
+		arg := result[i]
+		if len(arg) < 2 || arg[0] != '-' || arg == "--" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		eq := strings.IndexByte(name, '=')
+		if eq >= 0 {
+			name = name[:eq]
+		}
+		flg := cmd.Flags.Lookup(name)
+		if flg == nil {
+			break
+		}
+		secret := strings.Contains(name, "password")
+		if eq >= 0 {
+			if secret {
+				result[i] = arg[:strings.IndexByte(arg, '=')+1] + REDACTED
+			}
+			continue
+		}
+		if bf, ok := flg.Value.(interface{ IsBoolFlag() bool }); ok && bf.IsBoolFlag() {
+			continue
+		}
+		if i+1 < len(result) {
+			i++
+			if secret {
+				result[i] = REDACTED
+			}
+		}
+	}
+	return result
 }
 
 func (cmd *Command) setup(path []string) {
@@ -104,7 +182,12 @@ func (cmd *Command) setup(path []string) {
 	cmd.path = make([]string, len(path))
 	copy(cmd.path, path)
 
-	// Create a new flag set for the command options
+	cmd.initFlags()
+}
+
+// initFlags will create a new flag set for the command options with
+// all flags set to their defaults.
+func (cmd *Command) initFlags() {
 	cmd.Flags = flag.NewFlagSet("Options", 0)
 
 	// Call the init function, if it was defined.
@@ -141,7 +224,7 @@ func (cmd *Command) PrintHelp(w io.Writer) {
 
 	// Options
 	hdrPrint := false
-	tw := tabwriter.NewWriter(os.Stdout, 8, 0, 2, ' ', tabwriter.AlignRight)
+	tw := tabwriter.NewWriter(w, 8, 0, 2, ' ', tabwriter.AlignRight)
 	cmd.Flags.VisitAll(func(flag *flag.Flag) {
 		if !hdrPrint {
 			fmt.Fprintf(w, "\nOptions:\n")
@@ -179,24 +262,36 @@ func (grp *Group) Locate(args []string) (*Command, Node, []string) {
 		return nil, grp, args
 	}
 
-	// Collect the candidates for matching
-	candidates := []Node{}
-	for key, reg := range grp.subgroup {
-		if strings.HasPrefix(key, args[0]) {
-			candidates = append(candidates, reg)
-		}
-	}
-
 	// If there is more than one candidate, the choice is
 	// ambiguous and we cannot pick one.
+	candidates := grp.Candidates(args[0])
 	if len(candidates) != 1 {
 		return nil, grp, args
 	}
 
-	c, i, as := candidates[0].Locate(args[1:])
+	c, i, as := grp.subgroup[candidates[0]].Locate(args[1:])
 	return c, i, as
 }
 
+// Candidates will return the names of the commands and groups in the
+// group that the word is a prefix of, sorted by name. If the word is
+// the complete name of a command or group, only that name is
+// returned.
+func (grp *Group) Candidates(word string) []string {
+	if _, ok := grp.subgroup[word]; ok {
+		return []string{word}
+	}
+
+	candidates := []string{}
+	for key := range grp.subgroup {
+		if strings.HasPrefix(key, word) {
+			candidates = append(candidates, key)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
 // Register will allow a command or group to be registered. If it
 // cannot be registered under the provided words, an error will be
 // returned.
@@ -247,3 +342,24 @@ func (grp *Group) PrintHelp(w io.Writer) {
 func (grp *Group) Summary() string {
 	return grp.Brief
 }
+
+// walk will call the function for the group and then recursively for
+// all the nodes in the group, in order of their names.
+func (grp *Group) walk(fn func(path []string, node Node)) {
+	fn(grp.path, grp)
+
+	keys := make([]string, 0, len(grp.subgroup))
+	for key := range grp.subgroup {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		switch node := grp.subgroup[key].(type) {
+		case *Group:
+			node.walk(fn)
+		case *Command:
+			fn(node.path, node)
+		}
+	}
+}
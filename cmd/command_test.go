@@ -17,6 +17,11 @@
 package cmd
 
 import (
+	"io/ioutil"
+	"mysqld/stable"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -176,6 +181,51 @@ func TestCompletion(t *testing.T) {
 	checkCommand(t, tree, args3, args3[1:], nil)
 }
 
+func TestAmbiguous(t *testing.T) {
+	ctx := NewContext("Just a test", "")
+	ctx.RegisterGroup([]string{"server"}, &Group{Brief: "A group"})
+	for _, name := range []string{"show", "start", "status", "stop", "list", "listall"} {
+		ctx.RegisterCommand([]string{"server", name}, &Command{
+			Brief: "A command",
+			Body:  func(*Context, *Command, []string) error { return nil },
+		})
+	}
+
+	// An abbreviation matching several commands should report
+	// the candidates.
+	err := ctx.RunCommand([]string{"server", "s", "master"})
+	if err == nil {
+		t.Fatalf("Expected an error, got none")
+	}
+	if amb, ok := err.Err.(*AmbiguousError); !ok {
+		t.Errorf("Expected ambiguous error, got %v", err.Err)
+	} else {
+		compareSlices(t, amb.Candidates, []string{"show", "start", "status", "stop"})
+	}
+	expect := `Ambiguous command "s": show, start, status, stop`
+	if err.Error() != expect {
+		t.Errorf("Expected error %q, got %q", expect, err.Error())
+	}
+
+	// A word that does not match anything is not ambiguous
+	err = ctx.RunCommand([]string{"server", "x"})
+	if err == nil {
+		t.Fatalf("Expected an error, got none")
+	}
+	if _, ok := err.Err.(*AmbiguousError); ok {
+		t.Errorf("Expected command not found, got %v", err.Err)
+	}
+
+	// A complete name is never ambiguous, even if it is a prefix
+	// of another name.
+	args := []string{"server", "list", "master"}
+	c, _, as := ctx.Locate(args)
+	if c == nil || c.path[1] != "list" {
+		t.Errorf("Expected command 'server list', got %v", c)
+	}
+	compareSlices(t, as, args[2:])
+}
+
 func TestBasic(t *testing.T) {
 	tree := &Group{
 		Brief:    "Just a test",
@@ -216,3 +266,96 @@ func TestBasic(t *testing.T) {
 		t.Errorf("Expected %v, got %v", args3, as)
 	}
 }
+
+// auditEntries will return the number of entries in the audit log of
+// the stable.
+func auditEntries(t *testing.T, stbl *stable.Stable) int {
+	content, err := ioutil.ReadFile(filepath.Join(stbl.Root, stable.AUDIT_FILE))
+	if os.IsNotExist(err) {
+		return 0
+	} else if err != nil {
+		t.Fatalf("Unable to read audit log: %s", err)
+	}
+	return strings.Count(string(content), "\n")
+}
+
+func TestAudit(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	stbl, err := stable.CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+	stbl.Auditing = true
+	if err := stbl.WriteConfig(); err != nil {
+		t.Fatalf("Unable to write configuration: %s", err)
+	}
+
+	ctx := NewContext("Just a test", "")
+	ctx.RootDir = root
+	ctx.RegisterCommand([]string{"change"}, &Command{
+		Brief: "A mutating command",
+		Body:  func(*Context, *Command, []string) error { return nil },
+	})
+	ctx.RegisterCommand([]string{"show"}, &Command{
+		Brief:    "A read-only command",
+		ReadOnly: true,
+		Body:     func(*Context, *Command, []string) error { return nil },
+	})
+
+	if err := ctx.RunCommand([]string{"change", "one", "two"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := stbl.Lock(0); err != nil {
+		t.Errorf("Stable still locked after command: %s", err)
+	}
+	stbl.Unlock()
+	if count := auditEntries(t, stbl); count != 1 {
+		t.Errorf("Expected 1 audit entry, got %d", count)
+	}
+	if info, err := os.Stat(filepath.Join(stbl.Root, stable.AUDIT_FILE)); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected audit log only readable by owner, got %v (error %v)", info.Mode(), err)
+	}
+
+	if err := ctx.RunCommand([]string{"show"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if count := auditEntries(t, stbl); count != 1 {
+		t.Errorf("Expected 1 audit entry after read-only command, got %d", count)
+	}
+
+	// Turn off auditing and check that nothing is written
+	stbl.Auditing = false
+	stbl.WriteConfig()
+	if err := ctx.RunCommand([]string{"change"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if count := auditEntries(t, stbl); count != 1 {
+		t.Errorf("Expected 1 audit entry with auditing disabled, got %d", count)
+	}
+}
+
+func TestRedactArgs(t *testing.T) {
+	cmd := &Command{}
+	cmd.Init = func(cmd *Command) {
+		cmd.Flags.String("password", "", "Password")
+		cmd.Flags.String("user", "", "User")
+		cmd.Flags.Bool("force", false, "Force")
+	}
+	cmd.setup([]string{"test"})
+
+	tests := []struct{ args, expect []string }{
+		{[]string{"-password=secret", "x"}, []string{"-password=" + REDACTED, "x"}},
+		{[]string{"--password", "secret", "-user", "root", "x"}, []string{"--password", REDACTED, "-user", "root", "x"}},
+		{[]string{"-force", "-password", "secret"}, []string{"-force", "-password", REDACTED}},
+		{[]string{"x", "-password", "secret"}, []string{"x", "-password", "secret"}},
+		{[]string{"--", "-password", "secret"}, []string{"--", "-password", "secret"}},
+	}
+	for _, test := range tests {
+		compareSlices(t, cmd.redactArgs(test.args), test.expect)
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Shells supported when writing completion scripts.
+const (
+	SHELL_BASH = "bash"
+	SHELL_ZSH  = "zsh"
+)
+
+// completionNode hold what can be completed after the words of a
+// group or command in the completion script.
+type completionNode struct {
+	words, options []string
+	servers        bool
+}
+
+var funcNameRegex = regexp.MustCompile(`\W`)
+
+// WriteCompletion will write a completion script for all groups and
+// commands in the command tree to the writer, for the given shell,
+// which can be either SHELL_BASH or SHELL_ZSH. The name is the name
+// of the program and globals are the options accepted before the
+// command words, if any.
+//
+// The script complete the names of the groups and commands at each
+// level of the tree and the options of each command. For commands in
+// the "server" group, the names of the servers in the stable are
+// completed as well, by running the "server fmt" command of the
+// program. If the globals have a "root" option, the root given on the
+// command line being completed is passed on to that command.
+func (ctx *Context) WriteCompletion(w io.Writer, name, shell string, globals *flag.FlagSet) error {
+	switch shell {
+	case SHELL_BASH:
+	case SHELL_ZSH:
+		// The bash completion functions can be used by zsh
+		// once the bash compatibility layer is loaded.
+		fmt.Fprintf(w, "autoload -U +X bashcompinit && bashcompinit\n")
+	default:
+		return fmt.Errorf("Unknown shell %q", shell)
+	}
+
+	nodes := map[string]*completionNode{}
+	paths := []string{}
+	ctx.Walk(func(path []string, node Node) {
+		key := strings.Join(path, " ")
+		entry := &completionNode{}
+		switch node := node.(type) {
+		case *Group:
+			for word := range node.subgroup {
+				entry.words = append(entry.words, word)
+			}
+			sort.Strings(entry.words)
+			if len(path) == 0 && globals != nil {
+				entry.options = flagNames(globals)
+			}
+		case *Command:
+			entry.options = flagNames(node.Flags)
+			entry.servers = len(path) > 0 && path[0] == "server"
+		}
+		nodes[key] = entry
+		paths = append(paths, key)
+	})
+
+	fn := "_" + funcNameRegex.ReplaceAllString(name, "_")
+	fmt.Fprintf(w, "%s_servers() {\n", fn)
+	fmt.Fprintf(w, "    %s ${1:+-root \"$1\"} server fmt '{Name}' '*' 2>/dev/null\n", name)
+	fmt.Fprintf(w, "}\n\n")
+
+	fmt.Fprintf(w, "%s() {\n", fn)
+	fmt.Fprintf(w, "    local cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	fmt.Fprintf(w, "    local path=\"\" word words=\"\" options=\"\" servers=\"\" root=\"\" i\n\n")
+
+	// Find the deepest group or command given by the words
+	// before the current one, skipping options but remembering
+	// the root given before the first word. Bash split "-root=DIR"
+	// into three words, so an "=" after the option is skipped.
+	fmt.Fprintf(w, "    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	fmt.Fprintf(w, "        word=\"${COMP_WORDS[i]}\"\n")
+	if globals != nil && globals.Lookup("root") != nil {
+		fmt.Fprintf(w, "        if [[ -z \"$path\" ]]; then\n")
+		fmt.Fprintf(w, "            case \"$word\" in\n")
+		fmt.Fprintf(w, "            -root|--root)\n")
+		fmt.Fprintf(w, "                i=$((i + 1))\n")
+		fmt.Fprintf(w, "                [[ \"${COMP_WORDS[i]}\" == \"=\" ]] && i=$((i + 1))\n")
+		fmt.Fprintf(w, "                root=\"${COMP_WORDS[i]}\"\n")
+		fmt.Fprintf(w, "                continue ;;\n")
+		fmt.Fprintf(w, "            -root=*|--root=*) root=\"${word#*=}\"; continue ;;\n")
+		fmt.Fprintf(w, "            esac\n")
+		fmt.Fprintf(w, "        fi\n")
+	}
+	fmt.Fprintf(w, "        case \"$word\" in -*) continue ;; esac\n")
+	fmt.Fprintf(w, "        case \"${path:+$path }$word\" in\n")
+	for _, key := range paths {
+		if len(key) > 0 {
+			fmt.Fprintf(w, "        %q) path=\"${path:+$path }$word\" ;;\n", key)
+		}
+	}
+	fmt.Fprintf(w, "        *) break ;;\n")
+	fmt.Fprintf(w, "        esac\n")
+	fmt.Fprintf(w, "    done\n\n")
+
+	fmt.Fprintf(w, "    case \"$path\" in\n")
+	for _, key := range paths {
+		entry := nodes[key]
+		fmt.Fprintf(w, "    %q)\n", key)
+		if len(entry.words) > 0 {
+			fmt.Fprintf(w, "        words=%q\n", strings.Join(entry.words, " "))
+		}
+		if len(entry.options) > 0 {
+			fmt.Fprintf(w, "        options=%q\n", strings.Join(entry.options, " "))
+		}
+		if entry.servers {
+			fmt.Fprintf(w, "        servers=1\n")
+		}
+		fmt.Fprintf(w, "        ;;\n")
+	}
+	fmt.Fprintf(w, "    esac\n\n")
+
+	fmt.Fprintf(w, "    if [[ \"$cur\" == -* ]]; then\n")
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W \"$options\" -- \"$cur\"))\n")
+	fmt.Fprintf(w, "    elif [[ -n \"$servers\" ]]; then\n")
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W \"$(%s_servers \"$root\")\" -- \"$cur\"))\n", fn)
+	fmt.Fprintf(w, "    else\n")
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n")
+	fmt.Fprintf(w, "    fi\n")
+	fmt.Fprintf(w, "}\n\n")
+
+	fmt.Fprintf(w, "complete -F %s %s\n", fn, name)
+	return nil
+}
+
+// flagNames will return the names of all the flags in the flag set,
+// including the leading dash, sorted by name.
+func flagNames(flags *flag.FlagSet) []string {
+	names := []string{}
+	flags.VisitAll(func(flag *flag.Flag) {
+		names = append(names, "-"+flag.Name)
+	})
+	return names
+}
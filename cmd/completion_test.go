@@ -0,0 +1,130 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteCompletion(t *testing.T) {
+	ctx := NewContext("Just a test", "The root of the tree.")
+	ctx.RegisterGroup([]string{"server"}, &Group{Brief: "Server commands"})
+	ctx.RegisterCommand([]string{"server", "add"}, &Command{
+		Brief: "Add a server",
+		Init: func(cmd *Command) {
+			cmd.Flags.String("dist", "", "Distribution to use")
+			cmd.Flags.Int("count", 1, "Number of servers")
+		},
+	})
+	ctx.RegisterCommand([]string{"server", "start"}, &Command{Brief: "Start a server"})
+	ctx.RegisterCommand([]string{"help"}, &Command{Brief: "Give help"})
+
+	globals := flag.NewFlagSet("test", flag.ContinueOnError)
+	globals.String("root", ".", "Root directory")
+
+	var buf bytes.Buffer
+	if err := ctx.WriteCompletion(&buf, "my-prog", SHELL_BASH, globals); err != nil {
+		t.Fatalf("Unable to write completion: %s", err)
+	}
+	script := buf.String()
+
+	expected := []string{
+		"_my_prog_servers() {\n    my-prog ${1:+-root \"$1\"} server fmt '{Name}' '*' 2>/dev/null\n}\n",
+		"    \"\")\n        words=\"help server\"\n        options=\"-root\"\n        ;;\n",
+		"    \"server\")\n        words=\"add start\"\n        ;;\n",
+		"    \"server add\")\n        options=\"-count -dist\"\n        servers=1\n        ;;\n",
+		"    \"help\")\n        ;;\n",
+		"complete -F _my_prog my-prog\n",
+	}
+	for _, str := range expected {
+		if !strings.Contains(script, str) {
+			t.Errorf("Expected %q in script:\n%s", str, script)
+		}
+	}
+
+	// The script should at least be valid bash, if bash is
+	// available.
+	if path, err := exec.LookPath("bash"); err == nil {
+		check := exec.Command(path, "-n")
+		check.Stdin = strings.NewReader(script)
+		if output, err := check.CombinedOutput(); err != nil {
+			t.Errorf("Script is not valid: %s: %s", err, output)
+		}
+	}
+
+	if path, err := exec.LookPath("bash"); err == nil {
+		checkServerCompletion(t, path, script)
+	}
+
+	buf.Reset()
+	if err := ctx.WriteCompletion(&buf, "my-prog", SHELL_ZSH, nil); err != nil {
+		t.Fatalf("Unable to write completion: %s", err)
+	}
+	if !strings.HasPrefix(buf.String(), "autoload -U +X bashcompinit && bashcompinit\n") {
+		t.Errorf("Expected bash compatibility in zsh script:\n%s", buf.String())
+	}
+
+	if err := ctx.WriteCompletion(&buf, "my-prog", "fish", nil); err == nil {
+		t.Errorf("Expected error for unknown shell")
+	}
+}
+
+// checkServerCompletion will run the completion function of the
+// script for a server command, with a fake program that print server
+// names only when called with the expected arguments.
+func checkServerCompletion(t *testing.T, bash, script string) {
+	dir, err := ioutil.TempDir("", "completion")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	prog := "#!/bin/sh\n" +
+		"[ $# = 6 ] && [ \"$*\" = \"-root /tmp/my root server fmt {Name} *\" ] && echo alpha beta\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "my-prog"), []byte(prog), 0755); err != nil {
+		t.Fatalf("Unable to write program: %s", err)
+	}
+
+	tests := []struct {
+		words  string
+		expect []string
+	}{
+		{`my-prog -root "/tmp/my root" server add ""`, []string{"alpha", "beta"}},
+		{`my-prog -root = "/tmp/my root" server add b`, []string{"beta"}},
+		{`my-prog --root="/tmp/my root" server add a`, []string{"alpha"}},
+		{`my-prog server add ""`, nil},
+	}
+	for _, test := range tests {
+		run := exec.Command(bash, "-c", script+
+			"COMP_WORDS=("+test.words+"); COMP_CWORD=$((${#COMP_WORDS[@]} - 1)); _my_prog\n"+
+			"for word in \"${COMPREPLY[@]}\"; do echo \"$word\"; done\n")
+		run.Env = append(os.Environ(), "PATH="+dir+":"+os.Getenv("PATH"))
+		output, err := run.CombinedOutput()
+		if err != nil {
+			t.Errorf("Completion of %s failed: %s: %s", test.words, err, output)
+			continue
+		}
+		compareSlices(t, strings.Fields(string(output)), test.expect)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrNotConfirmed is returned when the user did not confirm that a
+// command should proceed.
+var ErrNotConfirmed = errors.New("Not confirmed, nothing done")
+
+// Confirm will write the question followed by the items, one on each
+// line, to the writer and read the answer from the reader. It return
+// true if the answer is "y" or "yes" and false for any other answer,
+// including no answer at all.
+func Confirm(r io.Reader, w io.Writer, question string, items []string) (bool, error) {
+	fmt.Fprintf(w, "%s\n", question)
+	for _, item := range items {
+		fmt.Fprintf(w, "    %s\n", item)
+	}
+	fmt.Fprintf(w, "Proceed? [y/N] ")
+
+	answer, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// Confirm will ask the user on the terminal to confirm that the
+// command should proceed with the items, which are printed before
+// asking. If the user does not confirm, ErrNotConfirmed is
+// returned. If AssumeYes is set for the context, nothing is asked and
+// the command proceed.
+func (ctx *Context) Confirm(question string, items []string) error {
+	if ctx.AssumeYes {
+		return nil
+	}
+	if ok, err := Confirm(os.Stdin, os.Stderr, question, items); err != nil {
+		return err
+	} else if !ok {
+		return ErrNotConfirmed
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestConfirm(t *testing.T) {
+	items := []string{"slave.1", "slave.2"}
+	tests := []struct {
+		answer string
+		result bool
+	}{
+		{"y\n", true},
+		{"Yes\n", true},
+		{" y \n", true},
+		{"n\n", false},
+		{"\n", false},
+		{"", false},
+		{"yeah\n", false},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		result, err := Confirm(strings.NewReader(test.answer), &out, "Remove servers?", items)
+		if err != nil {
+			t.Errorf("Answer %q: unexpected error: %s", test.answer, err)
+		} else if result != test.result {
+			t.Errorf("Answer %q: expected %v, got %v", test.answer, test.result, result)
+		}
+
+		expect := "Remove servers?\n    slave.1\n    slave.2\nProceed? [y/N] "
+		if out.String() != expect {
+			t.Errorf("Expected prompt %q, got %q", expect, out.String())
+		}
+	}
+
+	// Nothing should be asked when the context assume yes.
+	context := NewContext("Test", "Test")
+	context.AssumeYes = true
+	if err := context.Confirm("Remove servers?", items); err != nil {
+		t.Errorf("Expected confirmation, got %s", err)
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"fmt"
 	"io"
 	"mysqld/stable"
+	"os"
 	"strings"
 )
 
@@ -53,6 +54,38 @@ func (err *RunError) PrintHelp(w io.Writer) {
 	}
 }
 
+// AmbiguousError is the error when a word of a command is a prefix
+// of the names of several commands or groups, so that it is not
+// possible to decide which one was intended.
+type AmbiguousError struct {
+	Word       string
+	Candidates []string
+}
+
+func (err *AmbiguousError) Error() string {
+	return fmt.Sprintf("Ambiguous command %q: %s", err.Word, strings.Join(err.Candidates, ", "))
+}
+
+// locateError will return the error for words that do not locate a
+// command, where node and rest are what was returned from locating
+// the command. If the first word that could not be matched is
+// ambiguous, an AmbiguousError is returned.
+func locateError(words []string, node Node, rest []string) error {
+	if grp, ok := node.(*Group); ok && len(rest) > 0 {
+		if candidates := grp.Candidates(rest[0]); len(candidates) > 1 {
+			return &AmbiguousError{Word: rest[0], Candidates: candidates}
+		}
+	}
+
+	// Find the first unrecognized word, or if all words are
+	// recognized, find the last word in the list.
+	end := len(words) - len(rest)
+	if end < len(words) {
+		end++
+	}
+	return fmt.Errorf("Command not found: %q", strings.Join(words[:end], " "))
+}
+
 // Context hold the structure of commands, including such things as
 // the complete list of all commands (as a tree), the Stable they are
 // running in, the root directory, etc. Each command above receive the
@@ -62,12 +95,21 @@ type Context struct {
 	RootDir string
 	Stable  *stable.Stable
 
+	// AssumeYes is set to proceed with destructive commands
+	// without asking the user for confirmation.
+	AssumeYes bool
+
+	// Out is the writer that commands write their output to. It
+	// is standard output unless redirected.
+	Out io.Writer
+
 	tree *Group
 }
 
 // NewContext will create a new context.
 func NewContext(summary, description string) *Context {
 	context := &Context{
+		Out: os.Stdout,
 		tree: &Group{
 			Brief:       summary,
 			Description: description,
@@ -128,14 +170,7 @@ func (ctx *Context) RunCommand(words []string) *RunError {
 	// by recursively going through the command tree.
 	cmd, node, args := ctx.tree.Locate(words)
 	if cmd == nil {
-		// Find the first unrecognized word, or if all words
-		// are recognized, find the last word in the list.
-		end := len(words) - len(args)
-		if end < cap(words) {
-			end++
-		}
-		err := fmt.Errorf("Command not found: %q", strings.Join(words[:end], " "))
-		return &RunError{Err: err, Where: node}
+		return &RunError{Err: locateError(words, node, args), Where: node}
 	}
 
 	if err := cmd.Run(ctx, args); err != nil {
@@ -147,3 +182,78 @@ func (ctx *Context) RunCommand(words []string) *RunError {
 func (ctx *Context) PrintHelp(w io.Writer) {
 	ctx.tree.PrintHelp(w)
 }
+
+// Replay will re-execute the commands recorded in the audit log
+// entries against the stable in the root directory of the
+// context. Entries for commands that failed are skipped, as are
+// commands with side effects unless sideEffects is true. The number
+// of replayed commands is returned.
+func (ctx *Context) Replay(entries []stable.AuditEntry, sideEffects bool) (int, error) {
+	// The commands were already confirmed when they were first
+	// executed, so do not ask again.
+	saved := ctx.AssumeYes
+	ctx.AssumeYes = true
+	defer func() { ctx.AssumeYes = saved }()
+
+	count := 0
+	for _, entry := range entries {
+		if len(entry.Error) > 0 {
+			continue
+		}
+
+		cmd, _, rest := ctx.tree.Locate(entry.Command)
+		if cmd == nil || len(rest) > 0 {
+			return count, fmt.Errorf("Command not found: %q", strings.Join(entry.Command, " "))
+		}
+		if cmd.SkipStable || (cmd.SideEffects && !sideEffects) {
+			continue
+		}
+
+		words := append(append([]string{}, entry.Command...), entry.Args...)
+		if err := ctx.RunCommand(words); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// RunForEach will run the command given by the words against each of
+// the stables in the roots, calling label with the root before
+// running the command for it. Only read-only commands are allowed
+// unless allowChanges is true. If the command fails for a stable, the
+// remaining stables are still processed and the first error is
+// returned.
+func (ctx *Context) RunForEach(roots, words []string, allowChanges bool, label func(root string)) error {
+	cmd, node, rest := ctx.tree.Locate(words)
+	if cmd == nil {
+		return &RunError{Err: locateError(words, node, rest), Where: node}
+	}
+	if cmd.SkipStable {
+		return fmt.Errorf("Command %q does not use a stable", strings.Join(cmd.path, " "))
+	}
+	if !cmd.ReadOnly && !allowChanges {
+		return fmt.Errorf("Command %q can change the stable", strings.Join(cmd.path, " "))
+	}
+
+	saved := ctx.RootDir
+	defer func() { ctx.RootDir = saved }()
+
+	var result error
+	for _, root := range roots {
+		label(root)
+		ctx.RootDir = root
+		if err := cmd.Run(ctx, rest); err != nil && result == nil {
+			result = fmt.Errorf("Stable %s: %s", root, err)
+		}
+	}
+	return result
+}
+
+// Walk will call the function for every group and command in the
+// command tree, starting with the root of the tree. The nodes of each
+// group are visited in order of their names, with each group visited
+// before the nodes it contain.
+func (ctx *Context) Walk(fn func(path []string, node Node)) {
+	ctx.tree.walk(fn)
+}
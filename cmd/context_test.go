@@ -16,7 +16,19 @@
 
 package cmd_test
 
-import "mysqld/cmd"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mysqld/cmd"
+	"mysqld/stable"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
 
 func ExampleContext_RegisterCommand() {
 	context := cmd.NewContext(
@@ -33,3 +45,181 @@ func ExampleContext_RegisterCommand() {
 
 	context.RegisterCommand([]string{"init"}, sampleCmd)
 }
+
+func TestReplay(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	context := cmd.NewContext("Just a test", "")
+	context.RootDir = root
+	context.RegisterGroup([]string{"distribution"}, &cmd.Group{})
+	context.RegisterGroup([]string{"server"}, &cmd.Group{})
+	context.RegisterCommand([]string{"distribution", "add"}, &cmd.Command{
+		Body: func(ctx *cmd.Context, _ *cmd.Command, args []string) error {
+			ctx.Stable.Distro[args[0]] = &stable.Dist{Name: args[0]}
+			return nil
+		},
+	})
+	context.RegisterCommand([]string{"server", "add"}, &cmd.Command{
+		Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+			port, _ := strconv.Atoi(cmd.Flags.Lookup("port").Value.String())
+			ctx.Stable.Server[args[0]] = &stable.Server{
+				Name: args[0],
+				Dist: ctx.Stable.Distro[args[1]],
+				Port: port,
+			}
+			return nil
+		},
+		Init: func(cmd *cmd.Command) {
+			cmd.Flags.Int("port", 3306, "Port of the server")
+		},
+	})
+	context.RegisterCommand([]string{"server", "execute"}, &cmd.Command{
+		SideEffects: true,
+		Body: func(*cmd.Context, *cmd.Command, []string) error {
+			return errors.New("Command should not be replayed")
+		},
+	})
+
+	log := `{"Command":["distribution","add"],"Args":["mysql-5.6.14"]}
+{"Command":["server","add"],"Args":["-port=3307","master","mysql-5.6.14"]}
+{"Command":["server","add"],"Args":["slave","mysql-5.6.14"]}
+{"Command":["server","add"],"Args":["broken","mysql-5.6.14"],"Error":"Unable to create server"}
+{"Command":["server","execute"],"Args":["slave","SELECT 1"]}
+`
+	entries, err := stable.ReadAudit(strings.NewReader(log))
+	if err != nil {
+		t.Fatalf("Unable to read audit log: %s", err)
+	}
+
+	if _, err := stable.CreateStable(root); err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+
+	count, err := context.Replay(entries, false)
+	if err != nil {
+		t.Fatalf("Replay failed: %s", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 replayed commands, got %d", count)
+	}
+
+	stbl, err := stable.OpenStable(root)
+	if err != nil {
+		t.Fatalf("Unable to open stable: %s", err)
+	}
+	if _, ok := stbl.Distro["mysql-5.6.14"]; !ok || len(stbl.Distro) != 1 {
+		t.Errorf("Expected only distribution %q, got %v", "mysql-5.6.14", stbl.Distro)
+	}
+	for _, name := range []string{"master", "slave"} {
+		if _, ok := stbl.Server[name]; !ok {
+			t.Errorf("Server %q missing after replay", name)
+		}
+	}
+
+	// Flags given to one command are not used for the next.
+	ports := map[string]int{"master": 3307, "slave": 3306}
+	for name, port := range ports {
+		if srv, ok := stbl.Server[name]; ok && srv.Port != port {
+			t.Errorf("Expected port %d for server %q, got %d", port, name, srv.Port)
+		}
+	}
+	if len(stbl.Server) != 2 {
+		t.Errorf("Expected 2 servers, got %v", stbl.Server)
+	}
+}
+
+func TestRunForEach(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	roots := []string{filepath.Join(root, "first"), filepath.Join(root, "second")}
+	for _, dir := range roots {
+		os.Mkdir(dir, 0755)
+		if _, err := stable.CreateStable(dir); err != nil {
+			t.Fatalf("Unable to create stable: %s", err)
+		}
+	}
+
+	// The show command records the stable it was run against
+	// together with its arguments.
+	output := []string{}
+	context := cmd.NewContext("Just a test", "")
+	context.RootDir = root
+	context.RegisterGroup([]string{"server"}, &cmd.Group{})
+	context.RegisterCommand([]string{"server", "show"}, &cmd.Command{
+		ReadOnly: true,
+		Body: func(ctx *cmd.Context, _ *cmd.Command, args []string) error {
+			output = append(output, ctx.Stable.Root+" "+strings.Join(args, " "))
+			return nil
+		},
+	})
+	context.RegisterCommand([]string{"server", "add"}, &cmd.Command{
+		Body: func(*cmd.Context, *cmd.Command, []string) error {
+			output = append(output, "add")
+			return nil
+		},
+	})
+
+	label := func(root string) { output = append(output, "== "+root) }
+	if err := context.RunForEach(roots, []string{"server", "show", "slave.*"}, false, label); err != nil {
+		t.Fatalf("Unable to run command: %s", err)
+	}
+	expect := []string{
+		"== " + roots[0],
+		filepath.Join(roots[0], stable.STABLE_DIR) + " slave.*",
+		"== " + roots[1],
+		filepath.Join(roots[1], stable.STABLE_DIR) + " slave.*",
+	}
+	if strings.Join(output, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("Expected output %q, got %q", expect, output)
+	}
+	if context.RootDir != root {
+		t.Errorf("Expected root directory %q to be restored, got %q", root, context.RootDir)
+	}
+
+	// Commands that can change the stable are only allowed when
+	// explicitly requested.
+	output = []string{}
+	if err := context.RunForEach(roots, []string{"server", "add"}, false, label); err == nil {
+		t.Errorf("Expected error when running command that change the stable")
+	}
+	if len(output) > 0 {
+		t.Errorf("Expected no output, got %q", output)
+	}
+	if err := context.RunForEach(roots, []string{"server", "add"}, true, label); err != nil {
+		t.Errorf("Unable to run command: %s", err)
+	}
+	if len(output) != 4 {
+		t.Errorf("Expected command to run for both stables, got %q", output)
+	}
+}
+
+func TestOutput(t *testing.T) {
+	context := cmd.NewContext("Just a test", "")
+	if context.Out != os.Stdout {
+		t.Errorf("Expected output to standard output by default")
+	}
+	context.RegisterCommand([]string{"greet"}, &cmd.Command{
+		SkipStable: true,
+		Body: func(ctx *cmd.Context, _ *cmd.Command, args []string) error {
+			fmt.Fprintf(ctx.Out, "Hello %s\n", strings.Join(args, " "))
+			return nil
+		},
+	})
+
+	var buf bytes.Buffer
+	context.Out = &buf
+	if err := context.RunCommand([]string{"greet", "-", "world"}); err != nil {
+		t.Fatalf("Unable to run command: %s", err)
+	}
+	if buf.String() != "Hello - world\n" {
+		t.Errorf("Expected output %q, got %q", "Hello - world\n", buf.String())
+	}
+}
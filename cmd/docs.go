@@ -0,0 +1,159 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Formats supported when writing documentation.
+const (
+	FORMAT_MARKDOWN = "markdown"
+	FORMAT_MAN      = "man"
+)
+
+// paragraphs will split a description into paragraphs, where each
+// paragraph has the whitespace normalized. Paragraphs are separated
+// by empty lines.
+func paragraphs(text string) []string {
+	result := []string{}
+	words := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			if len(words) > 0 {
+				result = append(result, strings.Join(words, " "))
+				words = []string{}
+			}
+			continue
+		}
+		words = append(words, fields...)
+	}
+	if len(words) > 0 {
+		result = append(result, strings.Join(words, " "))
+	}
+	return result
+}
+
+// flagDefault will return a description of the default value of the
+// flag, or an empty string if it does not have one.
+func flagDefault(flag *flag.Flag) string {
+	if len(flag.DefValue) > 0 {
+		return fmt.Sprintf(" (default %q)", flag.DefValue)
+	}
+	return ""
+}
+
+// WriteDocs will write documentation for all groups and commands in
+// the command tree to the writer in the given format, which can be
+// either FORMAT_MARKDOWN or FORMAT_MAN. The name is the name of the
+// program.
+func (ctx *Context) WriteDocs(w io.Writer, name, format string) error {
+	switch format {
+	case FORMAT_MARKDOWN:
+		ctx.Walk(func(path []string, node Node) {
+			writeMarkdown(w, name, path, node)
+		})
+	case FORMAT_MAN:
+		ctx.Walk(func(path []string, node Node) {
+			writeMan(w, name, path, node)
+		})
+	default:
+		return fmt.Errorf("Unknown documentation format %q", format)
+	}
+	return nil
+}
+
+func writeMarkdown(w io.Writer, name string, path []string, node Node) {
+	level := len(path) + 1
+	if level > 6 {
+		level = 6
+	}
+	heading := strings.Join(append([]string{name}, path...), " ")
+	fmt.Fprintf(w, "%s %s\n\n%s\n\n", strings.Repeat("#", level), heading, node.Summary())
+
+	switch node := node.(type) {
+	case *Group:
+		for _, para := range paragraphs(node.Description) {
+			fmt.Fprintf(w, "%s\n\n", para)
+		}
+
+	case *Command:
+		fmt.Fprintf(w, "Usage: `%s %s`\n\n", heading, node.Synopsis)
+		for _, para := range paragraphs(node.Description) {
+			fmt.Fprintf(w, "%s\n\n", para)
+		}
+
+		hasFlags := false
+		node.Flags.VisitAll(func(flag *flag.Flag) {
+			if !hasFlags {
+				fmt.Fprintf(w, "Options:\n\n")
+				hasFlags = true
+			}
+			fmt.Fprintf(w, "- `-%s`: %s%s\n", flag.Name, flag.Usage, flagDefault(flag))
+		})
+		if hasFlags {
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// manEscape will escape text so that it is not interpreted by troff.
+func manEscape(text string) string {
+	text = strings.Replace(text, `\`, `\e`, -1)
+	text = strings.Replace(text, "-", `\-`, -1)
+	if strings.HasPrefix(text, ".") || strings.HasPrefix(text, "'") {
+		text = `\&` + text
+	}
+	return text
+}
+
+func writeMan(w io.Writer, name string, path []string, node Node) {
+	if len(path) == 0 {
+		fmt.Fprintf(w, ".TH %s 1\n", strings.ToUpper(name))
+		fmt.Fprintf(w, ".SH NAME\n%s \\- %s\n", name, manEscape(node.Summary()))
+		fmt.Fprintf(w, ".SH DESCRIPTION\n")
+		for _, para := range paragraphs(node.(*Group).Description) {
+			fmt.Fprintf(w, ".PP\n%s\n", manEscape(para))
+		}
+		fmt.Fprintf(w, ".SH COMMANDS\n")
+		return
+	}
+
+	heading := strings.Join(path, " ")
+	fmt.Fprintf(w, ".SS \"%s\"\n%s\n", heading, manEscape(node.Summary()))
+
+	switch node := node.(type) {
+	case *Group:
+		for _, para := range paragraphs(node.Description) {
+			fmt.Fprintf(w, ".PP\n%s\n", manEscape(para))
+		}
+
+	case *Command:
+		fmt.Fprintf(w, ".PP\n.B %s %s\n%s\n", name, manEscape(heading), manEscape(node.Synopsis))
+		for _, para := range paragraphs(node.Description) {
+			fmt.Fprintf(w, ".PP\n%s\n", manEscape(para))
+		}
+		node.Flags.VisitAll(func(flag *flag.Flag) {
+			fmt.Fprintf(w, ".TP\n.B \\-%s\n%s\n", manEscape(flag.Name),
+				manEscape(flag.Usage+flagDefault(flag)))
+		})
+	}
+}
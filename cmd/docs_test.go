@@ -0,0 +1,96 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteDocs(t *testing.T) {
+	ctx := NewContext("Just a test", "The root of the tree.")
+	ctx.RegisterGroup([]string{"server"}, &Group{
+		Brief:       "Server commands",
+		Description: "All commands for servers.",
+	})
+	ctx.RegisterCommand([]string{"server", "add"}, &Command{
+		Brief:    "Add a server",
+		Synopsis: "[ OPTION ] NAME",
+		Description: `Add a new server.
+
+        The server is bootstrapped.`,
+		Init: func(cmd *Command) {
+			cmd.Flags.String("dist", "", "Distribution to use")
+			cmd.Flags.Int("count", 1, "Number of servers")
+		},
+	})
+	ctx.RegisterCommand([]string{"server", "start"}, &Command{
+		Brief:    "Start a server",
+		Synopsis: "PATTERN",
+		Init: func(cmd *Command) {
+			cmd.Flags.Bool("wait", false, "Wait for the server")
+		},
+	})
+	ctx.RegisterCommand([]string{"help"}, &Command{
+		Brief:    "Give help",
+		Synopsis: "WORD ...",
+	})
+
+	var buf bytes.Buffer
+	if err := ctx.WriteDocs(&buf, "prog", FORMAT_MARKDOWN); err != nil {
+		t.Fatalf("Unable to write docs: %s", err)
+	}
+	output := buf.String()
+
+	expected := []string{
+		"# prog\n\nJust a test\n\nThe root of the tree.\n",
+		"## prog help\n",
+		"Usage: `prog help WORD ...`",
+		"## prog server\n\nServer commands\n\nAll commands for servers.\n",
+		"### prog server add\n",
+		"Usage: `prog server add [ OPTION ] NAME`",
+		"Add a new server.\n\nThe server is bootstrapped.\n",
+		"- `-count`: Number of servers (default \"1\")",
+		"- `-dist`: Distribution to use\n",
+		"### prog server start\n",
+		"Usage: `prog server start PATTERN`",
+		"- `-wait`: Wait for the server (default \"false\")",
+	}
+	for _, str := range expected {
+		if !strings.Contains(output, str) {
+			t.Errorf("Expected %q in output:\n%s", str, output)
+		}
+	}
+
+	// Groups are documented before the commands they contain
+	if strings.Index(output, "## prog server\n") > strings.Index(output, "### prog server add") {
+		t.Errorf("Group not documented before its commands:\n%s", output)
+	}
+
+	buf.Reset()
+	if err := ctx.WriteDocs(&buf, "prog", FORMAT_MAN); err != nil {
+		t.Fatalf("Unable to write docs: %s", err)
+	}
+	if !strings.Contains(buf.String(), ".B prog server add\n[ OPTION ] NAME\n") {
+		t.Errorf("Expected synopsis in man page:\n%s", buf.String())
+	}
+
+	if err := ctx.WriteDocs(&buf, "prog", "html"); err == nil {
+		t.Errorf("Expected error for unknown format")
+	}
+}
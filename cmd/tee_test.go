@@ -0,0 +1,64 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTeeFile(t *testing.T) {
+	root, err := ioutil.TempDir("", "tee")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	path := filepath.Join(root, "output.log")
+	ioutil.WriteFile(path, []byte("Earlier output\n"), 0644)
+
+	var term bytes.Buffer
+	w, file, err := TeeFile(&term, path)
+	if err != nil {
+		t.Fatalf("Unable to open file: %s", err)
+	}
+	fmt.Fprintf(w, "\n%s> %s\n", "master", "SELECT 1")
+	fmt.Fprintf(w, "1\n")
+	if err := file.Close(); err != nil {
+		t.Fatalf("Unable to close file: %s", err)
+	}
+
+	expect := "\nmaster> SELECT 1\n1\n"
+	if term.String() != expect {
+		t.Errorf("Expected %q on terminal, got %q", expect, term.String())
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unable to read file: %s", err)
+	}
+	if string(content) != "Earlier output\n"+expect {
+		t.Errorf("Expected %q in file, got %q", "Earlier output\n"+expect, content)
+	}
+
+	if _, _, err := TeeFile(&term, filepath.Join(root, "missing", "output.log")); err == nil {
+		t.Errorf("Expected error when file cannot be created")
+	}
+}
@@ -25,26 +25,76 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 var (
 	ErrSectionPresent = errors.New("Section exists")
 	ErrSectionMissing = errors.New("Section missing")
+	ErrOptionMissing  = errors.New("Option missing")
 )
 
+// ParseError is the error when a configuration file cannot be
+// read. It gives the file and line where the error was found, which
+// for errors in included files is the location in the included file.
+// File is empty if the configuration was not read from a file.
+type ParseError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (err *ParseError) Error() string {
+	if len(err.File) == 0 {
+		return fmt.Sprintf("Line %d: %s", err.Line, err.Err)
+	}
+	return fmt.Sprintf("%s:%d: %s", err.File, err.Line, err.Err)
+}
+
 // Section is a section of the configuration file. Each section can
 // contain mappings from options to values. The values are always
-// stored as strings, but they can be converted on retrieval.
+// stored as strings, but they can be converted on retrieval. The
+// order the options were added in is kept in keys, so that they are
+// written back in the same order. Options without a value, such as
+// "skip_networking", are marked in flags and written without a value.
+// Comments on the same line as an option are kept in comments and
+// written back after the option. Values that were single-quoted are
+// marked in literals and the values before environment variables
+// were expanded are kept in templates, see ExpandEnv.
 type Section struct {
-	Header  []string
-	options map[string]string
+	Header    []string
+	options   map[string]string
+	keys      []string
+	flags     map[string]bool
+	comments  map[string]string
+	literals  map[string]bool
+	templates map[string]string
 }
 
 // Config is the configuration structure holding the sections and
 // options.
+//
+// Options before the first section are only accepted if Lenient is
+// set, in which case they are placed in the section with the empty
+// name, which is written first and without a section header.
+//
+// The order the sections were added in is kept in order, so that
+// they are written back in the same order.
+//
+// If Raw is set, options where environment variables were expanded
+// are written with the value before expansion.
 type Config struct {
 	Header  []string
 	Section map[string]*Section
+	Lenient bool `json:"-"`
+	Raw     bool `json:"-"`
+	order   []string
 }
 
 // New will create a new empty configuration structure.
@@ -72,14 +122,24 @@ func (cnf *Config) AddSection(section string) (*Section, error) {
 		return nil, ErrSectionPresent
 	}
 
-	sec := &Section{
-		Header:  make([]string, 0),
-		options: make(map[string]string),
-	}
+	sec := newSection()
 	cnf.Section[section] = sec
+	cnf.order = append(cnf.order, section)
 	return sec, nil
 }
 
+// newSection will create a new empty section.
+func newSection() *Section {
+	return &Section{
+		Header:    make([]string, 0),
+		options:   make(map[string]string),
+		flags:     make(map[string]bool),
+		comments:  make(map[string]string),
+		literals:  make(map[string]bool),
+		templates: make(map[string]string),
+	}
+}
+
 // RemoveSection will remove a section from the configuration
 // structure. If the section is missing from the structure, a
 // ErrSectionMissing error is returned.
@@ -89,19 +149,238 @@ func (cnf *Config) RemoveSection(section string) error {
 		return fmt.Errorf("Section %q missing", section)
 	}
 	delete(cnf.Section, section)
+	for i, name := range cnf.order {
+		if name == section {
+			cnf.order = append(cnf.order[:i], cnf.order[i+1:]...)
+			break
+		}
+	}
 	return nil
 }
 
+// CanonicalOption will return the canonical form of an option
+// name. MySQL treat dashes and underscores in option names as
+// equivalent, so the name is stored with underscores only.
+func CanonicalOption(option string) string {
+	return strings.Replace(option, "-", "_", -1)
+}
+
+// HasSection will return true if the configuration has a section
+// with the name, false otherwise.
+func (cnf *Config) HasSection(name string) bool {
+	_, ok := cnf.Section[name]
+	return ok
+}
+
+// Get will return the value of an option in a section, and whether
+// the option was set. If the section or option does not exist, an
+// empty string and false are returned.
+func (cnf *Config) Get(section, option string) (string, bool) {
+	sec, ok := cnf.Section[section]
+	if !ok {
+		return "", false
+	}
+	value, ok := sec.options[CanonicalOption(option)]
+	return value, ok
+}
+
+// Set will set the value of an option in a section. If the section
+// does not exist, it is added.
+func (cnf *Config) Set(section, option, value string) {
+	sec, ok := cnf.Section[section]
+	if !ok {
+		sec, _ = cnf.AddSection(section)
+	}
+	sec.SetString(option, value)
+}
+
+// Has will return true if the option is set in the section, false
+// otherwise.
+func (sec *Section) Has(option string) bool {
+	_, ok := sec.options[CanonicalOption(option)]
+	return ok
+}
+
+// Remove will remove the option from the section, together with its
+// comment. Removing an option that is not set does nothing.
+func (sec *Section) Remove(option string) {
+	option = CanonicalOption(option)
+	if _, ok := sec.options[option]; !ok {
+		return
+	}
+	delete(sec.options, option)
+	delete(sec.flags, option)
+	delete(sec.comments, option)
+	delete(sec.literals, option)
+	delete(sec.templates, option)
+	for i, opt := range sec.keys {
+		if opt == option {
+			sec.keys = append(sec.keys[:i], sec.keys[i+1:]...)
+			break
+		}
+	}
+}
+
 // GetString will return the value of an option in a section. If the
 // section or option does not exist, an error is returned.
 func (sec *Section) GetString(option string) string {
-	return sec.options[option]
+	return sec.options[CanonicalOption(option)]
+}
+
+// lookup will return the value of an option in a section, or
+// ErrOptionMissing if the option is not present.
+func (sec *Section) lookup(option string) (string, error) {
+	val, ok := sec.options[CanonicalOption(option)]
+	if !ok {
+		return "", ErrOptionMissing
+	}
+	return val, nil
+}
+
+// sizeSuffix map the suffixes accepted for sizes to the multiplier.
+var sizeSuffix = map[string]uint64{
+	"K": 1 << 10,
+	"M": 1 << 20,
+	"G": 1 << 30,
+	"T": 1 << 40,
+}
+
+// parseSize will parse a size, which can have one of the suffixes K,
+// M, G, or T in upper or lower case.
+func parseSize(val string) (uint64, error) {
+	multiplier := uint64(1)
+	if len(val) > 0 {
+		if mult, ok := sizeSuffix[strings.ToUpper(val[len(val)-1:])]; ok {
+			multiplier = mult
+			val = val[:len(val)-1]
+		}
+	}
+	num, err := strconv.ParseUint(val, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if num > math.MaxUint64/multiplier {
+		return 0, fmt.Errorf("Size %q out of range", val)
+	}
+	return num * multiplier, nil
+}
+
+// GetUint64 will return the value of an option in a section as an
+// unsigned integer. Sizes like "256M" are accepted, see parseSize. If
+// the option does not exist, ErrOptionMissing is returned.
+func (sec *Section) GetUint64(option string) (uint64, error) {
+	val, err := sec.lookup(option)
+	if err != nil {
+		return 0, err
+	}
+	num, err := parseSize(val)
+	if err != nil {
+		return 0, fmt.Errorf("Option %q: %s", option, err)
+	}
+	return num, nil
+}
+
+// GetInt will return the value of an option in a section as an
+// integer. Sizes like "256M" are accepted, see parseSize. If the
+// option does not exist, ErrOptionMissing is returned.
+func (sec *Section) GetInt(option string) (int, error) {
+	val, err := sec.lookup(option)
+	if err != nil {
+		return 0, err
+	}
+	neg := strings.HasPrefix(val, "-")
+	num, err := parseSize(strings.TrimPrefix(val, "-"))
+	if err != nil {
+		return 0, fmt.Errorf("Option %q: %s", option, err)
+	}
+	if num > uint64(^uint(0)>>1) {
+		return 0, fmt.Errorf("Option %q: value %q out of range", option, val)
+	}
+	if neg {
+		return -int(num), nil
+	}
+	return int(num), nil
+}
+
+// GetBool will return the value of an option in a section as a
+// boolean. The values ON, TRUE, and 1 are true and OFF, FALSE, and 0
+// are false, ignoring case. An option without a value is true. If
+// the option does not exist, ErrOptionMissing is returned.
+func (sec *Section) GetBool(option string) (bool, error) {
+	val, err := sec.lookup(option)
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToUpper(val) {
+	case "", "ON", "TRUE", "1":
+		return true, nil
+	case "OFF", "FALSE", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("Option %q: %q is not a boolean", option, val)
 }
 
 // Set will set the value of an option in a section. If the section
-// did not exist prior to the call, the section will be created.
+// did not exist prior to the call, the section will be created. The
+// option is stored and written using underscores as separator, see
+// CanonicalOption.
 func (sec *Section) SetString(opt, val string) {
+	opt = CanonicalOption(opt)
+	if _, exists := sec.options[opt]; !exists {
+		sec.keys = append(sec.keys, opt)
+	}
 	sec.options[opt] = val
+	delete(sec.flags, opt)
+	delete(sec.literals, opt)
+	delete(sec.templates, opt)
+}
+
+// SetFlag will set an option that does not take a value, for example
+// "skip_networking". The value of the option is the empty string and
+// it is written without a value.
+func (sec *Section) SetFlag(opt string) {
+	sec.SetString(opt, "")
+	sec.flags[CanonicalOption(opt)] = true
+}
+
+// SetComment will set the comment written after an option. An empty
+// comment removes the comment.
+func (sec *Section) SetComment(opt, comment string) {
+	if len(comment) == 0 {
+		delete(sec.comments, CanonicalOption(opt))
+	} else {
+		sec.comments[CanonicalOption(opt)] = comment
+	}
+}
+
+// Comment will return the comment written after an option, or the
+// empty string if there is none.
+func (sec *Section) Comment(opt string) string {
+	return sec.comments[CanonicalOption(opt)]
+}
+
+// IsFlag will return true if the option is set without a value.
+func (sec *Section) IsFlag(opt string) bool {
+	return sec.flags[CanonicalOption(opt)]
+}
+
+// Options will return the names of all options in the section,
+// sorted by name.
+func (sec *Section) Options() []string {
+	names := make([]string, 0, len(sec.options))
+	for opt := range sec.options {
+		names = append(names, opt)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Each will call the function for each option in the section, in
+// order of the option names.
+func (sec *Section) Each(fn func(option, value string)) {
+	for _, opt := range sec.Options() {
+		fn(opt, sec.options[opt])
+	}
 }
 
 // ImportSection will import options into a single section.
@@ -133,28 +412,209 @@ func (cnf *Config) Import(sections map[string]map[string]string) error {
 	return nil
 }
 
+// Merge will overlay the other configuration on this one. Each
+// option in other is set in this configuration, overwriting any
+// existing value and creating sections as needed. Options that are
+// only in this configuration are kept.
+func (cnf *Config) Merge(other *Config) {
+	for _, name := range other.sectionNames() {
+		sec, exists := cnf.Section[name]
+		if !exists {
+			sec, _ = cnf.AddSection(name)
+		}
+		sec.merge(other.Section[name])
+	}
+}
+
+// merge will copy the options of another section into the section,
+// together with their comments, overwriting any options already set.
+func (sec *Section) merge(from *Section) {
+	for _, opt := range from.keys {
+		if from.flags[opt] {
+			sec.SetFlag(opt)
+		} else {
+			sec.SetString(opt, from.options[opt])
+		}
+		if comment, ok := from.comments[opt]; ok {
+			sec.SetComment(opt, comment)
+		}
+		if from.literals[opt] {
+			sec.literals[opt] = true
+		}
+		if template, ok := from.templates[opt]; ok {
+			sec.templates[opt] = template
+		}
+	}
+}
+
+// majorMinor will return the major and minor version of a version
+// string, for example "5.7" for "5.7.30-log".
+func majorMinor(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return version
+	}
+	minor := parts[1]
+	if end := strings.IndexFunc(minor, func(ch rune) bool { return ch < '0' || ch > '9' }); end >= 0 {
+		minor = minor[:end]
+	}
+	return parts[0] + "." + minor
+}
+
+// EffectiveSection will return the options that a program reading
+// the base group will see when it has the given version. As with
+// MySQL, this is the options of the base group, for example
+// "[mysqld]", together with the options of the group with the major
+// and minor version as suffix, for example "[mysqld-5.7]" for version
+// "5.7.30". Groups are read in the order they appear in the file, so
+// an option in a later group overrides the same option in an earlier
+// one. The returned section is a copy and changing it does not change
+// the configuration.
+func (cnf *Config) EffectiveSection(base, version string) *Section {
+	sec := newSection()
+	suffixed := base + "-" + majorMinor(version)
+	for _, name := range cnf.sectionNames() {
+		if name == base || len(version) > 0 && name == suffixed {
+			sec.merge(cnf.Section[name])
+		}
+	}
+	return sec
+}
+
 // Write will write the option structure to the given writer. If the
-// structure was previously read from an options file, comments will
-// not be written back.
+// structure was previously read from an options file, the comments
+// before each section and the comments on the same line as an option
+// are written back. Other comments are not kept.
+//
+// Sections are written in the order they were added, each preceeded
+// by its own header. Sections that were added to Section directly are
+// written last, in order of their names.
 func (cnf *Config) Write(wr io.Writer) error {
-	for name, sec := range cnf.Section {
+	for _, line := range cnf.Header {
+		fmt.Fprintf(wr, "# %s\n", line)
+	}
+	if sec, ok := cnf.Section[""]; ok {
+		sec.write(wr, cnf.Raw)
+	}
+	for _, name := range cnf.sectionNames() {
+		if len(name) == 0 {
+			continue
+		}
+		sec := cnf.Section[name]
 		fmt.Fprintf(wr, "\n\n")
-		for _, line := range cnf.Header {
-			fmt.Fprintf(wr, "# %s", line)
+		for _, line := range sec.Header {
+			fmt.Fprintf(wr, "# %s\n", line)
 		}
 		fmt.Fprintf(wr, "[%s]\n", name)
-		for opt, val := range sec.options {
-			fmt.Fprintln(wr, opt, "=", val)
-		}
+		sec.write(wr, cnf.Raw)
 	}
 	return nil
 }
 
+// sectionNames will return the names of all sections, in the order
+// they were added. Sections not added using AddSection are placed
+// last, sorted by name.
+func (cnf *Config) sectionNames() []string {
+	names := make([]string, 0, len(cnf.Section))
+	seen := make(map[string]bool)
+	for _, name := range cnf.order {
+		if _, ok := cnf.Section[name]; ok && !seen[name] {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+	rest := []string{}
+	for name := range cnf.Section {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return append(names, rest...)
+}
+
+// write will write the options of the section to the given writer,
+// in the order they were added, together with their comments. If raw
+// is set, the values before expanding environment variables are
+// written.
+func (sec *Section) write(wr io.Writer, raw bool) {
+	for _, opt := range sec.keys {
+		line := opt
+		if template, ok := sec.templates[opt]; raw && ok {
+			line += " = " + quote(template, false)
+		} else if !sec.flags[opt] {
+			line += " = " + quote(sec.options[opt], sec.literals[opt])
+		}
+		if comment, ok := sec.comments[opt]; ok {
+			line += " # " + comment
+		}
+		fmt.Fprintln(wr, line)
+	}
+}
+
+// isQuote will return true if the character is a quote character.
+func isQuote(ch byte) bool {
+	return ch == '\'' || ch == '"'
+}
+
+// commentStart will return the position where the comment of the
+// line starts, or -1 if there is no comment. Comment characters in a
+// quoted value are not the start of a comment. As with MySQL, a value
+// is only quoted if it starts with a quote.
+func commentStart(line []byte) int {
+	seenSeparator := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case ';', '#':
+			return i
+		case '=', ':':
+			if seenSeparator {
+				continue
+			}
+			seenSeparator = true
+			value := bytes.TrimLeft(line[i+1:], " \t")
+			if len(value) > 0 && isQuote(value[0]) {
+				if end := bytes.IndexByte(value[1:], value[0]); end >= 0 {
+					i = len(line) - len(value) + end + 1
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// unquote will remove the quotes around a quoted value.
+func unquote(value []byte) []byte {
+	if len(value) >= 2 && isQuote(value[0]) && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// quote will quote the value if it contains spaces or comment
+// characters, or if it starts with a quote, so that it is read back
+// unchanged. Literal values containing '$' are quoted so that they
+// are not expanded, see ExpandEnv. Single quotes are used unless the
+// value contains a single quote or is not literal and contains '$'.
+// Values containing both kinds of quotes cannot be written
+// losslessly.
+func quote(value string, literal bool) string {
+	dollar := strings.ContainsRune(value, '$')
+	if !strings.ContainsAny(value, " \t;#") && !(literal && dollar) &&
+		(len(value) == 0 || !isQuote(value[0])) {
+		return value
+	}
+	if strings.ContainsRune(value, '\'') || dollar && !literal {
+		return `"` + value + `"`
+	}
+	return "'" + value + "'"
+}
+
 // trimLine will remove (and return) slices to the line (without
 // leading and trailing whitespace) and comment (without leading and
 // trailing whitespace).
 func trimLine(line []byte) ([]byte, []byte) {
-	if pos := bytes.IndexAny(line, ";#"); pos != -1 {
+	if pos := commentStart(line); pos != -1 {
 		result := bytes.TrimSpace(line[:pos])
 		comment := bytes.TrimSpace(line[pos+1:])
 		return result, comment
@@ -169,6 +629,7 @@ func trimLine(line []byte) ([]byte, []byte) {
 func (cnf *Config) swap(other *Config) {
 	cnf.Header, other.Header = other.Header, cnf.Header
 	cnf.Section, other.Section = other.Section, cnf.Section
+	cnf.order, other.order = other.order, cnf.order
 }
 
 // scanLogicalLines will find the end of a logical line, taking
@@ -231,18 +692,191 @@ func scanLogicalLines(data []byte, atEOF bool) (int, []byte, error) {
 	}
 }
 
+// MAX_INCLUDE_DEPTH is the maximum nesting of included files, which
+// is the same as used by MySQL.
+const MAX_INCLUDE_DEPTH = 10
+
+// parser hold the state of reading a configuration file, which is
+// shared with any files included from it so that included options
+// behave as if they were textually inlined.
+type parser struct {
+	cnf         *Config
+	lenient     bool
+	section     string
+	headerLines []string
+	depth       int
+}
+
 // Read will read a configuration file from the provided reader rd and
 // parse it as a MySQL configuration file. Each section may optionally
 // be preceeded with a section comment which is an unbroken sequence
 // of comment lines. The header will then be stored with the section
 // and written back when the configuration file is written out.
+//
+// Options before the first section are an error, unless the
+// configuration is lenient. Errors in the file are returned as a
+// ParseError with the line of the error.
+//
+// The directives "!include FILE" and "!includedir DIR" read the file,
+// or every "*.cnf" file in the directory, as if it was inlined. Since
+// the reader has no directory, relative paths are resolved against
+// the current directory. Use ReadFile to resolve them against the
+// directory of the file.
 func (cnf *Config) Read(rd io.Reader) error {
+	return cnf.read(rd, "", ".")
+}
+
+// ReadFile will read the configuration file at path. Relative paths
+// in include directives are resolved against the directory of the
+// file.
+func (cnf *Config) ReadFile(path string) error {
+	fd, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	return cnf.read(fd, path, filepath.Dir(path))
+}
+
+// ReadFile will read the configuration file at path into a new
+// configuration structure.
+func ReadFile(path string) (*Config, error) {
+	cnf := New()
+	if err := cnf.ReadFile(path); err != nil {
+		return nil, err
+	}
+	return cnf, nil
+}
+
+// WriteFile will write the configuration to the file at path. The
+// configuration is first written to a temporary file in the same
+// directory, which then replace the file, so a failed write never
+// leave a truncated file behind.
+func (cnf *Config) WriteFile(path string) error {
+	fd, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	wr := bufio.NewWriter(fd)
+	cnf.Write(wr)
+	err = wr.Flush()
+	if err == nil {
+		err = fd.Chmod(0644)
+	}
+	if cerr := fd.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(fd.Name(), path)
+	}
+	if err != nil {
+		os.Remove(fd.Name())
+	}
+	return err
+}
+
+// read will parse the configuration file in rd, resolving included
+// files relative to dir, and replace the contents of the
+// configuration with the result. The name of the file is used in
+// errors.
+func (cnf *Config) read(rd io.Reader, name, dir string) error {
+	p := &parser{
+		cnf:         New(),
+		lenient:     cnf.Lenient,
+		headerLines: []string{},
+	}
+	if err := p.parse(rd, name, dir); err != nil {
+		return err
+	}
+	cnf.swap(p.cnf)
+	return nil
+}
+
+// include will parse the file at path as if it was inlined in the
+// file being read.
+func (p *parser) include(path string) error {
+	if p.depth >= MAX_INCLUDE_DEPTH {
+		return fmt.Errorf("Include of %q nested too deeply", path)
+	}
+	fd, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	p.depth++
+	defer func() { p.depth-- }()
+	return p.parse(fd, path, filepath.Dir(path))
+}
+
+// includeDir will parse all "*.cnf" files in the directory, in order
+// of the file names.
+func (p *parser) includeDir(dir string) error {
+	if _, err := os.Stat(dir); err != nil {
+		return err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.cnf"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		if err := p.include(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// directive will handle a line starting with '!', resolving relative
+// paths against dir.
+func (p *parser) directive(line []byte, dir string) error {
+	fields := bytes.Fields(line[1:])
+	if len(fields) != 2 {
+		return fmt.Errorf("Malformed directive %q", line)
+	}
+	path := string(fields[1])
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	switch string(fields[0]) {
+	case "include":
+		return p.include(path)
+	case "includedir":
+		return p.includeDir(path)
+	}
+	return fmt.Errorf("Unknown directive %q", fields[0])
+}
+
+// parse will parse the lines read from rd into the configuration of
+// the parser. Any error is returned as a ParseError giving the name
+// of the file and the line where the error was found.
+func (p *parser) parse(rd io.Reader, name, dir string) error {
+	lineno := 0
+	err := p.parseLines(rd, dir, &lineno)
+	if _, ok := err.(*ParseError); err == nil || ok {
+		return err
+	}
+	return &ParseError{File: name, Line: lineno, Err: err}
+}
+
+// parseLines will parse the lines read from rd, keeping lineno at
+// the line where the current logical line started.
+func (p *parser) parseLines(rd io.Reader, dir string, lineno *int) error {
 	scanner := bufio.NewScanner(rd)
-	// MySQL do not accept continuation lines, but we do
-	scanner.Split(scanLogicalLines)
-	newCnf := New()
-	section := ""
-	headerLines := []string{}
+	// MySQL do not accept continuation lines, but we do. Count the
+	// newlines consumed, so that line numbers refer to the physical
+	// lines of the file.
+	next := 1
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := scanLogicalLines(data, atEOF)
+		if token != nil {
+			*lineno = next
+			next += bytes.Count(data[:advance], []byte{'\n'})
+		}
+		return advance, token, err
+	})
+	newCnf := p.cnf
 
 	for scanner.Scan() {
 		source := scanner.Text()
@@ -251,30 +885,58 @@ func (cnf *Config) Read(rd io.Reader) error {
 		switch {
 		case len(bytes.TrimSpace([]byte(source))) == 0:
 			// This was an empty line, so the header is cleared
-			headerLines = []string{}
+			p.headerLines = []string{}
 
 		case len(line) == 0:
 			if comment != nil {
-				headerLines = append(headerLines, string(comment))
+				p.headerLines = append(p.headerLines, string(comment))
 			}
 
-		case line[0] == '[' && line[len(line)-1] == ']':
-			section = string(bytes.TrimSpace(line[1 : len(line)-1]))
-			newCnf.AddSection(section)
-			newCnf.Section[section].Header = headerLines
-			headerLines = make([]string, 0)
+		case line[0] == '[':
+			if line[len(line)-1] != ']' {
+				return fmt.Errorf("Malformed section header %q", line)
+			}
+			p.section = string(bytes.TrimSpace(line[1 : len(line)-1]))
+			if len(p.section) == 0 {
+				return errors.New("Empty section name")
+			}
+			newCnf.AddSection(p.section)
+			newCnf.Section[p.section].Header = p.headerLines
+			p.headerLines = make([]string, 0)
 
 		case line[0] == '!':
-			panic("File inclusions not handled yet")
+			if err := p.directive(line, dir); err != nil {
+				return err
+			}
 
 		default:
+			// Options without a separator are flags
+			option, value := line, []byte{}
+			literal := false
 			i := bytes.IndexAny(line, ":=")
-			option := bytes.TrimSpace(line[:i])
-			value := bytes.TrimSpace(line[i+1:])
-			newCnf.Section[section].SetString(string(option), string(value))
+			if i >= 0 {
+				option = bytes.TrimSpace(line[:i])
+				value = bytes.TrimSpace(line[i+1:])
+				literal = len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\''
+				value = unquote(value)
+			}
+			if _, ok := newCnf.Section[p.section]; !ok {
+				if !p.lenient {
+					return fmt.Errorf("Option %q outside section", option)
+				}
+				newCnf.AddSection(p.section)
+			}
+			sec := newCnf.Section[p.section]
+			if i < 0 {
+				sec.SetFlag(string(option))
+			} else {
+				sec.SetString(string(option), string(value))
+			}
+			sec.SetComment(string(option), string(comment))
+			if literal {
+				sec.literals[CanonicalOption(string(option))] = true
+			}
 		}
 	}
-
-	cnf.swap(newCnf)
-	return nil
+	return scanner.Err()
 }
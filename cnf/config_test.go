@@ -18,8 +18,11 @@ package cnf
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -43,6 +46,10 @@ func TestTrimLine(t *testing.T) {
 		"  x=12#just a test":  Result{"x=12", []byte("just a test")},
 		"  x=12;just a test":  Result{"x=12", []byte("just a test")},
 		"  x=12;#just a test": Result{"x=12", []byte("#just a test")},
+		"x = 'a#b' # test":    Result{"x = 'a#b'", []byte("test")},
+		`x = "a;b"`:           Result{`x = "a;b"`, nil},
+		"x = it's # test":     Result{"x = it's", []byte("test")},
+		"x = 'open # test":    Result{"x = 'open", []byte("test")},
 	}
 
 	for sample, expected := range samples {
@@ -177,6 +184,96 @@ func TestOptions(t *testing.T) {
 	}
 }
 
+func TestGetSet(t *testing.T) {
+	config := New()
+	if _, ok := config.Get("mysqld", "port"); ok {
+		t.Errorf("Expected no option in missing section")
+	}
+
+	config.Set("mysqld", "port", "3306")
+	config.Set("mysqld", "init-file", "")
+	if !config.HasSection("mysqld") {
+		t.Fatalf("Expected section %q to be added", "mysqld")
+	}
+	if val, ok := config.Get("mysqld", "port"); !ok || val != "3306" {
+		t.Errorf("Expected %q for option %q, got %q (found %v)", "3306", "port", val, ok)
+	}
+	if val, ok := config.Get("mysqld", "init_file"); !ok || val != "" {
+		t.Errorf("Expected empty option %q to be found, got %q (found %v)", "init_file", val, ok)
+	}
+	if _, ok := config.Get("mysqld", "socket"); ok {
+		t.Errorf("Expected option %q to be missing", "socket")
+	}
+}
+
+func TestEffectiveSection(t *testing.T) {
+	source := `
+[mysqld-5.7]
+port = 3307
+socket = /tmp/mysqld-5.7.sock
+
+[mysqld]
+port = 3306
+user = mysql
+log_bin = mysql-bin
+
+[mysqld-5.6]
+port = 3305
+`
+	cnf := New()
+	if err := cnf.Read(strings.NewReader(source)); err != nil {
+		t.Fatalf("Unable to read configuration: %s", err)
+	}
+
+	// The "[mysqld-5.7]" group comes before the "[mysqld]" group,
+	// so its port is overridden, while the "[mysqld-5.6]" group
+	// comes after and override the port.
+	tests := []struct {
+		version string
+		options map[string]string
+	}{
+		{"", map[string]string{"port": "3306", "socket": "", "user": "mysql"}},
+		{"5.7.30-log", map[string]string{"port": "3306", "socket": "/tmp/mysqld-5.7.sock", "user": "mysql"}},
+		{"5.6.14", map[string]string{"port": "3305", "socket": "", "user": "mysql"}},
+		{"5.5.40", map[string]string{"port": "3306", "socket": "", "user": "mysql"}},
+		{"5.70.1", map[string]string{"port": "3306", "socket": "", "user": "mysql"}},
+	}
+	for _, test := range tests {
+		sec := cnf.EffectiveSection("mysqld", test.version)
+		for opt, val := range test.options {
+			if res := sec.GetString(opt); res != val {
+				t.Errorf("Expected %q to be %q for version %q, was %q", opt, val, test.version, res)
+			}
+		}
+	}
+
+	// The effective section is a copy of the options.
+	cnf.EffectiveSection("mysqld", "5.7.30").SetString("port", "3310")
+	if port := cnf.Section["mysqld"].GetString("port"); port != "3306" {
+		t.Errorf("Expected port %q to be unchanged, was %q", "3306", port)
+	}
+}
+
+func TestEach(t *testing.T) {
+	cnf := New()
+	cnf.Import(map[string]map[string]string{
+		"mysqld": {
+			"port":    "3306",
+			"datadir": "/var/lib/mysql",
+			"log-bin": "",
+		},
+	})
+
+	result := []string{}
+	cnf.Section["mysqld"].Each(func(option, value string) {
+		result = append(result, option+"="+value)
+	})
+	expect := "datadir=/var/lib/mysql log_bin= port=3306"
+	if strings.Join(result, " ") != expect {
+		t.Errorf("Expected %q, got %q", expect, strings.Join(result, " "))
+	}
+}
+
 func TestImport(t *testing.T) {
 	cnf := New()
 
@@ -217,21 +314,25 @@ func TestWriteRead(t *testing.T) {
 
 	cnf.Import(sample)
 
-	filename := "test.cnf"
+	dir, err := ioutil.TempDir("", "cnf")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.cnf")
 
-	if fd, err := os.Create(filename); err != nil {
-		t.Fatalf("Unable to create %q: %s", filename, err)
-	} else {
-		cnf.Write(fd)
-		fd.Close()
+	if err := cnf.WriteFile(filename); err != nil {
+		t.Fatalf("Unable to write %q: %s", filename, err)
 	}
 
 	cnf.Section["second"].SetString("delta", "four") // Should not exist after reloading
 
-	if fd, err := os.Open(filename); err != nil {
-		t.Fatalf("Unable to open file %q", filename)
-	} else {
-		cnf.Read(fd)
+	cnf, err = ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Unable to read %q: %s", filename, err)
+	}
+	if val := cnf.Section["second"].GetString("delta"); len(val) > 0 {
+		t.Errorf("Expected no option %q after reloading, got %q", "delta", val)
 	}
 
 	for sec, contents := range sample {
@@ -316,3 +417,538 @@ gamma : 3;Another test
 	}
 
 }
+
+func TestReadTopLevel(t *testing.T) {
+	source := `
+user = mysql
+port = 3306
+
+[mysqld]
+port = 3307
+`
+	cnf := New()
+	if err := cnf.Read(strings.NewReader(source)); err == nil {
+		t.Errorf("Expected error for options outside section")
+	}
+
+	cnf = New()
+	cnf.Lenient = true
+	if err := cnf.Read(strings.NewReader(source)); err != nil {
+		t.Fatalf("Unable to read options outside section: %s", err)
+	}
+	if port := cnf.Section[""].GetString("port"); port != "3306" {
+		t.Errorf("Expected port %q outside section, got %q", "3306", port)
+	}
+	if port := cnf.Section["mysqld"].GetString("port"); port != "3307" {
+		t.Errorf("Expected port %q in section, got %q", "3307", port)
+	}
+
+	// The options outside the sections are written first and
+	// without a section header.
+	var buf bytes.Buffer
+	cnf.Write(&buf)
+	if out := buf.String(); !strings.HasPrefix(out, "user = mysql\nport = 3306\n") {
+		t.Errorf("Expected options outside section first, got %q", out)
+	}
+}
+
+func TestReadMalformed(t *testing.T) {
+	sources := map[string]string{
+		"[mysqld]\nport = 3306\n[client\nuser = mysql\n": "Line 3: Malformed section header",
+		"[mysqld]\n[]\nport = 3306\n":                    "Line 2: Empty section name",
+		"\nport = 3306\n[mysqld]\n":                      "Line 2: Option \"port\" outside section",
+	}
+	for source, expect := range sources {
+		cnf := New()
+		err := cnf.Read(strings.NewReader(source))
+		if err == nil {
+			t.Errorf("Expected error for %q, got none", source)
+		} else if !strings.HasPrefix(err.Error(), expect) {
+			t.Errorf("Expected error %q for %q, got %q", expect, source, err)
+		}
+	}
+}
+
+func TestParseErrorLocation(t *testing.T) {
+	// Continuation lines count as separate lines.
+	source := "[mysqld]\nplugin_load = a.so;\\\nb.so\n[client\n"
+	err := New().Read(strings.NewReader(source))
+	if perr, ok := err.(*ParseError); !ok || perr.Line != 4 {
+		t.Errorf("Expected error on line 4, got %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "cnf")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Errors in included files give the location in the included
+	// file, not in the including file.
+	main := filepath.Join(dir, "my.cnf")
+	extra := filepath.Join(dir, "extra.cnf")
+	ioutil.WriteFile(main, []byte("[mysqld]\nport = 3306\n!include extra.cnf\n"), 0644)
+	ioutil.WriteFile(extra, []byte("[client]\n\n[mysqld\n"), 0644)
+	err = New().ReadFile(main)
+	if expect := extra + ":3: "; err == nil || !strings.HasPrefix(err.Error(), expect) {
+		t.Errorf("Expected error starting with %q, got %v", expect, err)
+	}
+
+	// Errors in the directive are reported for the including file.
+	ioutil.WriteFile(main, []byte("[mysqld]\n!include missing.cnf\n"), 0644)
+	err = New().ReadFile(main)
+	if expect := main + ":2: "; err == nil || !strings.HasPrefix(err.Error(), expect) {
+		t.Errorf("Expected error starting with %q, got %v", expect, err)
+	}
+}
+
+func TestReadInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cnf")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"my.cnf":            "[mysqld]\nport = 3306\n!include extra.cnf\n!includedir conf.d\n",
+		"extra.cnf":         "user = mysql\n",
+		"conf.d/a.cnf":      "[client]\nport = 3307\n",
+		"conf.d/b.cnf":      "[mysqld]\nport = 3308\n",
+		"conf.d/ignore.txt": "[ignored]\n",
+	}
+	os.Mkdir(filepath.Join(dir, "conf.d"), 0755)
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatalf("Unable to write %s: %s", name, err)
+		}
+	}
+
+	cnf := New()
+	if err := cnf.ReadFile(filepath.Join(dir, "my.cnf")); err != nil {
+		t.Fatalf("Unable to read configuration: %s", err)
+	}
+	expected := map[string]map[string]string{
+		"mysqld": {"port": "3308", "user": "mysql"},
+		"client": {"port": "3307"},
+	}
+	for name, options := range expected {
+		sec, ok := cnf.Section[name]
+		if !ok {
+			t.Errorf("Section %q missing", name)
+			continue
+		}
+		for opt, val := range options {
+			if res := sec.GetString(opt); res != val {
+				t.Errorf("Expected %q in section %q to be %q, was %q", opt, name, val, res)
+			}
+		}
+	}
+	if _, ok := cnf.Section["ignored"]; ok {
+		t.Errorf("File without .cnf extension was included")
+	}
+
+	cnf = New()
+	source := "[mysqld]\n!include " + filepath.Join(dir, "missing.cnf") + "\n"
+	if err := cnf.Read(strings.NewReader(source)); err == nil {
+		t.Errorf("Expected error for missing include file")
+	}
+
+	// A file including itself should not recurse forever.
+	loop := filepath.Join(dir, "loop.cnf")
+	ioutil.WriteFile(loop, []byte("[mysqld]\n!include loop.cnf\n"), 0644)
+	if err := New().ReadFile(loop); err == nil {
+		t.Errorf("Expected error for recursive include")
+	}
+}
+
+func TestWriteOrder(t *testing.T) {
+	sections := []string{
+		"[mysqld]\nport = 3306\nsocket = /tmp/mysqld.sock\nbind_address = 127.0.0.1\nuser = mysql\n",
+		"[client]\nuser = root\nport = 3306\nhost = localhost\n",
+	}
+	cnf := New()
+	if err := cnf.Read(strings.NewReader(strings.Join(sections, "\n"))); err != nil {
+		t.Fatalf("Unable to read configuration: %s", err)
+	}
+	cnf.Section["client"].SetString("user", "mats")
+
+	var buf bytes.Buffer
+	cnf.Write(&buf)
+	out := buf.String()
+	sections[1] = strings.Replace(sections[1], "user = root", "user = mats", 1)
+	for _, section := range sections {
+		if !strings.Contains(out, section) {
+			t.Errorf("Expected %q in output, got %q", section, out)
+		}
+	}
+}
+
+func TestWriteSectionOrder(t *testing.T) {
+	source := `
+# Server options
+[mysqld]
+port = 3306
+
+[mysqld_safe]
+log_error = /var/log/mysqld.err
+
+# Client options
+# used by all clients
+[client]
+port = 3306
+`
+	cnf := New()
+	if err := cnf.Read(strings.NewReader(source)); err != nil {
+		t.Fatalf("Unable to read configuration: %s", err)
+	}
+
+	var buf bytes.Buffer
+	cnf.Write(&buf)
+	out := buf.String()
+	expected := "\n\n# Server options\n[mysqld]\nport = 3306\n" +
+		"\n\n[mysqld_safe]\nlog_error = /var/log/mysqld.err\n" +
+		"\n\n# Client options\n# used by all clients\n[client]\nport = 3306\n"
+	if out != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+
+	// Reading the output back should give the same sections in
+	// the same order.
+	other := New()
+	if err := other.Read(strings.NewReader(out)); err != nil {
+		t.Fatalf("Unable to read written configuration: %s", err)
+	}
+	buf.Reset()
+	other.Write(&buf)
+	if buf.String() != out {
+		t.Errorf("Round-trip changed output from %q to %q", out, buf.String())
+	}
+
+	other.RemoveSection("mysqld_safe")
+	other.AddSection("mysqld_safe")
+	buf.Reset()
+	other.Write(&buf)
+	if !strings.HasSuffix(buf.String(), "[mysqld_safe]\n") {
+		t.Errorf("Expected re-added section last, got %q", buf.String())
+	}
+}
+
+func TestTypedGetters(t *testing.T) {
+	cnf := New()
+	sec, _ := cnf.AddSection("mysqld")
+	sec.Import(map[string]string{
+		"port":                    "3306",
+		"offset":                  "-2",
+		"innodb_buffer_pool_size": "256M",
+		"max_allowed_packet":      "1g",
+		"key_buffer_size":         "16K",
+		"log_bin":                 "ON",
+		"skip_name_resolve":       "1",
+		"general_log":             "off",
+		"read_only":               "false",
+		"bad":                     "many",
+	})
+
+	ints := map[string]int{
+		"port":            3306,
+		"offset":          -2,
+		"key_buffer_size": 16 * 1024,
+	}
+	for opt, expected := range ints {
+		if val, err := sec.GetInt(opt); err != nil {
+			t.Errorf("GetInt(%q): %s", opt, err)
+		} else if val != expected {
+			t.Errorf("GetInt(%q) was %d, expected %d", opt, val, expected)
+		}
+	}
+
+	sizes := map[string]uint64{
+		"port":                    3306,
+		"innodb_buffer_pool_size": 256 * 1024 * 1024,
+		"max_allowed_packet":      1024 * 1024 * 1024,
+	}
+	for opt, expected := range sizes {
+		if val, err := sec.GetUint64(opt); err != nil {
+			t.Errorf("GetUint64(%q): %s", opt, err)
+		} else if val != expected {
+			t.Errorf("GetUint64(%q) was %d, expected %d", opt, val, expected)
+		}
+	}
+
+	bools := map[string]bool{
+		"log_bin":           true,
+		"skip_name_resolve": true,
+		"general_log":       false,
+		"read_only":         false,
+	}
+	for opt, expected := range bools {
+		if val, err := sec.GetBool(opt); err != nil {
+			t.Errorf("GetBool(%q): %s", opt, err)
+		} else if val != expected {
+			t.Errorf("GetBool(%q) was %v, expected %v", opt, val, expected)
+		}
+	}
+
+	if _, err := sec.GetInt("bad"); err == nil || err == ErrOptionMissing {
+		t.Errorf("Expected parse error from GetInt, got %v", err)
+	}
+	if _, err := sec.GetUint64("offset"); err == nil {
+		t.Errorf("Expected error for negative size")
+	}
+	if _, err := sec.GetBool("bad"); err == nil || err == ErrOptionMissing {
+		t.Errorf("Expected parse error from GetBool, got %v", err)
+	}
+	if _, err := sec.GetInt("missing"); err != ErrOptionMissing {
+		t.Errorf("Expected ErrOptionMissing from GetInt, got %v", err)
+	}
+	if _, err := sec.GetUint64("missing"); err != ErrOptionMissing {
+		t.Errorf("Expected ErrOptionMissing from GetUint64, got %v", err)
+	}
+	if _, err := sec.GetBool("missing"); err != ErrOptionMissing {
+		t.Errorf("Expected ErrOptionMissing from GetBool, got %v", err)
+	}
+}
+
+func TestCanonicalOption(t *testing.T) {
+	cnf := New()
+	if err := cnf.Read(strings.NewReader("[mysqld]\nlog-bin = master\nlog_bin = binlog\nskip-name-resolve = 1\n")); err != nil {
+		t.Fatalf("Unable to read configuration: %s", err)
+	}
+	sec := cnf.Section["mysqld"]
+	for _, opt := range []string{"log-bin", "log_bin"} {
+		if val := sec.GetString(opt); val != "binlog" {
+			t.Errorf("Expected %q for %q, got %q", "binlog", opt, val)
+		}
+	}
+	if val, err := sec.GetBool("skip_name_resolve"); err != nil || !val {
+		t.Errorf("Expected skip_name_resolve to be true, got %v (%v)", val, err)
+	}
+
+	sec.SetString("log-bin", "other")
+	var buf bytes.Buffer
+	cnf.Write(&buf)
+	expected := "\n\n[mysqld]\nlog_bin = other\nskip_name_resolve = 1\n"
+	if out := buf.String(); out != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+}
+
+func TestFlagOptions(t *testing.T) {
+	source := "\n\n[mysqld]\nskip_networking\nport = 3306\ninit_file = \n\n\n[mysql]\nquick\n"
+	cnf := New()
+	if err := cnf.Read(strings.NewReader(source)); err != nil {
+		t.Fatalf("Unable to read configuration: %s", err)
+	}
+	sec := cnf.Section["mysqld"]
+	if !sec.IsFlag("skip-networking") {
+		t.Errorf("Expected skip-networking to be a flag")
+	}
+	if sec.IsFlag("init_file") {
+		t.Errorf("Expected init_file with empty value not to be a flag")
+	}
+	if val, err := sec.GetBool("skip_networking"); err != nil || !val {
+		t.Errorf("Expected skip_networking to be true, got %v (%v)", val, err)
+	}
+
+	var buf bytes.Buffer
+	cnf.Write(&buf)
+	if out := buf.String(); out != source {
+		t.Errorf("Expected %q, got %q", source, out)
+	}
+
+	// Setting a value on a flag turns it into a normal option
+	sec.SetString("skip_networking", "OFF")
+	if sec.IsFlag("skip_networking") {
+		t.Errorf("Expected skip_networking not to be a flag after setting it")
+	}
+}
+
+func TestWriteComments(t *testing.T) {
+	source := "\n\n# Server options\n# for testing\n[mysqld]\nport = 3306 # Default port\nskip_networking # No TCP\nuser = mysql\n"
+	cnf := New()
+	if err := cnf.Read(strings.NewReader(source)); err != nil {
+		t.Fatalf("Unable to read configuration: %s", err)
+	}
+	if comment := cnf.Section["mysqld"].Comment("port"); comment != "Default port" {
+		t.Errorf("Expected comment %q for port, got %q", "Default port", comment)
+	}
+
+	var buf bytes.Buffer
+	cnf.Write(&buf)
+	if out := buf.String(); out != source {
+		t.Errorf("Expected %q, got %q", source, out)
+	}
+
+	// Comments written with the other comment character are
+	// written back using '#'.
+	cnf = New()
+	cnf.Read(strings.NewReader("[mysqld]\nport = 3306 ; Default port\n"))
+	cnf.Section["mysqld"].SetComment("user", "Not set")
+	cnf.Section["mysqld"].SetString("user", "mysql")
+	buf.Reset()
+	cnf.Write(&buf)
+	expected := "\n\n[mysqld]\nport = 3306 # Default port\nuser = mysql # Not set\n"
+	if out := buf.String(); out != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := New()
+	base.Import(map[string]map[string]string{
+		"mysqld": {"port": "3306", "log_output": "file"},
+		"client": {"user": "root"},
+	})
+
+	other := New()
+	other.Import(map[string]map[string]string{
+		"mysqld": {"port": "3307", "server_id": "2"},
+		"mysql":  {"prompt": "'two> '"},
+	})
+	other.Section["mysqld"].SetFlag("skip_networking")
+
+	base.Merge(other)
+	expected := map[string]map[string]string{
+		"mysqld": {"port": "3307", "log_output": "file", "server_id": "2", "skip_networking": ""},
+		"client": {"user": "root"},
+		"mysql":  {"prompt": "'two> '"},
+	}
+	for name, options := range expected {
+		sec, ok := base.Section[name]
+		if !ok {
+			t.Errorf("Section %q missing", name)
+			continue
+		}
+		for opt, val := range options {
+			if res := sec.GetString(opt); res != val {
+				t.Errorf("Expected %q in section %q to be %q, was %q", opt, name, val, res)
+			}
+		}
+		if len(sec.Options()) != len(options) {
+			t.Errorf("Expected options %v in section %q, got %v", options, name, sec.Options())
+		}
+	}
+	if !base.Section["mysqld"].IsFlag("skip_networking") {
+		t.Errorf("Expected skip_networking to be merged as a flag")
+	}
+
+	// The other configuration is not changed
+	if _, ok := other.Section["client"]; ok {
+		t.Errorf("Merge changed the merged configuration")
+	}
+}
+
+func TestWriteFileFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cnf")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Writing into a missing directory fails without creating
+	// any files.
+	path := filepath.Join(dir, "missing", "my.cnf")
+	if err := New().WriteFile(path); err == nil {
+		t.Errorf("Expected error writing %q", path)
+	}
+
+	// Writing an existing file replaces it and leave no
+	// temporary files behind.
+	path = filepath.Join(dir, "my.cnf")
+	ioutil.WriteFile(path, []byte("[old]\n"), 0644)
+	cnf := New()
+	cnf.Import(map[string]map[string]string{"mysqld": {"port": "3306"}})
+	if err := cnf.WriteFile(path); err != nil {
+		t.Fatalf("Unable to write %q: %s", path, err)
+	}
+	if entries, _ := ioutil.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected only %q in directory, got %d entries", path, len(entries))
+	}
+	read, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unable to read %q: %s", path, err)
+	}
+	if _, ok := read.Section["old"]; ok {
+		t.Errorf("Expected old contents to be replaced")
+	}
+	if port := read.Section["mysqld"].GetString("port"); port != "3306" {
+		t.Errorf("Expected port %q, got %q", "3306", port)
+	}
+}
+
+func TestQuotedValues(t *testing.T) {
+	source := `[mysql]
+prompt = 'one> '
+pager = "less -S"  # Pager to use
+delimiter = ';'
+comment = 'it''
+`
+	cnf := New()
+	if err := cnf.Read(strings.NewReader(source)); err != nil {
+		t.Fatalf("Unable to read configuration: %s", err)
+	}
+	sec := cnf.Section["mysql"]
+	expected := map[string]string{
+		"prompt":    "one> ",
+		"pager":     "less -S",
+		"delimiter": ";",
+		"comment":   "it'",
+	}
+	for opt, val := range expected {
+		if res := sec.GetString(opt); res != val {
+			t.Errorf("Expected %q for %q, got %q", val, opt, res)
+		}
+	}
+	sec.SetString("status", "it's # here")
+	sec.SetString("quoted", "'x'")
+	expected["status"] = "it's # here"
+	expected["quoted"] = "'x'"
+
+	// Writing and reading back should give the same values
+	var buf bytes.Buffer
+	cnf.Write(&buf)
+	other := New()
+	if err := other.Read(&buf); err != nil {
+		t.Fatalf("Unable to read written configuration: %s", err)
+	}
+	for opt, val := range expected {
+		if res := other.Section["mysql"].GetString(opt); res != val {
+			t.Errorf("Expected %q for %q after round-trip, got %q", val, opt, res)
+		}
+	}
+	if comment := other.Section["mysql"].Comment("pager"); comment != "Pager to use" {
+		t.Errorf("Expected comment %q for pager, got %q", "Pager to use", comment)
+	}
+}
+
+func TestRemoveOption(t *testing.T) {
+	cnf := New()
+	if err := cnf.Read(strings.NewReader("[mysqld]\nport = 3306 # Port\nskip-networking\nuser = mysql\n")); err != nil {
+		t.Fatalf("Unable to read configuration: %s", err)
+	}
+	if !cnf.HasSection("mysqld") || cnf.HasSection("client") {
+		t.Errorf("Expected only section %q, got %v", "mysqld", cnf.sectionNames())
+	}
+
+	sec := cnf.Section["mysqld"]
+	if !sec.Has("skip_networking") || !sec.Has("port") || sec.Has("socket") {
+		t.Errorf("Unexpected options %v", sec.Options())
+	}
+
+	sec.Remove("skip-networking")
+	sec.Remove("port")
+	sec.Remove("socket")
+	if sec.Has("skip_networking") || sec.Has("port") {
+		t.Errorf("Expected options to be removed, got %v", sec.Options())
+	}
+
+	// Adding the option back should not bring back the comment
+	// or the flag.
+	sec.SetString("port", "3307")
+	var buf bytes.Buffer
+	cnf.Write(&buf)
+	expected := "\n\n[mysqld]\nuser = mysql\nport = 3307\n"
+	if out := buf.String(); out != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package cnf
+
+import (
+	"os"
+)
+
+// Expand will replace ${VAR} and $VAR in the option values with the
+// value returned by mapping, in the same way as os.Expand. Values
+// that were single-quoted in the configuration file are literal and
+// are not expanded. The values before expansion are kept and written
+// instead of the expanded values if Raw is set.
+func (cnf *Config) Expand(mapping func(string) string) {
+	for _, sec := range cnf.Section {
+		for _, opt := range sec.keys {
+			if sec.literals[opt] || sec.flags[opt] {
+				continue
+			}
+			template, ok := sec.templates[opt]
+			if !ok {
+				template = sec.options[opt]
+			}
+			value := os.Expand(template, mapping)
+			if value != template {
+				sec.options[opt] = value
+				sec.templates[opt] = template
+			}
+		}
+	}
+}
+
+// ExpandEnv will replace ${VAR} and $VAR in the option values with
+// the value of the environment variable VAR, see Expand. Variables
+// that are not set are replaced with the empty string.
+func (cnf *Config) ExpandEnv() {
+	cnf.Expand(os.Getenv)
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package cnf
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("GOMYSQL_TEST_ROOT", "/tmp/stable")
+	defer os.Unsetenv("GOMYSQL_TEST_ROOT")
+	os.Unsetenv("GOMYSQL_TEST_UNSET")
+
+	source := `[mysqld]
+datadir = ${GOMYSQL_TEST_ROOT}/data
+socket = $GOMYSQL_TEST_ROOT/mysql.sock
+tmpdir = "${GOMYSQL_TEST_ROOT}/my tmp"
+pid_file = '${GOMYSQL_TEST_ROOT}/mysqld.pid'
+user = ${GOMYSQL_TEST_UNSET}mysql
+port = 3306
+`
+	cnf := New()
+	if err := cnf.Read(strings.NewReader(source)); err != nil {
+		t.Fatalf("Unable to read configuration: %s", err)
+	}
+	cnf.ExpandEnv()
+
+	sec := cnf.Section["mysqld"]
+	expected := map[string]string{
+		"datadir":  "/tmp/stable/data",
+		"socket":   "/tmp/stable/mysql.sock",
+		"tmpdir":   "/tmp/stable/my tmp",
+		"pid_file": "${GOMYSQL_TEST_ROOT}/mysqld.pid",
+		"user":     "mysql",
+		"port":     "3306",
+	}
+	for opt, val := range expected {
+		if res := sec.GetString(opt); res != val {
+			t.Errorf("Expected %q for %q, got %q", val, opt, res)
+		}
+	}
+
+	var buf bytes.Buffer
+	cnf.Write(&buf)
+	expanded := "\n\n[mysqld]\ndatadir = /tmp/stable/data\nsocket = /tmp/stable/mysql.sock\n" +
+		"tmpdir = '/tmp/stable/my tmp'\npid_file = '${GOMYSQL_TEST_ROOT}/mysqld.pid'\n" +
+		"user = mysql\nport = 3306\n"
+	if out := buf.String(); out != expanded {
+		t.Errorf("Expected %q, got %q", expanded, out)
+	}
+
+	buf.Reset()
+	cnf.Raw = true
+	cnf.Write(&buf)
+	raw := "\n\n[mysqld]\ndatadir = ${GOMYSQL_TEST_ROOT}/data\nsocket = $GOMYSQL_TEST_ROOT/mysql.sock\n" +
+		"tmpdir = \"${GOMYSQL_TEST_ROOT}/my tmp\"\npid_file = '${GOMYSQL_TEST_ROOT}/mysqld.pid'\n" +
+		"user = ${GOMYSQL_TEST_UNSET}mysql\nport = 3306\n"
+	if out := buf.String(); out != raw {
+		t.Errorf("Expected %q, got %q", raw, out)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package cnf
+
+import (
+	"encoding/json"
+)
+
+// sectionJSON is the layout of a section when encoded as JSON. The
+// options are kept in unexported fields of the section, so they need
+// to be listed explicitly to survive encoding and decoding.
+type sectionJSON struct {
+	Header    []string
+	Options   map[string]string `json:",omitempty"`
+	Keys      []string          `json:",omitempty"`
+	Flags     map[string]bool   `json:",omitempty"`
+	Comments  map[string]string `json:",omitempty"`
+	Literals  map[string]bool   `json:",omitempty"`
+	Templates map[string]string `json:",omitempty"`
+}
+
+// MarshalJSON will encode the section, including the options and
+// their comments, as JSON.
+func (sec *Section) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&sectionJSON{
+		Header:    sec.Header,
+		Options:   sec.options,
+		Keys:      sec.keys,
+		Flags:     sec.flags,
+		Comments:  sec.comments,
+		Literals:  sec.literals,
+		Templates: sec.templates,
+	})
+}
+
+// UnmarshalJSON will decode a section encoded using MarshalJSON. Maps
+// missing from the encoding are left empty, so that the section can be
+// changed after decoding.
+func (sec *Section) UnmarshalJSON(data []byte) error {
+	var content sectionJSON
+	if err := json.Unmarshal(data, &content); err != nil {
+		return err
+	}
+	*sec = *newSection()
+	if content.Header != nil {
+		sec.Header = content.Header
+	}
+	sec.keys = content.Keys
+	copyStrings(sec.options, content.Options)
+	copyStrings(sec.comments, content.Comments)
+	copyStrings(sec.templates, content.Templates)
+	copyBools(sec.flags, content.Flags)
+	copyBools(sec.literals, content.Literals)
+	return nil
+}
+
+// configJSON is the layout of a configuration when encoded as JSON.
+type configJSON struct {
+	Header  []string
+	Section map[string]*Section
+	Order   []string `json:",omitempty"`
+}
+
+// MarshalJSON will encode the configuration as JSON, keeping the
+// order of the sections.
+func (cnf *Config) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&configJSON{
+		Header:  cnf.Header,
+		Section: cnf.Section,
+		Order:   cnf.order,
+	})
+}
+
+// UnmarshalJSON will decode a configuration encoded using
+// MarshalJSON.
+func (cnf *Config) UnmarshalJSON(data []byte) error {
+	var content configJSON
+	if err := json.Unmarshal(data, &content); err != nil {
+		return err
+	}
+	cnf.Header, cnf.Section, cnf.order = content.Header, content.Section, content.Order
+	if cnf.Header == nil {
+		cnf.Header = make([]string, 0)
+	}
+	if cnf.Section == nil {
+		cnf.Section = make(map[string]*Section)
+	}
+	return nil
+}
+
+func copyStrings(dst, src map[string]string) {
+	for key, value := range src {
+		dst[key] = value
+	}
+}
+
+func copyBools(dst, src map[string]bool) {
+	for key, value := range src {
+		dst[key] = value
+	}
+}
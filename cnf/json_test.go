@@ -0,0 +1,71 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package cnf
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	source := `
+[mysqld]
+port = 3306 # Default port
+skip_networking
+init_file = '/tmp/$HOME'
+
+[client]
+user = root
+`
+	cnf := New()
+	if err := cnf.Read(strings.NewReader(source)); err != nil {
+		t.Fatalf("Unable to read configuration: %s", err)
+	}
+	data, err := json.Marshal(cnf)
+	if err != nil {
+		t.Fatalf("Unable to encode configuration: %s", err)
+	}
+
+	decoded := New()
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("Unable to decode configuration: %s", err)
+	}
+	if port, ok := decoded.Get("mysqld", "port"); !ok || port != "3306" {
+		t.Errorf("Expected port %q, got %q (found %v)", "3306", port, ok)
+	}
+
+	// The decoded configuration is written back the same way
+	var expected, result bytes.Buffer
+	cnf.Write(&expected)
+	decoded.Write(&result)
+	if expected.String() != result.String() {
+		t.Errorf("Expected %q, got %q", expected.String(), result.String())
+	}
+
+	// Sections without options, as written by older versions,
+	// can still be changed after decoding.
+	old := New()
+	if err := json.Unmarshal([]byte(`{"Header":[],"Section":{"mysqld":{"Header":[]}}}`), old); err != nil {
+		t.Fatalf("Unable to decode configuration: %s", err)
+	}
+	old.Section["mysqld"].SetString("port", "3307")
+	if port, ok := old.Get("mysqld", "port"); !ok || port != "3307" {
+		t.Errorf("Expected port %q, got %q (found %v)", "3307", port, ok)
+	}
+}
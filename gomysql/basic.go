@@ -19,8 +19,10 @@ package main
 import (
 	"fmt"
 	"mysqld/cmd"
+	"mysqld/log"
 	"mysqld/stable"
 	"os"
+	"time"
 )
 
 var version = "0.1.0"
@@ -52,17 +54,112 @@ var initCmd = cmd.Command{
 		// field in the context to ensure that surrounding code can
 		// use it.
 		stbl, err := stable.CreateStable(args[0])
-		if err == nil {
-			ctx.Stable = stbl
+		if err != nil {
+			return err
 		}
+		ctx.Stable = stbl
 
 		// Look for an existing mysqld installation at some
 		// known places. Note that the files might not be
 		// located all in the same directory, so we have to
 		// build a structure for the distribution to match
 		// what is expected from an added distribution.
+		dist, err := stbl.AddSystemDist()
+		if err == stable.ErrDistNotFound {
+			return nil
+		} else if err != nil {
+			log.Warningf("Unable to add installed server: %s", err)
+			return nil
+		}
+		fmt.Fprintf(ctx.Out, "Added installed server %s in %s as distribution %q\n",
+			dist.ServerVersion, dist.Root, dist.Name)
+		return stbl.WriteConfig()
+	},
+}
+
+var destroyCmd = cmd.Command{
+	Brief: "Destroy the stable and all servers in it",
+
+	Description: `This command will remove the stable, including
+	all distributions and servers in it, together with all the data
+	of the servers. Before anything is removed, the running servers
+	are stopped, so that no server processes are left behind.
+
+        The stable and the servers in it are printed and you are asked
+        to confirm before anything is removed, unless the global option
+        -yes is given.
+
+        If a server is still running after -timeout, the stable is not
+        destroyed. If -force is given, such servers are killed instead.`,
+
+	Synopsis:   "[ OPTION ]",
+	SkipStable: true,
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) > 0 {
+			return ErrTooManyArgs
+		}
+
+		// The stable is opened here rather than by the command
+		// runner, since the configuration should not be
+		// written back once the stable is destroyed.
+		stbl, err := stable.OpenStable(ctx.RootDir)
+		if err != nil {
+			return err
+		}
+		if err := stbl.Lock(stable.LOCK_TIMEOUT); err != nil {
+			return err
+		}
+		defer stbl.Unlock()
+
+		servers, err := stbl.FindMatchingServers([]string{"*"})
+		if err != nil {
+			return err
+		}
+		items := []string{"stable " + stbl.Root}
+		for _, srv := range servers {
+			items = append(items, "server "+srv.Name)
+		}
+		if err := ctx.Confirm("The following will be destroyed:", items); err != nil {
+			return err
+		}
+
+		timeout, interval, err := waitOptions(cmd)
+		if err != nil {
+			return err
+		}
+		force := cmd.Flags.Lookup("force").Value.String() == "true"
+		jobs, err := jobsOption(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Stop all running servers. Failures are reported but
+		// do not stop the command: the servers still running
+		// are checked below.
+		err = stable.ForEach(servers, jobs, func(srv *stable.Server) error {
+			if srv.Status() != stable.SERVER_RUNNING {
+				return nil
+			}
+			srv.SetPollInterval(interval)
+			return srv.Shutdown(timeout, force)
+		})
+		if err != nil {
+			log.Warningf("Unable to stop servers: %s", err)
+		}
+
+		for _, srv := range servers {
+			if srv.Status() == stable.SERVER_RUNNING {
+				return fmt.Errorf("Server %s is still running, stable not destroyed", srv.Name)
+			}
+		}
+
+		return stbl.Destroy()
+	},
 
-		return err
+	Init: func(cmd *cmd.Command) {
+		addWaitFlags(cmd, 30*time.Second)
+		addJobsFlag(cmd)
+		cmd.Flags.Bool("force", false, "Kill servers that do not stop within the timeout")
 	},
 }
 
@@ -72,7 +169,7 @@ var versionCmd = cmd.Command{
 	Description: "This command will show the version of the tool.",
 	SkipStable:  true,
 	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
-		fmt.Printf("%s version %s\n", os.Args[0], version)
+		fmt.Fprintf(ctx.Out, "%s version %s\n", os.Args[0], version)
 		return nil
 	},
 }
@@ -80,4 +177,5 @@ var versionCmd = cmd.Command{
 func init() {
 	context.RegisterCommand([]string{"init"}, &initCmd)
 	context.RegisterCommand([]string{"version"}, &versionCmd)
+	context.RegisterCommand([]string{"destroy"}, &destroyCmd)
 }
@@ -17,32 +17,81 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"mysqld/cmd"
-	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"text/tabwriter"
 )
 
+var (
+	ErrNoDistPath = errors.New("No distribution path or URL provided")
+	ErrNoDistName = errors.New("No distribution name provided")
+)
+
 var addDistCmd = cmd.Command{
 	Brief: "Add a distribution to the stable",
 
 	Description: `A distribution will be added to the stable using an
-	archive of a binary distribution. Either a tar file (gzipped or not), a
-	zip file, or an unpacked binary distribution can be used. If a directory
-	is given, a symlink will be created that point to the directory.`,
+	archive of a binary distribution. Either a tar file (uncompressed or
+	compressed with gzip, bzip2, or xz), a zip file, or an unpacked binary
+	distribution can be used. If a directory is given, a symlink will be
+	created that point to the directory. Unpacking xz archives require
+	the xz program. If an HTTP or HTTPS URL is given, the archive is
+	downloaded before it is added.
+
+        The distribution is named after the directory or archive
+        unless -name is given. It is an error to add a distribution
+        with the same name as an existing one.
+
+        If -sha256 or -md5 is given, the digest of the archive is
+        verified against the value given and the distribution is not
+        added if it does not match.`,
 
-	Synopsis: "add distribution PATH",
+	Synopsis: "add distribution { PATH | URL }",
 	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
-		_, err := ctx.Stable.AddDist(args[0])
+		if len(args) == 0 {
+			return ErrNoDistPath
+		} else if len(args) > 1 {
+			return ErrTooManyArgs
+		}
+		name := cmd.Flags.Lookup("name").Value.String()
+		sha256 := cmd.Flags.Lookup("sha256").Value.String()
+		md5 := cmd.Flags.Lookup("md5").Value.String()
+
+		var err error
+		switch {
+		case len(sha256) > 0 && len(md5) > 0:
+			return fmt.Errorf("Only one of -sha256 and -md5 can be given")
+		case len(sha256) > 0:
+			_, err = ctx.Stable.AddDistNamedChecksum(args[0], name, "sha256", sha256)
+		case len(md5) > 0:
+			_, err = ctx.Stable.AddDistNamedChecksum(args[0], name, "md5", md5)
+		default:
+			_, err = ctx.Stable.AddDistNamed(args[0], name)
+		}
 		return err
 	},
 
 	Init: func(cmd *cmd.Command) {
 		cmd.Flags.String("name", "",
 			"Name of distribution, if different from directory name")
+		cmd.Flags.String("sha256", "", "Expected SHA-256 digest of the archive")
+		cmd.Flags.String("md5", "", "Expected MD5 digest of the archive")
 	},
 }
 
+// distRecord is the information about a distribution that is
+// printed by 'distribution show'.
+type distRecord struct {
+	Name          string `json:"name"`
+	Version       string `json:"version"`
+	ServerVersion string `json:"server_version"`
+	Size          *int64 `json:"size,omitempty"`
+}
+
 var showDistCmd = cmd.Command{
 	Brief: "Show information about distributions",
 
@@ -51,33 +100,211 @@ var showDistCmd = cmd.Command{
 	--version', and the 'VERSION' is retrieved from the include file. In
 	some cases, different builds can produce a server version that contain
 	extra information, but the version is the base version of the server,
-	regardless of build options.`,
+	regardless of build options.
+
+        The list is printed as a table by default. If -format is
+        'json', a JSON array with one object for each distribution is
+        printed instead, and if -format is 'csv', a header line
+        followed by one line for each distribution is printed.`,
 
-	Synopsis: "show distributions",
+	Synopsis: "[ OPTION ]",
+	ReadOnly: true,
 	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
-		tw := tabwriter.NewWriter(os.Stdout, 8, 0, 2, ' ', tabwriter.AlignRight)
-		fmt.Fprintf(tw, "%s\t%s\t%s\t\n", "NAME", "VERSION", "SERVER VERSION")
+		showSize := cmd.Flags.Lookup("size").Value.String() == "true"
+		followLinks := cmd.Flags.Lookup("follow-links").Value.String() == "true"
+
+		header := []string{"NAME", "VERSION", "SERVER VERSION"}
+		if showSize {
+			header = append(header, "SIZE")
+		}
+		rows := [][]string{}
+		records := []distRecord{}
 		for _, dist := range ctx.Stable.Distro {
-			fmt.Fprintf(tw,
-				"%s\t%s\t%s\t\n",
-				dist.Name, dist.Version, dist.ServerVersion)
+			record := distRecord{
+				Name:          dist.Name,
+				Version:       dist.Version,
+				ServerVersion: dist.ServerVersion,
+			}
+			row := []string{record.Name, record.Version, record.ServerVersion}
+			if showSize {
+				size, err := dist.InstallSize(followLinks)
+				if err != nil {
+					return err
+				}
+				record.Size = &size
+				row = append(row, strconv.FormatInt(size, 10))
+			}
+			rows = append(rows, row)
+			records = append(records, record)
+		}
+		return printRecords(ctx.Out, cmd, header, rows, records)
+	},
+
+	Init: func(cmd *cmd.Command) {
+		cmd.Flags.Bool("size", false, "Show the install size of each distribution")
+		cmd.Flags.Bool("follow-links", false, "Count the size of files that symbolic links refer to")
+		addFormatFlag(cmd)
+	},
+}
+
+var verifyDistCmd = cmd.Command{
+	Brief: "Verify that a distribution is usable",
+
+	Description: `Check that the files of the distribution are
+	still in place and that it can be used to create servers. This
+	will also clear the cached install size of the distribution,
+	so that it is computed again the next time it is shown. The
+	distribution is matched the same way as for 'distribution
+	tools'.`,
+
+	Synopsis: "[ NAME ]",
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) > 1 {
+			return ErrTooManyArgs
+		}
+
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		dist, err := findDist(ctx.Stable, name)
+		if err != nil {
+			return err
+		}
+		return dist.Verify()
+	},
+}
+
+var toolsDistCmd = cmd.Command{
+	Brief: "Show the tools available in a distribution",
+
+	Description: `Show all the executables available in the
+	distribution, such as 'mysql', 'mysqladmin', and
+	'mysqldump'. The distribution is matched the same way as for
+	the -dist option of 'server add', that is, the provided NAME
+	can be any unambigous substring of the distribution name. If
+	no NAME is given, the only distribution in the stable is used.`,
+
+	Synopsis: "[ NAME ]",
+	ReadOnly: true,
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) > 1 {
+			return ErrTooManyArgs
+		}
+
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		dist, err := findDist(ctx.Stable, name)
+		if err != nil {
+			return err
+		}
+
+		binaries, err := dist.Binaries()
+		if err != nil {
+			return err
+		}
+		for _, name := range binaries {
+			fmt.Fprintln(ctx.Out, name)
 		}
-		tw.Flush()
 		return nil
 	},
 }
 
+var serversDistCmd = cmd.Command{
+	Brief: "Show the servers using distributions",
+
+	Description: `Show all the servers that are created from the
+	distribution, together with the status of each server. This is
+	useful to check before removing a distribution, since removing
+	it will also remove all the servers using it. The distribution
+	is matched the same way as for 'distribution tools'.
+
+        If no NAME is given, all distributions are shown, each
+        followed by the servers using it.`,
+
+	Synopsis: "[ NAME ]",
+	ReadOnly: true,
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) > 1 {
+			return ErrTooManyArgs
+		}
+
+		tw := tabwriter.NewWriter(ctx.Out, 8, 0, 2, ' ', 0)
+		if len(args) > 0 {
+			dist, err := findDist(ctx.Stable, args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(tw, "%s\t%s\t\n", "NAME", "STATUS")
+			for _, srv := range ctx.Stable.ServersUsingDist(dist) {
+				fmt.Fprintf(tw, "%s\t%s\t\n", srv.Name, srv.Status())
+			}
+			return tw.Flush()
+		}
+
+		names := []string{}
+		for name := range ctx.Stable.Distro {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(tw, "%s:\t\t\n", name)
+			for _, srv := range ctx.Stable.ServersUsingDist(ctx.Stable.Distro[name]) {
+				fmt.Fprintf(tw, "  %s\t%s\t\n", srv.Name, srv.Status())
+			}
+		}
+		return tw.Flush()
+	},
+}
+
 var removeDistCmt = cmd.Command{
-	Brief: "Remove a distribution from the stable",
+	Brief: "Remove distributions from the stable",
+
+	Description: `All distributions matching any of the provided
+	patterns will be completely removed from the stable, including
+	all servers that are based on those distributions. Use
+	'distribution servers' to see the servers that will be removed.
 
-	Description: `The distribution will be completely removed from
-	the stable, including all servers that are based on that
-	distribution.`,
+        The distributions and the servers that will be removed are
+        printed and you are asked to confirm before anything is
+        removed, unless the global option -yes is given.`,
 
-	Synopsis: "NAME",
+	Synopsis: "PATTERN ...",
 	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
-		// Remove the distribution
-		return ctx.Stable.DelDistByName(args[0])
+		if len(args) == 0 {
+			return ErrNoDistName
+		}
+
+		dists, err := ctx.Stable.FindMatchingDists(args)
+		if err != nil {
+			return err
+		} else if len(dists) == 0 {
+			return fmt.Errorf("No distributions matching %q", strings.Join(args, " "))
+		}
+
+		// Show what will be removed, including the servers
+		// using the distributions, before asking.
+		items := []string{}
+		for _, dist := range dists {
+			items = append(items, "distribution "+dist.Name)
+			for _, srv := range ctx.Stable.ServersUsingDist(dist) {
+				items = append(items, "server "+srv.Name)
+			}
+		}
+		if err := ctx.Confirm("The following will be removed:", items); err != nil {
+			return err
+		}
+
+		for _, dist := range dists {
+			if err := ctx.Stable.DelDistByName(dist.Name); err != nil {
+				return err
+			}
+		}
+		return nil
 	},
 }
 
@@ -90,4 +317,8 @@ func init() {
 	context.RegisterGroup([]string{"distribution"}, &distGrp)
 	context.RegisterCommand([]string{"distribution", "add"}, &addDistCmd)
 	context.RegisterCommand([]string{"distribution", "show"}, &showDistCmd)
+	context.RegisterCommand([]string{"distribution", "remove"}, &removeDistCmt)
+	context.RegisterCommand([]string{"distribution", "servers"}, &serversDistCmd)
+	context.RegisterCommand([]string{"distribution", "tools"}, &toolsDistCmd)
+	context.RegisterCommand([]string{"distribution", "verify"}, &verifyDistCmd)
 }
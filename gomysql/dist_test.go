@@ -0,0 +1,40 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package main
+
+import (
+	"mysqld/cmd"
+	"testing"
+)
+
+func TestDistArgs(t *testing.T) {
+	ctx := cmd.NewContext("Test", "Test")
+	tests := []struct {
+		command *cmd.Command
+		args    []string
+		err     error
+	}{
+		{&addDistCmd, nil, ErrNoDistPath},
+		{&addDistCmd, []string{"first.tar.gz", "second.tar.gz"}, ErrTooManyArgs},
+		{&removeDistCmt, nil, ErrNoDistName},
+	}
+	for _, test := range tests {
+		if err := test.command.Body(ctx, test.command, test.args); err != test.err {
+			t.Errorf("Arguments %q: expected error %v, got %v", test.args, test.err, err)
+		}
+	}
+}
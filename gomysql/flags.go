@@ -0,0 +1,114 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mysqld/cmd"
+	"mysqld/stable"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// addWaitFlags will add the options common to all commands that wait
+// for servers to change state: the time to wait before giving up and
+// the interval between checks.
+func addWaitFlags(cmd *cmd.Command, timeout time.Duration) {
+	cmd.Flags.Duration("timeout", timeout, "Time to wait for servers")
+	cmd.Flags.Duration("poll-interval", stable.DEFAULT_POLL_INTERVAL,
+		"Interval between checks when waiting for servers")
+}
+
+// waitOptions will return the timeout and poll interval given to a
+// command using the options added with addWaitFlags.
+func waitOptions(cmd *cmd.Command) (timeout, interval time.Duration, err error) {
+	timeout, err = time.ParseDuration(cmd.Flags.Lookup("timeout").Value.String())
+	if err != nil {
+		return
+	}
+	interval, err = time.ParseDuration(cmd.Flags.Lookup("poll-interval").Value.String())
+	return
+}
+
+// addJobsFlag will add the option to set the number of servers that
+// a command handle in parallel.
+func addJobsFlag(cmd *cmd.Command) {
+	cmd.Flags.Int("jobs", runtime.NumCPU(), "Number of servers to handle in parallel")
+}
+
+// jobsOption will return the number of servers to handle in parallel
+// given to a command using the option added with addJobsFlag.
+func jobsOption(cmd *cmd.Command) (int, error) {
+	return strconv.Atoi(cmd.Flags.Lookup("jobs").Value.String())
+}
+
+// addOutputFlag will add the option to write the output of a command
+// to a file as well as to the terminal.
+func addOutputFlag(cmd *cmd.Command) {
+	cmd.Flags.String("output-file", "", "Append the output to the file as well")
+}
+
+// openOutput will return the writer to use for the output of a
+// command using the option added with addOutputFlag, together with
+// the closer to call when the command is done.
+func openOutput(ctx *cmd.Context, command *cmd.Command) (io.Writer, io.Closer, error) {
+	path := command.Flags.Lookup("output-file").Value.String()
+	if len(path) == 0 {
+		return ctx.Out, ioutil.NopCloser(nil), nil
+	}
+	return cmd.TeeFile(ctx.Out, path)
+}
+
+// addFormatFlag will add the option to select the format used when a
+// command print a list of items.
+func addFormatFlag(cmd *cmd.Command) {
+	cmd.Flags.String("format", "table", "Output format: table, json, or csv")
+}
+
+// printRecords will print the items to the writer in the format given
+// to the command using the option added with addFormatFlag. The
+// header and rows are used for the table and CSV formats, while the
+// records, which should be a slice with one element for each row, are
+// used for the JSON format.
+func printRecords(w io.Writer, command *cmd.Command, header []string, rows [][]string, records interface{}) error {
+	switch format := command.Flags.Lookup("format").Value.String(); format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 8, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(tw, "%s\t\n", strings.Join(header, "\t"))
+		for _, row := range rows {
+			fmt.Fprintf(tw, "%s\t\n", strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	case "json":
+		return json.NewEncoder(w).Encode(records)
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+		return writer.WriteAll(rows)
+	default:
+		return fmt.Errorf("Unknown output format %q", format)
+	}
+}
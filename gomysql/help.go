@@ -17,9 +17,11 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"mysqld/cmd"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -32,10 +34,22 @@ var helpCmd = cmd.Command{
 	printed.
 
         If no arguments are provided at all, this help message is
-        shown.`,
+        shown.
 
-	Synopsis: "WORD ...",
+        If -format is given, documentation for all commands and
+        groups is generated instead. The format can be either
+        'markdown' or 'man'.`,
+
+	Synopsis: "[ OPTION ] WORD ...",
+	ReadOnly: true,
 	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if format := cmd.Flags.Lookup("format").Value.String(); len(format) > 0 {
+			if len(args) > 0 {
+				return ErrTooManyArgs
+			}
+			return ctx.WriteDocs(ctx.Out, filepath.Base(os.Args[0]), format)
+		}
+
 		// If no arguments were given, we show help on "help"
 		if len(args) == 0 {
 			args = []string{"help"}
@@ -48,11 +62,41 @@ var helpCmd = cmd.Command{
 			return fmt.Errorf("Extreneous arguments: %q", cmds)
 		}
 
-		node.PrintHelp(os.Stdout)
+		node.PrintHelp(ctx.Out)
 		return nil
 	},
+
+	Init: func(cmd *cmd.Command) {
+		cmd.Flags.String("format", "", "Generate documentation in the format")
+	},
+}
+
+var completionCmd = cmd.Command{
+	Brief: "Generate a shell completion script",
+
+	Description: `Write a completion script for the shell, which can
+	be either 'bash' or 'zsh', to standard output. The script will
+	complete the commands and groups, the options of each command,
+	and the names of the servers in the stable.
+
+        To enable completion in the current shell, use for example:
+
+            source <(gomysql completion bash)`,
+
+	Synopsis:   "{ bash | zsh }",
+	ReadOnly:   true,
+	SkipStable: true,
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) == 0 {
+			return fmt.Errorf("command 'completion' require SHELL")
+		} else if len(args) > 1 {
+			return ErrTooManyArgs
+		}
+		return ctx.WriteCompletion(ctx.Out, filepath.Base(os.Args[0]), args[0], flag.CommandLine)
+	},
 }
 
 func init() {
 	context.RegisterCommand([]string{"help"}, &helpCmd)
+	context.RegisterCommand([]string{"completion"}, &completionCmd)
 }
@@ -67,8 +67,9 @@
 // to write the 'server add' command if you want to and multiple
 // servers, there is a -count option to the 'server add' command. When
 // the -count option is used, the name provided last ('slave.' in this
-// case) is used as a prefix to the server names, so this command will
-// create servers 'slave.1', ..., 'slave.10':
+// case) is used as a prefix to the server names, and the numbers are
+// padded with zeroes so that the names sort in order. This command will
+// create servers 'slave.01', ..., 'slave.10':
 //
 //    gomysql server add -dist=5.6.14 -count=10 slave.
 //
@@ -82,6 +83,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	_ "github.com/go-sql-driver/mysql"
 	"mysqld/cmd"
 	"mysqld/log"
 	"os"
@@ -90,6 +92,8 @@ import (
 
 var flagRoot string
 var flagLevel int
+var flagYes bool
+var flagOutput string
 
 var brief = "Utility for managing a stable of MySQL servers"
 
@@ -114,8 +118,19 @@ func main() {
 	} else {
 		prog := filepath.Base(os.Args[0])
 		context.RootDir = flagRoot
+		context.AssumeYes = flagYes
 		log.SetPriority(log.Priority(flagLevel))
 
+		if len(flagOutput) > 0 {
+			file, err := os.Create(flagOutput)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", prog, err)
+				os.Exit(2)
+			}
+			defer file.Close()
+			context.Out = file
+		}
+
 		if err := context.RunCommand(args); err != nil {
 			fmt.Fprintf(os.Stderr, "%s: %s\n", prog, err)
 			os.Exit(2)
@@ -134,5 +149,9 @@ func usage() {
 func init() {
 	flag.Usage = usage
 	flag.StringVar(&flagRoot, "root", ".", "Root directory for stable")
-	flag.IntVar(&flagLevel, "level", log.PRIORITY_WARNING, "Logging level (0: error, 1: warnings, 2: info, 3: debug)")
+	flag.StringVar(&flagOutput, "output", "", "Write the output of the command to the file")
+	flag.StringVar(&flagOutput, "o", "", "Same as -output")
+	flag.BoolVar(&flagYes, "yes", false, "Do not ask for confirmation before removing anything")
+	flag.BoolVar(&flagYes, "f", false, "Same as -yes")
+	flag.IntVar(&flagLevel, "level", log.PRIORITY_WARNING, "Logging level (-1: fatal, 0: error, 1: warnings, 2: info, 3: debug)")
 }
@@ -17,17 +17,24 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"mysqld/cmd"
 	"mysqld/log"
 	"mysqld/stable"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
-	"syscall"
+	"sync"
 	"text/tabwriter"
+	"time"
 )
 
 var (
@@ -57,10 +64,22 @@ var fmtServerCmd = cmd.Command{
         Server structure. For example, the string '{Host}:{Port}' will
         generate a host-port pair for each server.
 
+        Fields of nested structures are given as a dotted path, for
+        example '{Dist.ServerVersion}', and methods that take no
+        arguments can be used as well, for example '{Status}'.
+
+        If -template is given, FMT is instead a Go text/template with
+        the fields of the server, '.Status', and '.IsLocal' as data,
+        which allows conditionals and formatting, for example
+        '{{.Host}}:{{printf "%05d" .Port}}' or
+        '{{if .IsLocal}}{{.Socket}}{{end}}'. Methods of the server
+        cannot be used in templates.
+
         Each server produces a single line, so keep that in mind when
         you write your scripts.`,
 
-	Synopsis: "FMT [PATTERN ...]",
+	Synopsis: "[ OPTION ] FMT [PATTERN ...]",
+	ReadOnly: true,
 	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
 		if len(args) == 0 {
 			return ErrNoFormatString
@@ -75,17 +94,30 @@ var fmtServerCmd = cmd.Command{
 		}
 
 		// Generate the strings
+		useTemplate := cmd.Flags.Lookup("template").Value.String() == "true"
 		for _, srv := range servers {
-			fmt.Println(srv.FormatString(args[0]))
+			if !useTemplate {
+				fmt.Fprintln(ctx.Out, srv.FormatString(args[0]))
+				continue
+			}
+			str, err := srv.FormatTemplate(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(ctx.Out, str)
 		}
 
 		return nil
 	},
+
+	Init: func(cmd *cmd.Command) {
+		cmd.Flags.Bool("template", false, "Use Go template syntax for the format")
+	},
 }
 
 var addServerCmd = cmd.Command{
 	Brief:    "Add a server to the stable",
-	Synopsis: "NAME",
+	Synopsis: "[ OPTION ] NAME",
 
 	Description: `This command will create one or more new server using a
 	previously added distribution and add it to the stable.
@@ -95,10 +127,33 @@ var addServerCmd = cmd.Command{
 	distribution matching, an error will be returned. The default for the
 	distribution is the empty string, which will pick every available
 	distribution, which is convenient if you have only one distribution.
+	If -glob is given, the value to -dist is instead a glob pattern that
+	has to match exactly one distribution, for example 'mysql-5.6.*'.
 
         If a value to -count is given, that number of servers are created from
         the distribution. The name given for the server is then a prefix rather
-        than an absolute name.`,
+        than an absolute name. The number after the prefix is padded with
+        zeroes to the width of the largest number, so that the names sort in
+        order, for example 'slave.01' to 'slave.10'. Use -width to pad to a
+        larger width, for example when more servers will be added later.
+
+        If -option is given, the option is set in the configuration
+        file of the created servers, taking precedence over the
+        generated options. The option is given as
+        SECTION.OPTION=VALUE, or SECTION.OPTION for options that do
+        not take a value, and -option can be given several times. For
+        example, '-option mysqld.log-bin=master-bin'.
+
+        If -password is given, it is set as the password of the root
+        user when the servers are bootstrapped and used when
+        connecting to the servers. The password is not written to the
+        configuration files of the servers. A password of the form
+        env:NAME or file:PATH is read from the environment variable
+        or file instead of being stored in the stable.
+
+        If -print is given, the name, port, server id, and socket of
+        each created server is printed, as a table or, if -json is
+        given as well, as one JSON object for each server.`,
 
 	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
 		distFlag := cmd.Flags.Lookup("dist")
@@ -115,78 +170,212 @@ var addServerCmd = cmd.Command{
 			return err
 		}
 
-		// Figure out the candidates for distributions
-		candidates := []*stable.Dist{}
-		for key, dist := range ctx.Stable.Distro {
-			if strings.Contains(key, distFlag.Value.String()) {
-				candidates = append(candidates, dist)
-			}
+		var dist *stable.Dist
+		if cmd.Flags.Lookup("glob").Value.String() == "true" {
+			dist, err = findDistMatching(ctx.Stable, distFlag.Value.String())
+		} else {
+			dist, err = findDist(ctx.Stable, distFlag.Value.String())
+		}
+		if err != nil {
+			return err
 		}
 
-		if len(candidates) == 0 {
-			return fmt.Errorf("No distribution containing %q", distFlag.Value.String())
-		} else if len(candidates) > 1 {
-			return fmt.Errorf("Ambigous choice.")
+		optionFlag := cmd.Flags.Lookup("option").Value.(*stringList)
+		changes := make([]*stable.OptionChange, len(*optionFlag))
+		for i, str := range *optionFlag {
+			change, err := stable.ParseOptionChange(str)
+			if err != nil {
+				return err
+			}
+			changes[i] = change
 		}
+		password := cmd.Flags.Lookup("password").Value.String()
 
-		dist := candidates[0]
+		width, err := strconv.Atoi(cmd.Flags.Lookup("width").Value.String())
+		if err != nil {
+			return err
+		}
 
 		// Build a list of server names to construct
 		servers := []string{}
 		if count == 0 {
 			servers = append(servers, args[0])
 		} else if count > 0 {
+			if digits := len(strconv.Itoa(count)); width < digits {
+				width = digits
+			}
 			for i := 1; i <= count; i++ {
-				servers = append(servers, fmt.Sprintf("%s%d", args[0], i))
+				servers = append(servers, fmt.Sprintf("%s%0*d", args[0], width, i))
 			}
 		}
 
 		// Create the servers
+		created := []*stable.Server{}
 		for _, name := range servers {
 			// TODO How to handle multiple errors from servers.
-			if _, err := ctx.Stable.AddServer(name, dist); err != nil {
+			srv, err := ctx.Stable.AddServerOptions(name, dist, password, changes)
+			if err != nil {
 				return fmt.Errorf("Unable to create server %s: %s", name, err.Error())
 			}
+			created = append(created, srv)
+		}
+
+		if cmd.Flags.Lookup("print").Value.String() == "true" {
+			return printServerInfo(ctx.Out, created, cmd.Flags.Lookup("json").Value.String() == "true")
 		}
 		return nil
 	},
 
 	Init: func(cmd *cmd.Command) {
 		cmd.Flags.String("dist", "", "Distribution to create the server from")
+		cmd.Flags.Bool("glob", false, "Match the distribution using a glob pattern")
 		cmd.Flags.Uint("count", 0, "Number of instances to create")
+		cmd.Flags.Uint("width", 0, "Minimum number of digits in the names of the instances")
+		cmd.Flags.Bool("print", false, "Print the details of the created servers")
+		cmd.Flags.Bool("json", false, "Print the details as JSON")
+		cmd.Flags.Var(&stringList{}, "option", "Option to set for the servers (can be repeated)")
+		cmd.Flags.String("password", "", "Password of the root user")
+	},
+}
+
+// stringList is a flag value that collect the values of a flag
+// given several times.
+type stringList []string
+
+func (list *stringList) String() string {
+	return strings.Join(*list, ",")
+}
+
+func (list *stringList) Set(value string) error {
+	*list = append(*list, value)
+	return nil
+}
+
+var adoptServerCmd = cmd.Command{
+	Brief:    "Adopt an existing server into the stable",
+	Synopsis: "[ OPTION ] NAME",
+
+	Description: `This command will add an existing server, which
+	was not created by the stable, to the stable under the given
+	name. The server will not be bootstrapped and the data
+	directory of the server will be left untouched, also when the
+	server is removed from the stable.
+
+        The options of the server are read from the configuration
+        file given with -config. If -datadir, -socket, or -port is not
+        given, the values are taken from the "mysqld" section of the
+        configuration file.
+
+        The server is associated with the distribution given by
+        -dist, which is matched the same way as for 'server add'.`,
+
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) == 0 {
+			return ErrNoServerName
+		} else if len(args) > 1 {
+			return ErrTooManyArgs
+		}
+
+		config := cmd.Flags.Lookup("config").Value.String()
+		if len(config) == 0 {
+			return fmt.Errorf("No configuration file given with -config")
+		}
+
+		port, err := strconv.Atoi(cmd.Flags.Lookup("port").Value.String())
+		if err != nil {
+			return err
+		}
+
+		dist, err := findDist(ctx.Stable, cmd.Flags.Lookup("dist").Value.String())
+		if err != nil {
+			return err
+		}
+
+		_, err = ctx.Stable.AdoptServer(args[0], dist, config,
+			cmd.Flags.Lookup("datadir").Value.String(),
+			cmd.Flags.Lookup("socket").Value.String(), port)
+		return err
+	},
+
+	Init: func(cmd *cmd.Command) {
+		cmd.Flags.String("dist", "", "Distribution to associate the server with")
+		cmd.Flags.String("config", "", "Configuration file of the server")
+		cmd.Flags.String("datadir", "", "Data directory of the server")
+		cmd.Flags.String("socket", "", "Socket of the server")
+		cmd.Flags.Uint("port", 0, "Port of the server")
 	},
 }
 
 var removeServerCmd = cmd.Command{
 	Brief: "Remove a server from the stable",
 
-	Description: `All servers matching the provided pattern will
-	be removed from the stable and all associated files
+	Description: `All servers matching any of the provided patterns
+	will be removed from the stable and all associated files
 	removed. Before the servers are removed, they will be
-	stopped.`,
+	stopped. If a server does not stop within the time given by
+	-timeout, it will not be removed.
+
+        The servers that will be removed are printed and you are asked
+        to confirm before anything is removed, unless the global
+        option -yes is given.`,
 
 	Synopsis: "PATTERN ...",
 	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
-		if len(args) > 1 {
-			return ErrTooManyArgs
-		} else if len(args) == 0 {
+		if len(args) == 0 {
 			return ErrNoServerName
 		}
 
+		timeout, interval, err := waitOptions(cmd)
+		if err != nil {
+			return err
+		}
+
 		// Find matching servers
-		servers, err := ctx.Stable.FindMatchingServers(args[:])
+		servers, err := ctx.Stable.FindMatchingServers(args)
 		if err != nil {
 			return err
 		} else if len(servers) == 0 {
-			return fmt.Errorf("No servers matching %q", args[0])
+			return fmt.Errorf("No servers matching %q", strings.Join(args, " "))
 		}
 
-		// TODO How to handle multiple errors from servers.
-		for _, srv := range servers {
-			ctx.Stable.DelServer(srv)
+		names := make([]string, len(servers))
+		for i, srv := range servers {
+			names[i] = srv.Name
 		}
-		return nil
+		if err := ctx.Confirm("The following servers will be removed:", names); err != nil {
+			return err
+		}
+
+		// Remove the servers one at a time, since removing a
+		// server changes the stable, and collect the errors of
+		// the servers that could not be removed.
+		return stable.ForEach(servers, 1, func(srv *stable.Server) error {
+			srv.SetPollInterval(interval)
+			if srv.Status() == stable.SERVER_RUNNING {
+				if err := srv.Stop(); err != nil {
+					return err
+				}
+				if err := srv.WaitStopped(timeout); err != nil {
+					return err
+				}
+			}
+			return ctx.Stable.DelServer(srv)
+		})
 	},
+
+	Init: func(cmd *cmd.Command) {
+		addWaitFlags(cmd, 30*time.Second)
+	},
+}
+
+// serverRecord is the information about a server that is printed
+// by 'server show'.
+type serverRecord struct {
+	Name    string `json:"name"`
+	Host    string `json:"host"`
+	Port    int    `json:"port"`
+	Version string `json:"version"`
+	Status  string `json:"status"`
 }
 
 var showServersCmd = cmd.Command{
@@ -196,254 +385,1355 @@ var showServersCmd = cmd.Command{
 	stable is shown together with the status. The version shown is
 	retrieved from the server version string shown when using
 	'mysqld --version' and is extracted when the server is
-	created.`,
+	created.
+
+        The list is printed as a table by default. If -format is
+        'json', a JSON array with one object for each server is
+        printed instead, and if -format is 'csv', a header line
+        followed by one line for each server is printed.`,
 
+	Synopsis: "[ OPTION ]",
+	ReadOnly: true,
 	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
 		if len(args) > 0 {
 			return ErrTooManyArgs
 		}
 
-		tw := tabwriter.NewWriter(os.Stdout, 8, 0, 2, ' ', tabwriter.AlignRight)
-		fmt.Fprintf(tw,
-			"%s\t%s\t%s\t%s\t%s\t\n",
-			"NAME", "HOST", "PORT", "VERSION", "STATUS")
+		header := []string{"NAME", "HOST", "PORT", "VERSION", "STATUS"}
+		rows := [][]string{}
+		records := []serverRecord{}
 		for _, srv := range ctx.Stable.Server {
-			fmt.Fprintf(tw,
-				"%s\t%s\t%d\t%s\t%s\t\n",
-				srv.Name, srv.Host, srv.Port,
-				srv.Dist.ServerVersion, srv.Status())
+			record := serverRecord{
+				Name:    srv.Name,
+				Host:    srv.Host,
+				Port:    srv.Port,
+				Version: srv.Dist.ServerVersion,
+				Status:  srv.Status().String(),
+			}
+			rows = append(rows, []string{
+				record.Name, record.Host, strconv.Itoa(record.Port),
+				record.Version, record.Status,
+			})
+			records = append(records, record)
 		}
-		tw.Flush()
-		return nil
+		return printRecords(ctx.Out, cmd, header, rows, records)
+	},
+
+	Init: func(cmd *cmd.Command) {
+		addFormatFlag(cmd)
 	},
 }
 
-var startServerCmd = cmd.Command{
-	Brief: "Start a server",
+var statusServerCmd = cmd.Command{
+	Brief: "Show the status of servers",
 
-	Description: `All servers matching the provided will be started in the
-	background. If any options are provided in addition to the name, they
-	will be added to the list of options when starting the server.`,
+	Description: `The status of all servers matching any of the
+	patterns is shown. If no patterns are given, all servers in
+	the stable are shown.
 
-	Synopsis: "PATTERN OPTION ...",
+        If -require is given, the command fails unless all servers
+        matched are in the required state, which can be 'running',
+        'ready' (running and accepting connections), or 'stopped'. The
+        servers not in the state are printed. If -require-count is
+        given, the command also fails unless at least that many
+        servers matched. This is useful to check that the servers
+        are healthy before running tests.`,
+
+	Synopsis: "[ OPTION ] [ PATTERN ... ]",
+	ReadOnly: true,
 	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
 		if len(args) == 0 {
-			return ErrNoServerName
+			args = []string{"*"}
 		}
 
-		// Fetch the server from the stable
-		servers, err := ctx.Stable.FindMatchingServers(args[:1])
+		servers, err := ctx.Stable.FindMatchingServers(args)
 		if err != nil {
 			return err
-		} else if len(servers) == 0 {
-			return fmt.Errorf("No servers matching %q", args[0])
 		}
 
-		// TODO How to handle multiple errors from servers.
+		tw := tabwriter.NewWriter(ctx.Out, 8, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(tw, "%s\t%s\t\n", "NAME", "STATUS")
 		for _, srv := range servers {
-			// Check if the server is running, i.e., if there is a PID file
-			if srv.Status() == stable.SERVER_RUNNING {
-				return fmt.Errorf("Server %q already running", srv.Name)
-			}
+			fmt.Fprintf(tw, "%s\t%s\t\n", srv.Name, srv.Status())
+		}
+		tw.Flush()
 
-			// Time to do the daemonize fandango
-			argv := []string{
-				filepath.Base(srv.BinPath),
-				fmt.Sprintf("--defaults-file=%s", srv.ConfigFile),
+		count, err := strconv.Atoi(cmd.Flags.Lookup("require-count").Value.String())
+		if err != nil {
+			return err
+		}
+		state := cmd.Flags.Lookup("require").Value.String()
+		if len(state) == 0 {
+			if count > 0 {
+				state = stable.STATE_RUNNING
+			} else {
+				return nil
 			}
-			argv = append(argv, args[1:]...)
-			forkDaemon(srv.BinPath, srv.BaseDir, srv.LogPath, argv)
 		}
-		return nil
+		return stable.RequireState(servers, state, count)
+	},
+
+	Init: func(cmd *cmd.Command) {
+		cmd.Flags.String("require", "", "Fail unless all servers are in the state")
+		cmd.Flags.Uint("require-count", 0, "Fail unless at least this number of servers match")
 	},
 }
 
-var stopServerCmd = cmd.Command{
-	Brief: "Stop a server",
+var logsServerCmd = cmd.Command{
+	Brief: "Show the error log of servers",
 
-	Description: `All servers matching the pattern will be stopped by
-	sending TERM (11) to it. This is the normal shutdown procedure for a
-	graceful shutdown of a server, but it only work when done on the local
-	machine. If an attempt to shut down a server on a remote machine is
-	done, an error will currently be thrown.`,
+	Description: `The error log of each server matching any of the
+	patterns is printed, with each line prefixed by the name of the
+	server.
 
-	Synopsis: "PATTERN",
+        If -grep is given, only lines matching the regular expression
+        are printed. The match is case-insensitive.
+
+        If -since is given, only entries with a timestamp newer than
+        the given duration back in time are printed, for example
+        '-since=1h30m'. Lines in the log that do not start with a
+        timestamp are not printed when -since is used.
+
+        If -n is given, only the last lines selected are printed for
+        each server.
+
+        If -f is given, the error logs are followed and lines are
+        printed as they are written to the logs, until the command is
+        interrupted.
+
+        If -bootstrap is given, the bootstrap log is printed instead
+        of the error log. If a server failed to bootstrap when it was
+        added, the bootstrap log is saved and can be printed by giving
+        the name of the server. The bootstrap log cannot be followed.`,
+
+	Synopsis: "[ OPTION ] PATTERN ...",
+	ReadOnly: true,
 	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
 		if len(args) == 0 {
 			return ErrNoServerName
-		} else if len(args) > 1 {
-			return ErrTooManyArgs
 		}
 
-		// Fetch matching servers from the stable
-		servers, err := ctx.Stable.FindMatchingServers(args[:1])
+		filter := &stable.LogFilter{}
+		if str := cmd.Flags.Lookup("grep").Value.String(); len(str) > 0 {
+			re, err := regexp.Compile("(?i)" + str)
+			if err != nil {
+				return err
+			}
+			filter.Match = re
+		}
+		if str := cmd.Flags.Lookup("since").Value.String(); len(str) > 0 {
+			since, err := time.ParseDuration(str)
+			if err != nil {
+				return err
+			}
+			filter.Since = time.Now().Add(-since)
+		}
+		last, err := strconv.Atoi(cmd.Flags.Lookup("n").Value.String())
 		if err != nil {
 			return err
-		} else if len(servers) == 0 {
-			return fmt.Errorf("No servers matching %q", args[0])
 		}
+		filter.Last = last
+		follow := cmd.Flags.Lookup("f").Value.String() == "true"
 
-		// TODO How to handle multiple errors from servers.
-		for _, srv := range servers {
-			if !srv.IsLocal() {
-				return fmt.Errorf("Non-local server: server is at %s", srv.Host)
+		servers, err := ctx.Stable.FindMatchingServers(args)
+		if err != nil {
+			return err
+		}
+
+		if cmd.Flags.Lookup("bootstrap").Value.String() == "true" {
+			if follow {
+				return fmt.Errorf("The bootstrap log cannot be followed")
 			}
 
-			// TODO: Check that the server is local
-			if srv.Status() != stable.SERVER_RUNNING {
-				return fmt.Errorf("Server %s not running", srv.Name)
+			// Servers that failed to bootstrap are not in the
+			// stable, so use the names given in that case.
+			names := args
+			if len(servers) > 0 {
+				names = make([]string, len(servers))
+				for i, srv := range servers {
+					names[i] = srv.Name
+				}
+			}
+			for _, name := range names {
+				rd, err := ctx.Stable.BootstrapLogReader(name)
+				if err != nil {
+					return err
+				}
+				err = filter.Copy(ctx.Out, rd, name+": ")
+				rd.Close()
+				if err != nil {
+					return err
+				}
 			}
+			return nil
+		}
 
-			if pid, err := srv.Pid(); err != nil {
-				return fmt.Errorf("Server %s: %s", srv.Name, err)
-			} else {
-				syscall.Kill(pid, syscall.SIGTERM)
+		if len(servers) == 0 {
+			return fmt.Errorf("No servers matching %q", args)
+		}
+
+		if follow {
+			return followLogs(ctx.Out, servers, filter)
+		}
+
+		for _, srv := range servers {
+			rd, err := srv.LogReader()
+			if err != nil {
+				log.Warningf("Server %s: %s", srv.Name, err)
+				continue
+			}
+			err = filter.Copy(ctx.Out, rd, srv.Name+": ")
+			rd.Close()
+			if err != nil {
+				return err
 			}
 		}
 		return nil
 	},
+
+	Init: func(cmd *cmd.Command) {
+		cmd.Flags.String("grep", "", "Only show lines matching the regular expression")
+		cmd.Flags.String("since", "", "Only show entries newer than the duration")
+		cmd.Flags.Bool("bootstrap", false, "Show the bootstrap log instead of the error log")
+		cmd.Flags.Int("n", 0, "Only show the last lines of each log")
+		cmd.Flags.Bool("f", false, "Follow the logs as they grow")
+	},
 }
 
-var clientServerCmd = cmd.Command{
-	Brief: "Connect to a server as a client",
+// followLogs will print the error logs of the servers to the writer
+// and then keep printing lines as they are written to the logs, until
+// interrupted.
+func followLogs(w io.Writer, servers []*stable.Server, filter *stable.LogFilter) error {
+	stop := make(chan struct{})
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		<-interrupt
+		close(stop)
+	}()
+
+	// New lines are always printed, so only the existing content
+	// is limited to the last lines.
+	growing := *filter
+	growing.Last = 0
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(servers))
+	for _, srv := range servers {
+		rd, err := srv.LogReader()
+		if err != nil {
+			log.Warningf("Server %s: %s", srv.Name, err)
+			continue
+		}
+		prefix := srv.Name + ": "
+		if err := filter.Copy(w, rd, prefix); err != nil {
+			rd.Close()
+			return err
+		}
+		wg.Add(1)
+		go func(rd io.ReadCloser) {
+			defer wg.Done()
+			defer rd.Close()
+			errs <- growing.Copy(w, stable.FollowLog(rd, stop), prefix)
+		}(rd)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	Description: `Command is used to connect to a server and
-	execute commands there.
+var startServerCmd = cmd.Command{
+	Brief: "Start a server",
+
+	Description: `All servers matching the provided will be started in the
+	background. If any options are provided in addition to the name, they
+	will be added to the list of options when starting the server.
+
+        If the server binary cannot be executed, the command fails
+        and the last lines of the error log of the server are shown.
+
+        If a server crashed and left its PID file behind, the PID file
+        is removed with a warning before the server is started. Servers
+        that are already running are not started again.
 
-        The command will open a prompt to that server.`,
+        If -wait is given, the command will wait for the PID file of
+        each server to appear and for each server to accept
+        connections, so that the servers can be used as soon as the
+        command returns. If mysqld wrote the PID file to the location
+        given in the options rather than where the stable expected
+        it, the stable is updated to use that file.
 
-	Synopsis: "[ OPTION ] SERVER",
+        Servers are started in parallel, with at most as many servers
+        handled at the same time as given by -jobs, which default to
+        the number of CPUs.
+
+        If -atomic is given, the command will wait for all servers to
+        accept connections. If any server fails to start or is not
+        ready within the timeout, all the servers that were started
+        are stopped again and the command fails, so that either all
+        servers are started or none of them.`,
+
+	Synopsis: "[ OPTION ] PATTERN OPTION ...",
 	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
-		// Find matching servers
-		servers, err := ctx.Stable.FindMatchingServers(args[0:1])
+		if len(args) == 0 {
+			return ErrNoServerName
+		}
+
+		// Fetch the server from the stable
+		servers, err := ctx.Stable.FindMatchingServers(args[:1])
 		if err != nil {
 			return err
 		} else if len(servers) == 0 {
 			return fmt.Errorf("No servers matching %q", args[0])
-		} else if len(servers) > 1 {
-			return fmt.Errorf("Pattern %q match more than one server", args[0])
 		}
 
-		log.Debugf("Found matching servers %v", servers)
+		timeout, interval, err := waitOptions(cmd)
+		if err != nil {
+			return err
+		}
+		jobs, err := jobsOption(cmd)
+		if err != nil {
+			return err
+		}
 
-		// Providing more than one server and not a command is
-		// not allowed. We don't support sending SQL to
-		// multiple servers using a command prompt (yet).
-		if len(args) == 1 && len(servers) > 1 {
-			return ErrTooManyServers
+		// A PID file left behind by a crashed server is removed
+		// so that waiting for the PID file does not find the old
+		// one. Running servers are refused by Start.
+		start := func(srv *stable.Server) error {
+			if removed, err := srv.RemoveStalePidFile(); err != nil {
+				return err
+			} else if removed {
+				log.Warningf("Server %s was not shut down cleanly: removed stale PID file", srv.Name)
+			}
+			srv.SetPollInterval(interval)
+			return srv.Start(args[1:]...)
 		}
 
-		return servers[0].Connect()
+		if cmd.Flags.Lookup("atomic").Value.String() == "true" {
+			stopped, err := stable.StartAtomic(servers, start, timeout)
+			for _, srv := range stopped {
+				fmt.Fprintf(ctx.Out, "Stopped %s\n", srv.Name)
+			}
+			return err
+		}
+
+		wait := cmd.Flags.Lookup("wait").Value.String() == "true"
+		return stable.ForEach(servers, jobs, func(srv *stable.Server) error {
+			if err := start(srv); err != nil {
+				return err
+			}
+			if wait {
+				if err := srv.WaitPidFile(timeout); err != nil {
+					return err
+				}
+				return srv.WaitReady(timeout)
+			}
+			return nil
+		})
 	},
 
 	Init: func(cmd *cmd.Command) {
-		cmd.Flags.String("database", "test", "Database to use when connecting")
+		cmd.Flags.Bool("wait", false, "Wait for the servers to accept connections")
+		cmd.Flags.Bool("atomic", false, "Stop all started servers if any server fails to start")
+		addWaitFlags(cmd, 30*time.Second)
+		addJobsFlag(cmd)
 	},
 }
 
-var executeServerCmd = cmd.Command{
-	Brief: "Connect to a server and execute commands",
+var stopServerCmd = cmd.Command{
+	Brief: "Stop a server",
 
-	Description: `Command is used to execute statements towards
-	one or more servers. The SQL provided on to the command will
-	be sent to all servers matching the pattern.
+	Description: `All servers matching the pattern will be stopped by
+	sending TERM (15) to it. This is the normal shutdown procedure for a
+	graceful shutdown of a server, but it only work when done on the local
+	machine. Servers on a remote machine are stopped using mysqladmin
+	shutdown instead.
 
-        The result set from the execution of each command will be
-        printed to the user.`,
+        The command waits for each server to stop. If a local server is
+        still running after the timeout, the command fails unless -force
+        is given, in which case the server is killed.
 
-	Synopsis: "[ OPTION ] PATTERN CMD ...",
+        Servers are stopped in parallel, with at most as many servers
+        handled at the same time as given by -jobs, which default to
+        the number of CPUs.`,
+
+	Synopsis: "[ OPTION ] PATTERN",
 	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
-		// Find matching servers
-		servers, err := ctx.Stable.FindMatchingServers(args[0:1])
+		if len(args) == 0 {
+			return ErrNoServerName
+		} else if len(args) > 1 {
+			return ErrTooManyArgs
+		}
+
+		// Fetch matching servers from the stable
+		servers, err := ctx.Stable.FindMatchingServers(args[:1])
 		if err != nil {
 			return err
 		} else if len(servers) == 0 {
 			return fmt.Errorf("No servers matching %q", args[0])
 		}
 
-		log.Debugf("Found matching servers %v", servers)
-
-		// Providing more than one server and not a command is
-		// not allowed. We don't support sending SQL to
-		// multiple servers using a command prompt (yet).
-		if len(args) == 1 && len(servers) > 1 {
-			return ErrTooManyServers
+		timeout, interval, err := waitOptions(cmd)
+		if err != nil {
+			return err
+		}
+		force := cmd.Flags.Lookup("force").Value.String() == "true"
+		jobs, err := jobsOption(cmd)
+		if err != nil {
+			return err
 		}
 
-		for _, srv := range servers {
-			fmt.Printf("\n%s> %s\n", srv.Name, strings.Join(args[1:], " "))
-			err := srv.Execute(args[1:]...)
-			if err != nil {
-				log.Errorf("Execute: %s", err)
+		return stable.ForEach(servers, jobs, func(srv *stable.Server) error {
+			if removed, err := srv.RemoveStalePidFile(); err != nil {
+				return err
+			} else if removed {
+				log.Warningf("Server %s had crashed: removed stale PID file", srv.Name)
+				return nil
 			}
-		}
-		return nil
+
+			if srv.Status() != stable.SERVER_RUNNING {
+				return fmt.Errorf("Server %s not running", srv.Name)
+			}
+
+			srv.SetPollInterval(interval)
+			return srv.Shutdown(timeout, force)
+		})
 	},
 
 	Init: func(cmd *cmd.Command) {
-		cmd.Flags.String("database", "test", "Database to use when connecting")
+		cmd.Flags.Bool("force", false, "Kill servers that do not stop within the timeout")
+		addWaitFlags(cmd, 30*time.Second)
+		addJobsFlag(cmd)
 	},
 }
 
-// forkDaemon will start a server as a daemon. The path to the binary
-// is given in binPath, the directory where the server should run is
-// given in runDir, and the path where the standard output and
-// standard error will be directed is given by outPath. Note that the
-// outPath will be opened in append mode, and created if it does not
-// exists.
-func forkDaemon(binPath, runDir, outPath string, argv []string) error {
-	pid, _, errno := syscall.RawSyscall(syscall.SYS_FORK, 0, 0, 0)
-	if errno != 0 {
-		return fmt.Errorf("Failed to fork: %s", errno.Error())
-	}
-
-	// Parent process just return.
-	if pid > 0 {
-		// TODO Do we need to check that the start succeeded? Create a
-		// pipe to communicate over then.
-		return nil
-	}
+var reloadServerCmd = cmd.Command{
+	Brief: "Reload the configuration and logs of a server",
 
-	// In child process
-	var file *os.File
-	var err error
+	Description: `All servers matching the pattern will be sent HUP (1),
+	which makes the server reload the grant tables and flush the
+	logs, tables, and caches. This can be used after rotating the log
+	files of a server. The server is not restarted, so options changed
+	in the configuration file do not take effect.
 
-	os.Chdir(runDir)
+        Only servers running on the local machine can be reloaded.`,
 
-	// Re-direct standard error and standard output to logfile
-	file, err = os.OpenFile(outPath, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
-	if err == nil {
-		fd := file.Fd()
-		syscall.Dup2(int(fd), int(os.Stdout.Fd()))
-		syscall.Dup2(int(fd), int(os.Stderr.Fd()))
-	} else {
-		return err
-	}
+	Synopsis: "PATTERN",
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) == 0 {
+			return ErrNoServerName
+		} else if len(args) > 1 {
+			return ErrTooManyArgs
+		}
+
+		servers, err := ctx.Stable.FindMatchingServers(args[:1])
+		if err != nil {
+			return err
+		} else if len(servers) == 0 {
+			return fmt.Errorf("No servers matching %q", args[0])
+		}
+
+		for _, srv := range servers {
+			if err := srv.Reload(); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var cloneServerCmd = cmd.Command{
+	Brief: "Create a new server with a copy of the data of a server",
+
+	Description: `Command will create a new server DST with a copy of
+	the data directory of server SRC, using the same distribution
+	and options. The new server is given a port, server id, socket,
+	and PID file of its own, and the server UUID is not copied, so
+	the clone can replicate with the original server.
+
+        If SRC is running, it is stopped before the data directory is
+        copied. It is not started again.`,
+
+	Synopsis:    "[ OPTION ] SRC DST",
+	SideEffects: true,
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) < 2 {
+			return ErrNoServerName
+		} else if len(args) > 2 {
+			return ErrTooManyArgs
+		}
 
-	// Re-direct standard input to /dev/null
-	file, err = os.OpenFile(os.DevNull, os.O_RDWR, 0)
-	if err == nil {
-		syscall.Dup2(int(file.Fd()), int(os.Stdin.Fd()))
-	} else {
+		src, err := findServer(ctx, args[0])
+		if err != nil {
+			return err
+		}
+
+		if src.Status() == stable.SERVER_RUNNING {
+			timeout, interval, err := waitOptions(cmd)
+			if err != nil {
+				return err
+			}
+			src.SetPollInterval(interval)
+			if err := src.Shutdown(timeout, false); err != nil {
+				return err
+			}
+		}
+
+		_, err = ctx.Stable.CloneServer(src, args[1])
 		return err
-	}
+	},
+
+	Init: func(cmd *cmd.Command) {
+		addWaitFlags(cmd, 30*time.Second)
+	},
+}
+
+var restartServerCmd = cmd.Command{
+	Brief: "Restart a server",
+
+	Description: `All local servers matching the pattern will be stopped
+	by sending TERM to them and started again once they have stopped. If
+	any options are provided in addition to the pattern, they will be
+	added to the list of options when starting the server, in the same
+	way as for start.
+
+        Servers that are not running are just started.`,
+
+	Synopsis: "[ OPTION ] PATTERN OPTION ...",
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) == 0 {
+			return ErrNoServerName
+		}
+
+		servers, err := ctx.Stable.FindMatchingServers(args[:1])
+		if err != nil {
+			return err
+		} else if len(servers) == 0 {
+			return fmt.Errorf("No servers matching %q", args[0])
+		}
+
+		timeout, interval, err := waitOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		for _, srv := range servers {
+			if !srv.IsLocal() {
+				return fmt.Errorf("Non-local server: server is at %s", srv.Host)
+			}
+		}
+
+		// TODO How to handle multiple errors from servers.
+		for _, srv := range servers {
+			srv.SetPollInterval(interval)
+			if err := srv.Restart(timeout, args[1:]...); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+
+	Init: func(cmd *cmd.Command) {
+		addWaitFlags(cmd, 30*time.Second)
+	},
+}
+
+var clientServerCmd = cmd.Command{
+	Brief: "Connect to a server as a client",
+
+	Description: `Command is used to connect to a server and
+	execute commands there.
+
+        The command will open a prompt to that server. If -database
+        is given, the prompt will use that database. If -output-file
+        is given, the session is written to the file as well.`,
+
+	Synopsis:    "[ OPTION ] SERVER",
+	SideEffects: true,
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		// Find matching servers
+		servers, err := ctx.Stable.FindMatchingServers(args[0:1])
+		if err != nil {
+			return err
+		} else if len(servers) == 0 {
+			return fmt.Errorf("No servers matching %q", args[0])
+		} else if len(servers) > 1 {
+			return fmt.Errorf("Pattern %q match more than one server", args[0])
+		}
+
+		log.Debugf("Found matching servers %v", servers)
+
+		// Providing more than one server and not a command is
+		// not allowed. We don't support sending SQL to
+		// multiple servers using a command prompt (yet).
+		if len(args) == 1 && len(servers) > 1 {
+			return ErrTooManyServers
+		}
+
+		servers[0].SetDatabase(cmd.Flags.Lookup("database").Value.String())
+
+		// The client is interactive, so let it write the
+		// output file itself.
+		if path := cmd.Flags.Lookup("output-file").Value.String(); len(path) > 0 {
+			return servers[0].Connect("--tee=" + path)
+		}
+		return servers[0].Connect()
+	},
+
+	Init: func(cmd *cmd.Command) {
+		cmd.Flags.String("database", "", "Database to use when connecting")
+		addOutputFlag(cmd)
+	},
+}
+
+var openServerCmd = cmd.Command{
+	Brief: "Open a server in a graphical client",
+
+	Description: `Command will open the connection URL of the
+	server using the program registered on the system for opening
+	URLs, that is 'xdg-open' or 'open', so that a MySQL GUI can
+	pick it up. If no such program is available, the URL is
+	printed instead.
+
+        The NAME have to match exactly one server.`,
+
+	Synopsis: "NAME",
+	ReadOnly: true,
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) == 0 {
+			return ErrNoServerName
+		} else if len(args) > 1 {
+			return ErrTooManyArgs
+		}
+
+		servers, err := ctx.Stable.FindMatchingServers(args)
+		if err != nil {
+			return err
+		} else if len(servers) == 0 {
+			return fmt.Errorf("No servers matching %q", args[0])
+		} else if len(servers) > 1 {
+			return fmt.Errorf("Pattern %q match more than one server", args[0])
+		}
+
+		err = servers[0].Open(stable.SystemLauncher)
+		if err == stable.ErrNoOpener {
+			fmt.Fprintln(ctx.Out, servers[0].URL())
+			return nil
+		}
+		return err
+	},
+}
+
+var executeServerCmd = cmd.Command{
+	Brief: "Connect to a server and execute commands",
+
+	Description: `Command is used to execute statements towards
+	one or more servers. The SQL provided on to the command will
+	be sent to all servers matching the pattern.
+
+        If -file is given, the statements are instead read from the
+        file, and if no SQL is provided, or the SQL is '-', the
+        statements are read from standard input. The statements are
+        sent to the standard input of the mysql client, so scripts
+        with several statements can be executed this way.
+
+        If -database is given, the statements are executed in that
+        database.
+
+        The result set from the execution of each command will be
+        printed to the user. If -output-file is given, the output is
+        written to the file as well.
+
+        The statements are executed on the servers in parallel, with
+        at most as many servers handled at the same time as given by
+        -jobs, which default to the number of CPUs. The output of each
+        server is printed when the server is done, so the output of
+        different servers is not mixed.`,
+
+	Synopsis:    "[ OPTION ] PATTERN [ CMD ... | - ]",
+	SideEffects: true,
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) == 0 {
+			return ErrNoServerName
+		}
+
+		// Find matching servers
+		servers, err := ctx.Stable.FindMatchingServers(args[0:1])
+		if err != nil {
+			return err
+		} else if len(servers) == 0 {
+			return fmt.Errorf("No servers matching %q", args[0])
+		}
+
+		log.Debugf("Found matching servers %v", servers)
+
+		database := cmd.Flags.Lookup("database").Value.String()
+		for _, srv := range servers {
+			srv.SetDatabase(database)
+		}
+
+		// Read the script once, if there is one, since it is
+		// sent to all the servers.
+		path := cmd.Flags.Lookup("file").Value.String()
+		var script []byte
+		switch {
+		case len(path) > 0 && len(args) > 1:
+			return fmt.Errorf("Statements cannot be given together with -file")
+		case len(path) > 0:
+			script, err = ioutil.ReadFile(path)
+		case len(args) == 1 || (len(args) == 2 && args[1] == "-"):
+			path = "-"
+			script, err = ioutil.ReadAll(os.Stdin)
+		}
+		if err != nil {
+			return err
+		}
+
+		jobs, err := jobsOption(cmd)
+		if err != nil {
+			return err
+		}
 
-	if err := syscall.Exec(binPath, argv, os.Environ()); err != nil {
+		out, closer, err := openOutput(ctx, cmd)
+		if err != nil {
+			return err
+		}
+		defer closer.Close()
+
+		// The output of each server is collected and written in
+		// one go, so that output of servers is not interleaved.
+		var mutex sync.Mutex
+		return stable.ForEach(servers, jobs, func(srv *stable.Server) error {
+			var buf bytes.Buffer
+			var err error
+			if len(path) > 0 {
+				fmt.Fprintf(&buf, "\n%s< %s\n", srv.Name, path)
+				err = srv.ExecuteReaderTo(&buf, &buf, bytes.NewReader(script))
+			} else {
+				fmt.Fprintf(&buf, "\n%s> %s\n", srv.Name, strings.Join(args[1:], " "))
+				err = srv.ExecuteTo(&buf, &buf, args[1:]...)
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			out.Write(buf.Bytes())
+			if err != nil {
+				return fmt.Errorf("Server %s: %s", srv.Name, err)
+			}
+			return nil
+		})
+	},
+
+	Init: func(cmd *cmd.Command) {
+		cmd.Flags.String("database", "", "Database to use when connecting")
+		cmd.Flags.String("file", "", "File to read the statements from")
+		addOutputFlag(cmd)
+		addJobsFlag(cmd)
+	},
+}
+
+var backupServerCmd = cmd.Command{
+	Brief: "Write a dump of a server to a file",
+
+	Description: `Command will dump all databases of the server
+	matching SERVER to FILE using mysqldump, so that the state of
+	the server can be restored later using 'server restore'. The
+	server has to be running. If the dump fails, FILE is removed.`,
+
+	Synopsis: "SERVER FILE",
+	ReadOnly: true,
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) == 0 {
+			return ErrNoServerName
+		} else if len(args) == 1 {
+			return fmt.Errorf("No file name provided")
+		} else if len(args) > 2 {
+			return ErrTooManyArgs
+		}
+
+		srv, err := findServer(ctx, args[0])
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Create(args[1])
+		if err != nil {
+			return err
+		}
+		err = srv.Dump(file)
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(args[1])
+		}
 		return err
+	},
+}
+
+var restoreServerCmd = cmd.Command{
+	Brief: "Restore a server from a dump file",
+
+	Description: `Command will execute the statements in FILE on the
+	server matching SERVER using the mysql client. This is used to
+	restore a dump written using 'server backup'. The server has to
+	be running.`,
+
+	Synopsis:    "SERVER FILE",
+	SideEffects: true,
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) == 0 {
+			return ErrNoServerName
+		} else if len(args) == 1 {
+			return fmt.Errorf("No file name provided")
+		} else if len(args) > 2 {
+			return ErrTooManyArgs
+		}
+
+		srv, err := findServer(ctx, args[0])
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return srv.Restore(file)
+	},
+}
+
+var queryServerCmd = cmd.Command{
+	Brief: "Run a query on servers and show the result",
+
+	Description: `Command is used to run a query, for example a
+	SELECT, against one or more servers and show the result
+	set. The query provided will be sent to all servers matching
+	the pattern. A '--' can be used to separate the query from the
+	pattern.
+
+        The result set from each server is printed as a table, or if
+        -json is given, as one JSON object for each server of the
+        form {"server":..., "columns":[...], "rows":[[...], ...]}. If
+        -objects is given as well, each row is instead printed as an
+        object mapping the column names to the values.
+
+        If -output-file is given, the output is written to the file
+        as well.`,
+
+	Synopsis: "[ OPTION ] PATTERN [ -- ] SQL ...",
+	ReadOnly: true,
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) == 0 {
+			return ErrNoServerName
+		}
+
+		words := args[1:]
+		if len(words) > 0 && words[0] == "--" {
+			words = words[1:]
+		}
+		if len(words) == 0 {
+			return fmt.Errorf("No query provided")
+		}
+		query := strings.Join(words, " ")
+
+		servers, err := ctx.Stable.FindMatchingServers(args[0:1])
+		if err != nil {
+			return err
+		} else if len(servers) == 0 {
+			return fmt.Errorf("No servers matching %q", args[0])
+		}
+
+		out, closer, err := openOutput(ctx, cmd)
+		if err != nil {
+			return err
+		}
+		defer closer.Close()
+
+		asJson := cmd.Flags.Lookup("json").Value.String() == "true"
+		asObjects := cmd.Flags.Lookup("objects").Value.String() == "true"
+		encoder := json.NewEncoder(out)
+		for _, srv := range servers {
+			result, err := srv.Query(query)
+			if err != nil {
+				return fmt.Errorf("Server %s: %s", srv.Name, err)
+			}
+
+			if asJson && asObjects {
+				err = encoder.Encode(map[string]interface{}{
+					"server": result.Server,
+					"rows":   result.Objects(),
+				})
+			} else if asJson {
+				err = encoder.Encode(result)
+			} else {
+				err = printQueryResult(out, result, query)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+
+	Init: func(cmd *cmd.Command) {
+		cmd.Flags.Bool("json", false, "Print the result as JSON")
+		cmd.Flags.Bool("objects", false, "Print each row as a JSON object")
+		addOutputFlag(cmd)
+	},
+}
+
+// printQueryResult will write the result of a query as a table.
+func printQueryResult(w io.Writer, result *stable.QueryResult, query string) error {
+	fmt.Fprintf(w, "\n%s> %s\n", result.Server, query)
+	tw := tabwriter.NewWriter(w, 8, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\t\n", strings.Join(result.Columns, "\t"))
+	for _, row := range result.Rows {
+		for _, value := range row {
+			if value == nil {
+				value = "NULL"
+			}
+			fmt.Fprintf(tw, "%v\t", value)
+		}
+		fmt.Fprintln(tw)
 	}
+	return tw.Flush()
+}
 
-	return nil
+var grepConfigServerCmd = cmd.Command{
+	Brief: "Search the options of all servers",
+
+	Description: `Search the options of all servers in the stable
+	for options where either the name or the value match the
+	regular expression REGEXP. Each match is printed as 'server:
+	section: option = value'. If -section is given, only options
+	in that section are searched.`,
+
+	Synopsis: "[ OPTION ] REGEXP",
+	ReadOnly: true,
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) == 0 {
+			return fmt.Errorf("No regular expression provided")
+		} else if len(args) > 1 {
+			return ErrTooManyArgs
+		}
+
+		re, err := regexp.Compile(args[0])
+		if err != nil {
+			return err
+		}
+
+		section := cmd.Flags.Lookup("section").Value.String()
+		for _, match := range ctx.Stable.GrepConfig(re, section) {
+			fmt.Fprintf(ctx.Out, "%s: %s: %s = %s\n", match.Server, match.Section, match.Option, match.Value)
+		}
+		return nil
+	},
+
+	Init: func(cmd *cmd.Command) {
+		cmd.Flags.String("section", "", "Only search options in the section")
+	},
+}
+
+// findServer will return the only server matching the pattern. It is
+// an error if the pattern match no server or more than one server.
+func findServer(ctx *cmd.Context, pattern string) (*stable.Server, error) {
+	servers, err := ctx.Stable.FindMatchingServers([]string{pattern})
+	if err != nil {
+		return nil, err
+	} else if len(servers) == 0 {
+		return nil, fmt.Errorf("No servers matching %q", pattern)
+	} else if len(servers) > 1 {
+		return nil, fmt.Errorf("Pattern %q match more than one server", pattern)
+	}
+	return servers[0], nil
+}
+
+var replicateServerCmd = cmd.Command{
+	Brief: "Set up replication from a master to a slave",
+
+	Description: `Command will make the server SLAVE replicate from the
+	server MASTER, starting at the current binary log position of
+	MASTER. Both servers have to be running, the binary log has to be
+	enabled on MASTER, and the servers need different server ids.
+
+        A replication user is created on MASTER and used by SLAVE to
+        connect. The user and password can be given using -user and
+        -password. The command waits for the slave I/O thread to
+        connect to the master and fails if it has not done so within
+        the timeout.`,
+
+	Synopsis:    "[ OPTION ] MASTER SLAVE",
+	SideEffects: true,
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) < 2 {
+			return ErrNoServerName
+		} else if len(args) > 2 {
+			return ErrTooManyArgs
+		}
+
+		master, err := findServer(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		slave, err := findServer(ctx, args[1])
+		if err != nil {
+			return err
+		}
+
+		timeout, interval, err := waitOptions(cmd)
+		if err != nil {
+			return err
+		}
+		slave.SetPollInterval(interval)
+
+		user := cmd.Flags.Lookup("user").Value.String()
+		password := cmd.Flags.Lookup("password").Value.String()
+		return stable.Replicate(stable.ServerExecutor, master, slave, user, password, timeout)
+	},
+
+	Init: func(cmd *cmd.Command) {
+		cmd.Flags.String("user", "repl", "User the slave use to connect to the master")
+		cmd.Flags.String("password", "", "Password of the replication user")
+		addWaitFlags(cmd, 30*time.Second)
+	},
+}
+
+var promoteServerCmd = cmd.Command{
+	Brief: "Promote a slave to be the new master",
+
+	Description: `Command will turn the server SLAVE into a master
+	by stopping replication on it and removing the replication
+	configuration. If -redirect is given, all servers matching the
+	pattern are redirected to replicate from the new master,
+	starting at its current binary log position. This require the
+	binary log to be enabled on SLAVE.
+
+        The redirected servers connect using a replication user that
+        is created on SLAVE. The user and password can be given using
+        -user and -password.
+
+        The new topology is printed once the promotion is done.`,
+
+	Synopsis:    "[ OPTION ] SLAVE",
+	SideEffects: true,
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) == 0 {
+			return ErrNoServerName
+		} else if len(args) > 1 {
+			return ErrTooManyArgs
+		}
+
+		master, err := findServer(ctx, args[0])
+		if err != nil {
+			return err
+		}
+
+		others := []*stable.Server{}
+		if pattern := cmd.Flags.Lookup("redirect").Value.String(); len(pattern) > 0 {
+			matched, err := ctx.Stable.FindMatchingServers([]string{pattern})
+			if err != nil {
+				return err
+			}
+			for _, srv := range matched {
+				if srv != master {
+					others = append(others, srv)
+				}
+			}
+		}
+
+		user := cmd.Flags.Lookup("user").Value.String()
+		password := cmd.Flags.Lookup("password").Value.String()
+		if err := stable.Promote(stable.ServerExecutor, master, others, user, password); err != nil {
+			return err
+		}
+
+		fmt.Fprintln(ctx.Out, master.Name)
+		for _, srv := range others {
+			fmt.Fprintf(ctx.Out, "  %s\n", srv.Name)
+		}
+		return nil
+	},
+
+	Init: func(cmd *cmd.Command) {
+		cmd.Flags.String("redirect", "", "Servers to replicate from the new master")
+		cmd.Flags.String("user", "repl", "User the redirected servers use to connect to the new master")
+		cmd.Flags.String("password", "", "Password of the replication user")
+	},
+}
+
+var setLimitsServerCmd = cmd.Command{
+	Brief: "Set resource limits for servers",
+
+	Description: `Set the resource limits that are applied to the
+	server processes when the servers matching the pattern are
+	started. Each limit is either a number or 'unlimited', and the
+	sizes are in bytes. Only the limits given are changed, and
+	-clear can be used to remove all limits before setting new
+	ones.
+
+        Without any options, the current limits of the servers are
+        shown.`,
+
+	Synopsis: "[ OPTION ] PATTERN",
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) == 0 {
+			return ErrNoServerName
+		} else if len(args) > 1 {
+			return ErrTooManyArgs
+		}
+
+		servers, err := ctx.Stable.FindMatchingServers(args)
+		if err != nil {
+			return err
+		} else if len(servers) == 0 {
+			return fmt.Errorf("No servers matching %q", args[0])
+		}
+
+		changed := cmd.Flags.NFlag() > 0
+		clear := cmd.Flags.Lookup("clear").Value.String() == "true"
+		for _, srv := range servers {
+			if clear {
+				srv.StartLimits = nil
+			}
+			for _, name := range stable.LimitNames() {
+				value := cmd.Flags.Lookup(name).Value.String()
+				if len(value) == 0 {
+					continue
+				}
+				if err := srv.SetLimit(name, value); err != nil {
+					return err
+				}
+			}
+
+			if !changed {
+				fmt.Fprintf(ctx.Out, "%s:", srv.Name)
+				for _, name := range stable.LimitNames() {
+					if value, ok := srv.StartLimits[name]; ok {
+						if value == stable.RLIM_INFINITY {
+							fmt.Fprintf(ctx.Out, " %s=unlimited", name)
+						} else {
+							fmt.Fprintf(ctx.Out, " %s=%d", name, value)
+						}
+					}
+				}
+				fmt.Fprintln(ctx.Out)
+			}
+		}
+		return nil
+	},
+
+	Init: func(cmd *cmd.Command) {
+		cmd.Flags.Bool("clear", false, "Remove all limits before setting new ones")
+		cmd.Flags.String("nofile", "", "Maximum number of open files")
+		cmd.Flags.String("core", "", "Maximum size of core files")
+		cmd.Flags.String("memory", "", "Maximum size of the address space")
+		cmd.Flags.String("data", "", "Maximum size of the data segment")
+		cmd.Flags.String("stack", "", "Maximum size of the stack")
+	},
+}
+
+var setEnvServerCmd = cmd.Command{
+	Brief: "Set environment variables for servers",
+
+	Description: `Set environment variables that are used when
+	bootstrapping and starting the servers matching PATTERN, as
+	well as when running clients for them. This is useful for
+	distributions that need, for example, LD_LIBRARY_PATH to be
+	set to find bundled libraries. Each variable is given as
+	NAME=VALUE, and giving an empty value removes the variable.
+
+        If no variables are given, the variables set for the servers
+        are shown.`,
+
+	Synopsis: "PATTERN [ NAME=VALUE ... ]",
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) == 0 {
+			return ErrNoServerName
+		}
+
+		servers, err := ctx.Stable.FindMatchingServers(args[:1])
+		if err != nil {
+			return err
+		} else if len(servers) == 0 {
+			return fmt.Errorf("No servers matching %q", args[0])
+		}
+
+		for _, srv := range servers {
+			for _, arg := range args[1:] {
+				i := strings.Index(arg, "=")
+				if i < 0 {
+					return fmt.Errorf("Expected NAME=VALUE, got %q", arg)
+				}
+				if err := srv.SetEnv(arg[:i], arg[i+1:]); err != nil {
+					return err
+				}
+			}
+
+			if len(args) == 1 {
+				names := []string{}
+				for name := range srv.Env {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				for _, name := range names {
+					fmt.Fprintf(ctx.Out, "%s: %s=%s\n", srv.Name, name, srv.Env[name])
+				}
+			}
+		}
+		return nil
+	},
+}
+
+var configServerCmd = cmd.Command{
+	Brief: "Show or change the options of servers",
+
+	Description: `Show or change the options in the configuration
+	file of the servers matching PATTERN. If no changes are given,
+	the options of the servers are printed, with each line
+	prefixed by the name of the server.
+
+        Options are set by giving SECTION.OPTION=VALUE, or
+        SECTION.OPTION for options that do not take a value, and
+        removed by giving SECTION.OPTION-. For example,
+        'mysqld.log-bin=master-bin' set the log_bin option in the
+        mysqld section.
+
+        The configuration file is re-written and the options are
+        kept for the server, but a running server has to be
+        restarted for the changes to take effect.`,
+
+	Synopsis: "PATTERN [ SECTION.OPTION[=VALUE] | SECTION.OPTION- ... ]",
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) == 0 {
+			return ErrNoServerName
+		}
+
+		changes := make([]*stable.OptionChange, len(args)-1)
+		for i, arg := range args[1:] {
+			change, err := stable.ParseOptionChange(arg)
+			if err != nil {
+				return err
+			}
+			changes[i] = change
+		}
+
+		servers, err := ctx.Stable.FindMatchingServers(args[:1])
+		if err != nil {
+			return err
+		} else if len(servers) == 0 {
+			return fmt.Errorf("No servers matching %q", args[0])
+		}
+
+		for _, srv := range servers {
+			if len(changes) == 0 {
+				var buf bytes.Buffer
+				if err := srv.Options.Write(&buf); err != nil {
+					return err
+				}
+				for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+					fmt.Fprintf(ctx.Out, "%s: %s\n", srv.Name, line)
+				}
+				continue
+			}
+
+			if err := srv.ChangeOptions(changes); err != nil {
+				return err
+			}
+			if srv.Status() == stable.SERVER_RUNNING {
+				log.Warningf("Server %s is running and has to be restarted for the changes to take effect", srv.Name)
+			}
+		}
+		return nil
+	},
+}
+
+// printServerInfo will print the connection details of the servers to
+// the writer, either as a table or as one JSON object for each server.
+func printServerInfo(w io.Writer, servers []*stable.Server, asJson bool) error {
+	if asJson {
+		encoder := json.NewEncoder(w)
+		for _, srv := range servers {
+			if err := encoder.Encode(srv.Info()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 8, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "NAME\tPORT\tSERVER ID\tSOCKET\t\n")
+	for _, srv := range servers {
+		info := srv.Info()
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t\n", info.Name, info.Port, info.ServerId, info.Socket)
+	}
+	return tw.Flush()
+}
+
+// findDist will find the distribution containing the provided string
+// as a substring. If less than or more than one distribution
+// matches, an error is returned.
+func findDist(stbl *stable.Stable, str string) (*stable.Dist, error) {
+	candidates := []*stable.Dist{}
+	for key, dist := range stbl.Distro {
+		if strings.Contains(key, str) {
+			candidates = append(candidates, dist)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("No distribution containing %q", str)
+	} else if len(candidates) > 1 {
+		return nil, fmt.Errorf("Ambigous choice.")
+	}
+	return candidates[0], nil
+}
+
+// findDistMatching will find the distribution matching the provided
+// glob pattern. If less than or more than one distribution matches,
+// an error is returned.
+func findDistMatching(stbl *stable.Stable, pattern string) (*stable.Dist, error) {
+	dists, err := stbl.FindMatchingDists([]string{pattern})
+	if err != nil {
+		return nil, err
+	} else if len(dists) == 0 {
+		return nil, fmt.Errorf("No distribution matching %q", pattern)
+	} else if len(dists) > 1 {
+		return nil, fmt.Errorf("Pattern %q match more than one distribution", pattern)
+	}
+	return dists[0], nil
 }
 
 func init() {
 	context.RegisterGroup([]string{"server"}, &srvGrp)
 	context.RegisterCommand([]string{"server", "add"}, &addServerCmd)
+	context.RegisterCommand([]string{"server", "adopt"}, &adoptServerCmd)
 	context.RegisterCommand([]string{"server", "remove"}, &removeServerCmd)
 	context.RegisterCommand([]string{"server", "show"}, &showServersCmd)
-	context.RegisterCommand([]string{"server", "status"}, &showServersCmd)
+	context.RegisterCommand([]string{"server", "status"}, &statusServerCmd)
+	context.RegisterCommand([]string{"server", "logs"}, &logsServerCmd)
 	context.RegisterCommand([]string{"server", "start"}, &startServerCmd)
 	context.RegisterCommand([]string{"server", "stop"}, &stopServerCmd)
+	context.RegisterCommand([]string{"server", "restart"}, &restartServerCmd)
+	context.RegisterCommand([]string{"server", "reload"}, &reloadServerCmd)
+	context.RegisterCommand([]string{"server", "clone"}, &cloneServerCmd)
 	context.RegisterCommand([]string{"server", "fmt"}, &fmtServerCmd)
 	context.RegisterCommand([]string{"server", "client"}, &clientServerCmd)
 	context.RegisterCommand([]string{"server", "execute"}, &executeServerCmd)
+	context.RegisterCommand([]string{"server", "query"}, &queryServerCmd)
+	context.RegisterCommand([]string{"server", "backup"}, &backupServerCmd)
+	context.RegisterCommand([]string{"server", "restore"}, &restoreServerCmd)
+	context.RegisterCommand([]string{"server", "open"}, &openServerCmd)
+	context.RegisterCommand([]string{"server", "grep-config"}, &grepConfigServerCmd)
+	context.RegisterCommand([]string{"server", "replicate"}, &replicateServerCmd)
+	context.RegisterCommand([]string{"server", "promote"}, &promoteServerCmd)
+	context.RegisterCommand([]string{"server", "set-limits"}, &setLimitsServerCmd)
+	context.RegisterCommand([]string{"server", "set-env"}, &setEnvServerCmd)
+	context.RegisterCommand([]string{"server", "config"}, &configServerCmd)
 }
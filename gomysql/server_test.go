@@ -0,0 +1,93 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mysqld/stable"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFmtServerAll(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	stbl, err := stable.CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+	dist := &stable.Dist{Name: "fake"}
+	stbl.Distro[dist.Name] = dist
+	for _, name := range []string{"beta", "alpha"} {
+		stbl.Server[name] = &stable.Server{Name: name, Dist: dist}
+	}
+	if err := stbl.WriteConfig(); err != nil {
+		t.Fatalf("Unable to write configuration: %s", err)
+	}
+
+	// This is the command used by the completion script to list
+	// the names of the servers.
+	var out bytes.Buffer
+	savedRoot, savedOut := context.RootDir, context.Out
+	defer func() { context.RootDir, context.Out = savedRoot, savedOut }()
+	context.RootDir, context.Out = root, &out
+	if err := context.RunCommand([]string{"server", "fmt", "{Name}", "*"}); err != nil {
+		t.Fatalf("Unable to list servers: %s", err)
+	}
+	if names := out.String(); names != "alpha\nbeta\n" {
+		t.Errorf("Expected %q, got %q", "alpha\nbeta\n", names)
+	}
+}
+
+func TestRemoveServerError(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	stbl, err := stable.CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+
+	// The base directory of the server cannot be removed since
+	// it is below a file.
+	file := filepath.Join(root, "file")
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatalf("Unable to create file: %s", err)
+	}
+	dist := &stable.Dist{Name: "fake"}
+	stbl.Distro[dist.Name] = dist
+	stbl.Server["broken"] = &stable.Server{Name: "broken", Dist: dist, BaseDir: filepath.Join(file, "broken")}
+	if err := stbl.WriteConfig(); err != nil {
+		t.Fatalf("Unable to write configuration: %s", err)
+	}
+
+	savedRoot, savedYes := context.RootDir, context.AssumeYes
+	defer func() { context.RootDir, context.AssumeYes = savedRoot, savedYes }()
+	context.RootDir, context.AssumeYes = root, true
+	if err := context.RunCommand([]string{"server", "remove", "broken"}); err == nil {
+		t.Errorf("Expected error when server cannot be removed")
+	}
+}
@@ -0,0 +1,305 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package main
+
+import (
+	"fmt"
+	"mysqld/cmd"
+	"mysqld/stable"
+	"os"
+)
+
+var stableGrp = cmd.Group{
+	Brief: "Commands for working with the stable",
+
+	Description: `All commands for working with the stable as a
+	whole are in this group.`,
+}
+
+var auditStableCmd = cmd.Command{
+	Brief: "Enable or disable the audit log",
+
+	Description: `When auditing is enabled, every command that
+	changes the stable or the servers in it is recorded in the
+	audit log 'audit.log' in the stable directory together with
+	the time, the arguments, and the result of the command.
+
+        Without arguments, the command will show if auditing is
+        enabled or not.`,
+
+	Synopsis: "[ on | off ]",
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) > 1 {
+			return ErrTooManyArgs
+		} else if len(args) == 0 {
+			if ctx.Stable.Auditing {
+				fmt.Fprintln(ctx.Out, "on")
+			} else {
+				fmt.Fprintln(ctx.Out, "off")
+			}
+			return nil
+		}
+
+		switch args[0] {
+		case "on":
+			ctx.Stable.Auditing = true
+		case "off":
+			ctx.Stable.Auditing = false
+		default:
+			return fmt.Errorf("Expected 'on' or 'off', got %q", args[0])
+		}
+		return nil
+	},
+}
+
+var replayStableCmd = cmd.Command{
+	Brief: "Replay an audit log into a new stable",
+
+	Description: `This command will create a new stable in
+	LOCATION and re-execute all the commands recorded in the audit
+	log LOG against it. Commands that failed when they were
+	recorded are skipped.
+
+        Commands that have effects that cannot be reproduced from the
+        stable alone, such as executing SQL statements on servers,
+        are skipped unless -include-sql is given.`,
+
+	Synopsis:   "[ OPTION ] LOG LOCATION",
+	SkipStable: true,
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) != 2 {
+			return fmt.Errorf("command 'stable replay' require LOG and LOCATION")
+		}
+
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		entries, err := stable.ReadAudit(file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+
+		if _, err := stable.CreateStable(args[1]); err != nil {
+			return err
+		}
+
+		ctx.RootDir = args[1]
+		sideEffects := cmd.Flags.Lookup("include-sql").Value.String() == "true"
+		count, err := ctx.Replay(entries, sideEffects)
+		fmt.Fprintf(ctx.Out, "Replayed %d of %d commands\n", count, len(entries))
+		return err
+	},
+
+	Init: func(cmd *cmd.Command) {
+		cmd.Flags.Bool("include-sql", false, "Replay commands executing SQL statements as well")
+	},
+}
+
+var checkStableCmd = cmd.Command{
+	Brief: "Check the stable for inconsistencies",
+
+	Description: `Check that the servers in the stable are
+	consistent with the options they are started with. For
+	example, if the PID file that mysqld will write is not the
+	one that the stable looks at, the status of the server will
+	be misreported.
+
+        Each problem found is printed on a line of its own and an
+        error is returned if any problems were found.`,
+
+	ReadOnly: true,
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) > 0 {
+			return ErrTooManyArgs
+		}
+		problems := ctx.Stable.Check()
+		for _, problem := range problems {
+			fmt.Fprintln(ctx.Out, problem)
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("Found %d problems in stable", len(problems))
+		}
+		return nil
+	},
+}
+
+var doctorStableCmd = cmd.Command{
+	Brief: "Diagnose problems with the environment",
+
+	Description: `Run a series of checks of the environment that
+	the stable is used in and print the result of each check,
+	together with a hint on how to fix it if it failed. The checks
+	include that the stable directory is short enough to hold the
+	socket paths of the servers, that the stable directory is
+	writable, and that the mysqld of each distribution can be
+	executed.
+
+        An error is returned if any critical check failed.`,
+
+	ReadOnly: true,
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) > 0 {
+			return ErrTooManyArgs
+		}
+		failed := 0
+		for _, diag := range ctx.Stable.Doctor(stable.SystemLauncher) {
+			if diag.Err == nil {
+				fmt.Fprintf(ctx.Out, "[PASS] %s\n", diag.Check)
+				continue
+			}
+			fmt.Fprintf(ctx.Out, "[FAIL] %s: %s\n", diag.Check, diag.Err)
+			fmt.Fprintf(ctx.Out, "       %s\n", diag.Hint)
+			if diag.Critical {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d critical checks failed", failed)
+		}
+		return nil
+	},
+}
+
+var registerStableCmd = cmd.Command{
+	Brief: "Register a stable in the registry",
+
+	Description: `Add the stable in DIR to the registry of stables,
+	so that commands can be run against it using 'stable
+	foreach'. If no DIR is given, the stable in the root directory
+	is registered. The registry is kept in the file given by the
+	GOMYSQL_REGISTRY environment variable, or in
+	'.gomysql/registry.json' in the home directory.`,
+
+	Synopsis:   "[ DIR ]",
+	SkipStable: true,
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		return updateRegistry(ctx, args, (*stable.Registry).Add)
+	},
+}
+
+var unregisterStableCmd = cmd.Command{
+	Brief: "Remove a stable from the registry",
+
+	Description: `Remove the stable in DIR from the registry of
+	stables. If no DIR is given, the stable in the root directory
+	is removed. The stable itself is not touched.`,
+
+	Synopsis:   "[ DIR ]",
+	SkipStable: true,
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		return updateRegistry(ctx, args, (*stable.Registry).Remove)
+	},
+}
+
+var listStableCmd = cmd.Command{
+	Brief: "List the registered stables",
+
+	Description: `Show the directories of all stables in the
+	registry.`,
+
+	SkipStable: true,
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) > 0 {
+			return ErrTooManyArgs
+		}
+		reg, err := stable.ReadRegistry(stable.DefaultRegistryPath())
+		if err != nil {
+			return err
+		}
+		for _, root := range reg.Stables {
+			fmt.Fprintln(ctx.Out, root)
+		}
+		return nil
+	},
+}
+
+var foreachStableCmd = cmd.Command{
+	Brief: "Run a command against all registered stables",
+
+	Description: `Run the COMMAND against each stable in the
+	registry, printing the directory of the stable before the
+	output of the command. For example, to show the servers in all
+	stables:
+
+            gomysql stable foreach -- server show
+
+        Only commands that do not change the stables are allowed
+        unless -allow-changes is given.`,
+
+	Synopsis:   "[ OPTION ] [ -- ] COMMAND ...",
+	SkipStable: true,
+	Body: func(ctx *cmd.Context, cmd *cmd.Command, args []string) error {
+		if len(args) > 0 && args[0] == "--" {
+			args = args[1:]
+		}
+		if len(args) == 0 {
+			return fmt.Errorf("No command provided")
+		}
+
+		reg, err := stable.ReadRegistry(stable.DefaultRegistryPath())
+		if err != nil {
+			return err
+		}
+
+		allowChanges := cmd.Flags.Lookup("allow-changes").Value.String() == "true"
+		label := func(root string) {
+			fmt.Fprintf(ctx.Out, "\n== %s ==\n", root)
+		}
+		return ctx.RunForEach(reg.Stables, args, allowChanges, label)
+	},
+
+	Init: func(cmd *cmd.Command) {
+		cmd.Flags.Bool("allow-changes", false, "Allow commands that change the stables")
+	},
+}
+
+// updateRegistry will read the registry, apply the update to the
+// directory given in the arguments (or the root directory), and write
+// the registry back.
+func updateRegistry(ctx *cmd.Context, args []string, update func(*stable.Registry, string) error) error {
+	if len(args) > 1 {
+		return ErrTooManyArgs
+	}
+
+	dir := ctx.RootDir
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	reg, err := stable.ReadRegistry(stable.DefaultRegistryPath())
+	if err != nil {
+		return err
+	}
+	if err := update(reg, dir); err != nil {
+		return err
+	}
+	return reg.Write()
+}
+
+func init() {
+	context.RegisterGroup([]string{"stable"}, &stableGrp)
+	context.RegisterCommand([]string{"stable", "audit"}, &auditStableCmd)
+	context.RegisterCommand([]string{"stable", "check"}, &checkStableCmd)
+	context.RegisterCommand([]string{"stable", "doctor"}, &doctorStableCmd)
+	context.RegisterCommand([]string{"stable", "register"}, &registerStableCmd)
+	context.RegisterCommand([]string{"stable", "unregister"}, &unregisterStableCmd)
+	context.RegisterCommand([]string{"stable", "list"}, &listStableCmd)
+	context.RegisterCommand([]string{"stable", "foreach"}, &foreachStableCmd)
+	context.RegisterCommand([]string{"stable", "replay"}, &replayStableCmd)
+}
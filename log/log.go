@@ -20,15 +20,23 @@
 // based on a priority set.
 package log
 
-import "log"
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
 
 // Priority is a type to enumerate the logging levels. Higher priority
 // levels, such as "error" have lower numbers, while lower priorities,
-// such as "info" have higher numbers.
+// such as "info" have higher numbers. Fatal messages are always
+// printed, so setting the priority to "fatal" silences all other
+// messages.
 type Priority int
 
 const (
-	PRIORITY_ERROR = iota
+	PRIORITY_FATAL = iota - 1
+	PRIORITY_ERROR
 	PRIORITY_WARNING
 	PRIORITY_INFO
 	PRIORITY_DEBUG
@@ -36,6 +44,31 @@ const (
 
 var priority Priority = PRIORITY_WARNING
 
+// logger is the logger that all messages are written through.
+var logger = log.New(os.Stderr, "", log.LstdFlags)
+
+// exit is called to terminate the program after a fatal message.
+var exit = os.Exit
+
+// SetOutput set the destination for log messages to w.
+func SetOutput(w io.Writer) {
+	logger.SetOutput(w)
+}
+
+// SetFlags set the output flags of the logger, which control the
+// prefix of each message. The flags are the same as for the standard
+// log package, e.g., log.LstdFlags|log.Lshortfile.
+func SetFlags(flags int) {
+	logger.SetFlags(flags)
+}
+
+// output write a message to the logger. The file and line reported
+// when using log.Lshortfile or log.Llongfile is the one of the caller
+// of the logging function.
+func output(s string) {
+	logger.Output(3, s)
+}
+
 // SetLevel set the log level priority to pri. Any messages for that
 // priority or higher will then be printed, so priority "warning" will
 // print both "warning" and "error", but not "info".
@@ -45,72 +78,82 @@ func SetPriority(pri Priority) {
 
 func Debug(a ...interface{}) {
 	if priority >= PRIORITY_DEBUG {
-		log.Print(a...)
+		output(fmt.Sprint(a...))
 	}
 }
 
 func Debugln(a ...interface{}) {
 	if priority >= PRIORITY_DEBUG {
-		log.Println(a...)
+		output(fmt.Sprintln(a...))
 	}
 }
 
 func Debugf(format string, a ...interface{}) {
 	if priority >= PRIORITY_DEBUG {
-		log.Printf(format, a...)
+		output(fmt.Sprintf(format, a...))
 	}
 }
 
 func Info(a ...interface{}) {
 	if priority >= PRIORITY_INFO {
-		log.Print(a...)
+		output(fmt.Sprint(a...))
 	}
 }
 
 func Infoln(a ...interface{}) {
 	if priority >= PRIORITY_INFO {
-		log.Println(a...)
+		output(fmt.Sprintln(a...))
 	}
 }
 
 func Infof(format string, a ...interface{}) {
 	if priority >= PRIORITY_INFO {
-		log.Printf(format, a...)
+		output(fmt.Sprintf(format, a...))
 	}
 }
 
 func Warning(a ...interface{}) {
-	if priority >= PRIORITY_INFO {
-		log.Print(a...)
+	if priority >= PRIORITY_WARNING {
+		output(fmt.Sprint(a...))
 	}
 }
 
 func Warningln(a ...interface{}) {
-	if priority >= PRIORITY_INFO {
-		log.Println(a...)
+	if priority >= PRIORITY_WARNING {
+		output(fmt.Sprintln(a...))
 	}
 }
 
 func Warningf(format string, a ...interface{}) {
-	if priority >= PRIORITY_INFO {
-		log.Printf(format, a...)
+	if priority >= PRIORITY_WARNING {
+		output(fmt.Sprintf(format, a...))
 	}
 }
 
 func Error(a ...interface{}) {
-	if priority >= PRIORITY_INFO {
-		log.Print(a...)
+	if priority >= PRIORITY_ERROR {
+		output(fmt.Sprint(a...))
 	}
 }
 
 func Errorln(a ...interface{}) {
-	if priority >= PRIORITY_INFO {
-		log.Println(a...)
+	if priority >= PRIORITY_ERROR {
+		output(fmt.Sprintln(a...))
 	}
 }
 
 func Errorf(format string, a ...interface{}) {
-	if priority >= PRIORITY_INFO {
-		log.Printf(format, a...)
+	if priority >= PRIORITY_ERROR {
+		output(fmt.Sprintf(format, a...))
 	}
 }
+
+func Fatal(a ...interface{}) {
+	output(fmt.Sprint(a...))
+	exit(1)
+}
+
+func Fatalf(format string, a ...interface{}) {
+	output(fmt.Sprintf(format, a...))
+	exit(1)
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package log
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPriority(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	flags := logger.Flags()
+	logger.SetFlags(0)
+	saved := priority
+	defer func() {
+		SetOutput(os.Stderr)
+		logger.SetFlags(flags)
+		priority = saved
+	}()
+
+	families := []struct {
+		name string
+		fns  []func()
+	}{
+		{"error", []func(){
+			func() { Error("error") },
+			func() { Errorln("error") },
+			func() { Errorf("%s", "error") },
+		}},
+		{"warning", []func(){
+			func() { Warning("warning") },
+			func() { Warningln("warning") },
+			func() { Warningf("%s", "warning") },
+		}},
+		{"info", []func(){
+			func() { Info("info") },
+			func() { Infoln("info") },
+			func() { Infof("%s", "info") },
+		}},
+		{"debug", []func(){
+			func() { Debug("debug") },
+			func() { Debugln("debug") },
+			func() { Debugf("%s", "debug") },
+		}},
+	}
+
+	levels := []Priority{PRIORITY_FATAL, PRIORITY_ERROR, PRIORITY_WARNING, PRIORITY_INFO, PRIORITY_DEBUG}
+	for _, level := range levels {
+		SetPriority(level)
+		for i, family := range families {
+			for _, fn := range family.fns {
+				buf.Reset()
+				fn()
+				emitted := strings.TrimSpace(buf.String()) == family.name
+				if expected := Priority(i) <= level; emitted != expected {
+					t.Errorf("Priority %d: %s message emitted is %v, expected %v",
+						level, family.name, emitted, expected)
+				}
+			}
+		}
+	}
+}
+
+func TestFatal(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	flags := logger.Flags()
+	logger.SetFlags(0)
+	saved := priority
+	var status int
+	exit = func(code int) { status = code }
+	defer func() {
+		SetOutput(os.Stderr)
+		logger.SetFlags(flags)
+		priority = saved
+		exit = os.Exit
+	}()
+
+	SetPriority(PRIORITY_FATAL)
+	for _, fn := range []func(){
+		func() { Fatal("fatal") },
+		func() { Fatalf("%s", "fatal") },
+	} {
+		buf.Reset()
+		status = 0
+		fn()
+		if msg := strings.TrimSpace(buf.String()); msg != "fatal" {
+			t.Errorf("Expected message %q, got %q", "fatal", msg)
+		}
+		if status != 1 {
+			t.Errorf("Expected exit status 1, got %d", status)
+		}
+	}
+}
+
+func TestSetFlags(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	flags := logger.Flags()
+	defer func() {
+		SetOutput(os.Stderr)
+		logger.SetFlags(flags)
+	}()
+
+	// The file reported should be the one calling the logging
+	// function, not the log package itself.
+	SetFlags(log.Lshortfile)
+	Error("message")
+	if msg := buf.String(); !strings.HasPrefix(msg, "log_test.go:") {
+		t.Errorf("Expected file prefix, got %q", msg)
+	}
+}
@@ -0,0 +1,339 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mysqld/log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// commandReader is reading the output of a command. Closing the
+// reader will wait for the command to finish.
+type commandReader struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (rd *commandReader) Close() error {
+	rd.ReadCloser.Close()
+	return rd.cmd.Wait()
+}
+
+// xzProgram is the program used to uncompress xz archives.
+var xzProgram = "xz"
+
+// findXz will return the path to the xz program, or an error telling
+// that it is needed if it cannot be found.
+func findXz() (string, error) {
+	path, err := exec.LookPath(xzProgram)
+	if err != nil {
+		return "", fmt.Errorf("The %s program is needed to unpack xz archives, but it was not found: install it or use a .tar.gz archive", xzProgram)
+	}
+	return path, nil
+}
+
+// decompress will return a reader for the uncompressed contents of
+// the tar archive at the path, which is compressed according to the
+// type of the path. There is no xz support in the standard library,
+// so the xz program is used to uncompress xz archives.
+func decompress(file io.Reader, path string) (io.ReadCloser, error) {
+	switch pathType(path) {
+	case TGZ_PATH:
+		return gzip.NewReader(file)
+	case TBZ2_PATH:
+		return ioutil.NopCloser(bzip2.NewReader(file)), nil
+	case TXZ_PATH:
+		xz, err := findXz()
+		if err != nil {
+			return nil, err
+		}
+		cmd := exec.Command(xz, "--decompress", "--stdout")
+		cmd.Stdin = file
+		out, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+		return &commandReader{out, cmd}, nil
+	default:
+		return ioutil.NopCloser(file), nil
+	}
+}
+
+// extractTar will extract the tar archive at the path into the
+// directory, uncompressing it if necessary. Files are written with the
+// modes recorded in the archive. If the extraction fails, the error
+// identifies the entry that could not be extracted.
+//
+// If a checksum is given, the digest of the archive is computed while
+// it is extracted and it is an error if it does not match.
+func extractTar(path, dir string, sum *checksum) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var rd io.Reader = file
+	if sum != nil {
+		rd = io.TeeReader(file, sum)
+	}
+
+	zr, err := decompress(rd, path)
+	if err != nil {
+		return fmt.Errorf("Unable to unpack %s: %s", path, err)
+	}
+
+	err = extractTarEntries(zr, path, dir)
+	if cerr := zr.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("Unable to unpack %s: %s", path, cerr)
+	}
+	if err != nil || sum == nil {
+		return err
+	}
+
+	// The end of the archive is not necessarily read when
+	// extracting it, so read the rest for the checksum.
+	if _, err := io.Copy(ioutil.Discard, rd); err != nil {
+		return err
+	}
+	return sum.verify(path)
+}
+
+// extractTarEntries will extract all the entries of the uncompressed
+// tar archive read from the reader into the directory.
+func extractTarEntries(zr io.Reader, path, dir string) error {
+	rd := tar.NewReader(zr)
+	for {
+		hdr, err := rd.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("Unable to unpack %s: %s", path, err)
+		}
+		if err := extractTarEntry(rd, hdr, dir); err != nil {
+			return fmt.Errorf("Unable to unpack %q from %s: %s", hdr.Name, path, err)
+		}
+	}
+}
+
+// extractZip will extract the zip archive at the path into the
+// directory. Files are written with the modes recorded in the
+// archive. If the extraction fails, the error identifies the entry
+// that could not be extracted.
+//
+// If a checksum is given, it is verified before the archive is
+// extracted. Zip archives are not read sequentially, so the archive
+// is read once for the checksum.
+func extractZip(path, dir string, sum *checksum) error {
+	if sum != nil {
+		if err := sum.verifyFile(path); err != nil {
+			return err
+		}
+	}
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("Unable to unpack %s: %s", path, err)
+	}
+	defer zr.Close()
+
+	for _, file := range zr.File {
+		if err := extractZipEntry(file, dir); err != nil {
+			return fmt.Errorf("Unable to unpack %q from %s: %s", file.Name, path, err)
+		}
+	}
+	return nil
+}
+
+// insideDir will return true if the path is the directory or a path
+// under the directory.
+func insideDir(dir, path string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// entryPath will return the path of the archive entry name under the
+// directory. Entries outside the directory are an error, and so are
+// entries that are, or are below, a symbolic link, since they would be
+// written wherever the link point to.
+func entryPath(dir, name string) (string, error) {
+	path := filepath.Join(dir, name)
+	if !insideDir(dir, path) {
+		return "", fmt.Errorf("Entry is outside of the archive directory")
+	}
+	for parent := path; insideDir(dir, parent) && parent != dir; parent = filepath.Dir(parent) {
+		if info, err := os.Lstat(parent); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("Entry is or is below the symbolic link %q", parent[len(dir)+1:])
+		}
+	}
+	return path, nil
+}
+
+// resolveLink will return the path that the link target resolve to
+// when followed from the parent directory, following the symbolic
+// links already extracted on the way. It is an error if the link
+// leave the root directory at any point.
+func resolveLink(root, parent, link string, depth int) (string, error) {
+	if depth > 40 {
+		return "", fmt.Errorf("Too many levels of symbolic links in %q", link)
+	}
+	if filepath.IsAbs(link) {
+		return "", fmt.Errorf("Link target %q is an absolute path", link)
+	}
+	path := parent
+	for _, part := range strings.Split(filepath.ToSlash(link), "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			path = filepath.Dir(path)
+		default:
+			path = filepath.Join(path, part)
+			if target, err := os.Readlink(path); err == nil {
+				path, err = resolveLink(root, filepath.Dir(path), target, depth+1)
+				if err != nil {
+					return "", err
+				}
+			}
+		}
+		if !insideDir(root, path) {
+			return "", fmt.Errorf("Link target %q is outside of the archive directory", link)
+		}
+	}
+	return path, nil
+}
+
+// makeSymlink will create a symbolic link at path pointing to the
+// link target, which has to be relative and resolve to a path inside
+// the directory.
+func makeSymlink(dir, path, link string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	parent, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	if _, err := resolveLink(root, parent, link, 0); err != nil {
+		return err
+	}
+	return os.Symlink(link, path)
+}
+
+// createFile will create a new file at the path with the contents of
+// the reader. Existing files are never written over, since that
+// could be a symbolic link created by an earlier entry.
+func createFile(path string, mode os.FileMode, rd io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, rd)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// extractTarEntry will extract a single entry of the tar archive into
+// the directory.
+func extractTarEntry(rd io.Reader, hdr *tar.Header, dir string) error {
+	target, err := entryPath(dir, hdr.Name)
+	if err != nil {
+		return err
+	}
+	mode := hdr.FileInfo().Mode().Perm()
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		return os.MkdirAll(target, mode)
+
+	case tar.TypeReg, tar.TypeRegA:
+		return createFile(target, mode, rd)
+
+	case tar.TypeSymlink:
+		return makeSymlink(dir, target, hdr.Linkname)
+
+	case tar.TypeLink:
+		source, err := entryPath(dir, hdr.Linkname)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return os.Link(source, target)
+
+	default:
+		log.Debugf("Skipping %q of type %c", hdr.Name, hdr.Typeflag)
+		return nil
+	}
+}
+
+// extractZipEntry will extract a single entry of the zip archive into
+// the directory.
+func extractZipEntry(file *zip.File, dir string) error {
+	target, err := entryPath(dir, file.Name)
+	if err != nil {
+		return err
+	}
+	mode := file.Mode()
+
+	rd, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+
+	switch {
+	case mode.IsDir():
+		return os.MkdirAll(target, mode.Perm())
+
+	case mode&os.ModeSymlink != 0:
+		link, err := ioutil.ReadAll(rd)
+		if err != nil {
+			return err
+		}
+		return makeSymlink(dir, target, string(link))
+
+	case mode.IsRegular():
+		return createFile(target, mode.Perm(), rd)
+
+	default:
+		log.Debugf("Skipping %q with mode %v", file.Name, mode)
+		return nil
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// makeTarball will create a tar archive at the path with the entries
+// given. The archive is compressed using gzip if the path has a
+// ".tar.gz" extension.
+func makeTarball(t *testing.T, path string, entries []*tar.Header, contents map[string]string) {
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Unable to create %s: %s", path, err)
+	}
+	defer file.Close()
+
+	var zw io.WriteCloser = file
+	if pathType(path) == TGZ_PATH {
+		zw = gzip.NewWriter(file)
+	}
+	tw := tar.NewWriter(zw)
+	for _, hdr := range entries {
+		content := contents[hdr.Name]
+		hdr.Size = int64(len(content))
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("Unable to write header for %s: %s", hdr.Name, err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("Unable to write %s: %s", hdr.Name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Unable to close archive: %s", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Unable to close archive: %s", err)
+	}
+}
+
+func TestUnpackTar(t *testing.T) {
+	root, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	archive := filepath.Join(root, "mysql-5.7.20.tar.gz")
+	makeTarball(t, archive, []*tar.Header{
+		{Name: "mysql-5.7.20/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "mysql-5.7.20/bin/mysqld", Typeflag: tar.TypeReg, Mode: 0755},
+		{Name: "mysql-5.7.20/share/english/errmsg.sys", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "mysql-5.7.20/lib/libmysqlclient.so", Typeflag: tar.TypeSymlink, Linkname: "libmysqlclient.so.20"},
+	}, map[string]string{
+		"mysql-5.7.20/bin/mysqld":               "#!/bin/sh\n",
+		"mysql-5.7.20/share/english/errmsg.sys": "errors",
+	})
+
+	distDir := filepath.Join(root, "dist")
+	os.Mkdir(distDir, 0755)
+	dist := &Dist{}
+	if err := dist.unpackTar(distDir, archive, "mysql-5.7.20", nil); err != nil {
+		t.Fatalf("Unable to unpack archive: %s", err)
+	}
+	if dist.Name != "mysql-5.7.20" || dist.Root != filepath.Join(distDir, "mysql-5.7.20") {
+		t.Errorf("Expected distribution mysql-5.7.20 in %s, got %s in %s", distDir, dist.Name, dist.Root)
+	}
+
+	if finfo, err := os.Stat(filepath.Join(dist.Root, "bin", "mysqld")); err != nil {
+		t.Errorf("Expected mysqld to be unpacked: %s", err)
+	} else if finfo.Mode().Perm()&0100 == 0 {
+		t.Errorf("Expected mysqld to be executable, mode was %v", finfo.Mode())
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(dist.Root, "share", "english", "errmsg.sys")); err != nil || string(data) != "errors" {
+		t.Errorf("Expected errmsg.sys to contain %q, got %q (error: %v)", "errors", data, err)
+	}
+	if link, err := os.Readlink(filepath.Join(dist.Root, "lib", "libmysqlclient.so")); err != nil || link != "libmysqlclient.so.20" {
+		t.Errorf("Expected symlink to %q, got %q (error: %v)", "libmysqlclient.so.20", link, err)
+	}
+
+	// Entries outside of the archive directory are rejected, and
+	// nothing is left behind.
+	evil := filepath.Join(root, "evil.tar.gz")
+	makeTarball(t, evil, []*tar.Header{
+		{Name: "evil/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "evil/../../escape", Typeflag: tar.TypeReg, Mode: 0644},
+	}, nil)
+	err = (&Dist{}).unpackTar(distDir, evil, "evil", nil)
+	if err == nil || !strings.Contains(err.Error(), "evil/../../escape") {
+		t.Errorf("Expected error identifying the entry, got %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(distDir, "evil")); !os.IsNotExist(err) {
+		t.Errorf("Expected no distribution after failure, got %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(root, "escape")); !os.IsNotExist(err) {
+		t.Errorf("Expected no file outside of the archive, got %v", err)
+	}
+
+	// Symbolic links out of the archive directory are rejected,
+	// and so are entries written through a symbolic link.
+	outside := filepath.Join(root, "outside")
+	os.Mkdir(outside, 0755)
+	links := map[string][]*tar.Header{
+		"absolute": {
+			{Name: "evil/a", Typeflag: tar.TypeSymlink, Linkname: outside},
+			{Name: "evil/a/x", Typeflag: tar.TypeReg, Mode: 0644},
+		},
+		"relative": {
+			{Name: "evil/lib/a", Typeflag: tar.TypeSymlink, Linkname: "../../outside"},
+			{Name: "evil/lib/a/x", Typeflag: tar.TypeReg, Mode: 0644},
+		},
+		"through": {
+			{Name: "evil/a", Typeflag: tar.TypeSymlink, Linkname: "."},
+			{Name: "evil/a/b/c", Typeflag: tar.TypeSymlink, Linkname: "../../outside"},
+			{Name: "evil/a/b/c/x", Typeflag: tar.TypeReg, Mode: 0644},
+		},
+		"resolved": {
+			{Name: "evil/d/c", Typeflag: tar.TypeSymlink, Linkname: ".."},
+			{Name: "evil/d/L", Typeflag: tar.TypeSymlink, Linkname: "c/../../../outside/pwned"},
+			{Name: "evil/d/L", Typeflag: tar.TypeReg, Mode: 0644},
+		},
+		"overwrite": {
+			{Name: "evil/d/L", Typeflag: tar.TypeSymlink, Linkname: "x"},
+			{Name: "evil/d/L", Typeflag: tar.TypeReg, Mode: 0644},
+		},
+	}
+	for name, entries := range links {
+		makeTarball(t, evil, entries, nil)
+		if err := (&Dist{}).unpackTar(distDir, evil, "evil", nil); err == nil {
+			t.Errorf("Expected error for %s symbolic link", name)
+		}
+		if files, _ := ioutil.ReadDir(outside); len(files) > 0 {
+			t.Errorf("Expected no file outside of the archive for %s symbolic link", name)
+		}
+	}
+}
+
+// makeZip will create a zip archive at the path with the contents of
+// the directory tree, placed under the top directory in the archive.
+func makeZip(t *testing.T, path, tree, top string) {
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Unable to create %s: %s", path, err)
+	}
+	defer file.Close()
+
+	zw := zip.NewWriter(file)
+	err = filepath.Walk(tree, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(tree, path)
+		if err != nil {
+			return err
+		}
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(filepath.Join(top, rel))
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		wr, err := zw.CreateHeader(hdr)
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		_, err = wr.Write(data)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Unable to add %s to archive: %s", tree, err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Unable to close archive: %s", err)
+	}
+}
+
+func TestUnpackZip(t *testing.T) {
+	root, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	tree := filepath.Join(root, "tree")
+	files := map[string]string{
+		"bin/mysqld":                    "#!/bin/sh\n",
+		"share/english/errmsg.sys":      "errors",
+		"share/mysql_system_tables.sql": "-- SQL",
+	}
+	makeDistTree(t, tree, files)
+
+	archive := filepath.Join(root, "mysql-5.7.20.zip")
+	makeZip(t, archive, tree, "mysql-5.7.20")
+
+	distDir := filepath.Join(root, "dist")
+	os.Mkdir(distDir, 0755)
+	dist := &Dist{}
+	if err := dist.unpackZip(distDir, archive, "mysql-5.7.20", nil); err != nil {
+		t.Fatalf("Unable to unpack archive: %s", err)
+	}
+	if dist.Root != filepath.Join(distDir, "mysql-5.7.20") {
+		t.Errorf("Expected root %s, got %s", filepath.Join(distDir, "mysql-5.7.20"), dist.Root)
+	}
+
+	for path, content := range files {
+		fullpath := filepath.Join(dist.Root, path)
+		if data, err := ioutil.ReadFile(fullpath); err != nil || string(data) != content {
+			t.Errorf("Expected %s to contain %q, got %q (error: %v)", path, content, data, err)
+		}
+		orig, _ := os.Stat(filepath.Join(tree, path))
+		if finfo, err := os.Stat(fullpath); err == nil && finfo.Mode() != orig.Mode() {
+			t.Errorf("Expected %s to have mode %v, got %v", path, orig.Mode(), finfo.Mode())
+		}
+	}
+}
+
+func TestUnpackTarFormats(t *testing.T) {
+	root, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	entries := func() []*tar.Header {
+		return []*tar.Header{
+			{Name: "mysql-5.7.20/bin/mysqld", Typeflag: tar.TypeReg, Mode: 0755},
+		}
+	}
+	contents := map[string]string{"mysql-5.7.20/bin/mysqld": "#!/bin/sh\n"}
+
+	// Each kind of archive is tried, with the extension stripped
+	// from the name of the distribution.
+	archives := []string{filepath.Join(root, "mysql-5.7.20.tar")}
+	makeTarball(t, archives[0], entries(), contents)
+	if _, err := exec.LookPath("xz"); err == nil {
+		xzPath := filepath.Join(root, "xz", "mysql-5.7.20.tar")
+		os.Mkdir(filepath.Dir(xzPath), 0755)
+		makeTarball(t, xzPath, entries(), contents)
+		if output, err := exec.Command("xz", xzPath).CombinedOutput(); err != nil {
+			t.Fatalf("Unable to compress %s: %s", xzPath, output)
+		}
+		archives = append(archives, xzPath+".xz")
+	}
+
+	for i, archive := range archives {
+		name, err := distName(archive)
+		if err != nil || name != "mysql-5.7.20" {
+			t.Errorf("Expected name %q for %s, got %q (error: %v)", "mysql-5.7.20", archive, name, err)
+		}
+
+		distDir := filepath.Join(root, fmt.Sprintf("dist-%d", i))
+		os.Mkdir(distDir, 0755)
+		dist := &Dist{}
+		if err := dist.unpackDist(distDir, archive, name, nil); err != nil {
+			t.Errorf("Unable to unpack %s: %s", archive, err)
+			continue
+		}
+		if data, err := ioutil.ReadFile(filepath.Join(dist.Root, "bin", "mysqld")); err != nil || string(data) != "#!/bin/sh\n" {
+			t.Errorf("Expected mysqld to be unpacked from %s, got %q (error: %v)", archive, data, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// AUDIT_FILE is the name of the audit log in the stable directory.
+const AUDIT_FILE = "audit.log"
+
+// AuditEntry is a single entry in the audit log. Each entry record
+// the words of the command that was executed, the arguments given to
+// the command (including options), and the error message, if the
+// command failed.
+type AuditEntry struct {
+	Time    time.Time
+	Command []string
+	Args    []string
+	Error   string
+}
+
+// auditFile return the name of the audit log for the stable.
+func (stable *Stable) auditFile() string {
+	return filepath.Join(stable.Root, AUDIT_FILE)
+}
+
+// Audit will append an entry for a command to the audit log, if
+// auditing is enabled for the stable. The entries are written as one
+// JSON object per line.
+func (stable *Stable) Audit(command, args []string, result error) error {
+	if !stable.Auditing {
+		return nil
+	}
+
+	entry := AuditEntry{
+		Time:    time.Now(),
+		Command: command,
+		Args:    args,
+	}
+	if result != nil {
+		entry.Error = result.Error()
+	}
+
+	// The arguments of the commands can contain sensitive
+	// information, so the audit log is only readable by the owner.
+	file, err := os.OpenFile(stable.auditFile(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if err := file.Chmod(0600); err != nil {
+		file.Close()
+		return err
+	}
+	err = json.NewEncoder(file).Encode(&entry)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// ReadAudit will read all entries of an audit log from the reader.
+func ReadAudit(rd io.Reader) ([]AuditEntry, error) {
+	entries := []AuditEntry{}
+	decoder := json.NewDecoder(rd)
+	for {
+		var entry AuditEntry
+		if err := decoder.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// configuredPidPath will return the path where mysqld will write the
+// PID file given the options of the server. A relative path is
+// relative to the data directory and if no PID file is configured,
+// mysqld use the host name in the data directory.
+func (srv *Server) configuredPidPath() string {
+	pidFile, _ := srv.Options.Get("mysqld", "pid_file")
+	if len(pidFile) == 0 {
+		host, err := os.Hostname()
+		if err != nil {
+			host = "localhost"
+		}
+		pidFile = host + ".pid"
+	}
+	if !filepath.IsAbs(pidFile) {
+		pidFile = filepath.Join(srv.DataDir, pidFile)
+	}
+	return filepath.Clean(pidFile)
+}
+
+// Check will check that the server is consistent with the options
+// it is started with and return a list of the problems found.
+func (srv *Server) Check() []error {
+	problems := []error{}
+	if srv.Options != nil {
+		pidPath := srv.configuredPidPath()
+		if pidPath != filepath.Clean(srv.PidPath) {
+			problems = append(problems,
+				fmt.Errorf("PID file is %q in options but %q for server", pidPath, srv.PidPath))
+		}
+	}
+	return problems
+}
+
+// Check will check all servers in the stable and return a list of the
+// problems found. Each problem is prefixed with the name of the
+// server it concerns.
+func (stable *Stable) Check() []error {
+	names := make([]string, 0, len(stable.Server))
+	for name := range stable.Server {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	problems := []error{}
+	for _, name := range names {
+		for _, err := range stable.Server[name].Check() {
+			problems = append(problems, fmt.Errorf("Server %s: %s", name, err))
+		}
+	}
+	return problems
+}
+
+// WaitPidFile will wait for the PID file of a newly started server to
+// appear. If it does not show up at PidPath within the timeout, but
+// there is a PID file at the location given by the options, the
+// server is updated to use that location instead.
+func (srv *Server) WaitPidFile(timeout time.Duration) error {
+	exists := func(path string) func() bool {
+		return func() bool {
+			_, err := srv.prober().Stat(path)
+			return err == nil
+		}
+	}
+
+	if srv.waitFor(timeout, exists(srv.PidPath)) {
+		return nil
+	}
+	if pidPath := srv.configuredPidPath(); exists(pidPath)() {
+		srv.PidPath = pidPath
+		return nil
+	}
+	return fmt.Errorf("No PID file for server %s after %v", srv.Name, timeout)
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"io/ioutil"
+	"mysqld/cnf"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	stable, err := CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+
+	dist := &Dist{Name: "fake", Root: filepath.Join(root, "fake"), Version: "5.6.19"}
+	srv, err := stable.newServer("one", dist)
+	if err != nil {
+		t.Fatalf("Unable to create server: %s", err)
+	}
+	stable.Server[srv.Name] = srv
+
+	if problems := stable.Check(); len(problems) > 0 {
+		t.Errorf("Expected no problems, got %v", problems)
+	}
+
+	// A relative PID file is relative to the data directory
+	srv.Options.Section["mysqld"].SetString("pid_file", "../run/mysqld.pid")
+	if problems := stable.Check(); len(problems) > 0 {
+		t.Errorf("Expected no problems, got %v", problems)
+	}
+
+	srv.Options.Section["mysqld"].SetString("pid_file", "mysqld.pid")
+	problems := stable.Check()
+	if len(problems) != 1 {
+		t.Fatalf("Expected one problem, got %v", problems)
+	}
+	expect := `Server one: PID file is "` + filepath.Join(srv.DataDir, "mysqld.pid") +
+		`" in options but "` + srv.PidPath + `" for server`
+	if problems[0].Error() != expect {
+		t.Errorf("Expected %q, got %q", expect, problems[0])
+	}
+}
+
+func TestWaitPidFile(t *testing.T) {
+	root, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	srv := &Server{
+		Name:    "one",
+		DataDir: root,
+		PidPath: filepath.Join(root, "expected.pid"),
+		Options: cnf.New(),
+	}
+	srv.Options.Import(map[string]map[string]string{
+		"mysqld": {"pid_file": "actual.pid"},
+	})
+	srv.SetPollInterval(time.Millisecond)
+
+	if err := srv.WaitPidFile(10 * time.Millisecond); err == nil {
+		t.Errorf("Expected error when there is no PID file")
+	}
+
+	// If mysqld wrote the PID file where the options say, the
+	// server should use that file instead.
+	actual := filepath.Join(root, "actual.pid")
+	ioutil.WriteFile(actual, []byte("4711\n"), 0644)
+	if err := srv.WaitPidFile(10 * time.Millisecond); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	if srv.PidPath != actual {
+		t.Errorf("PID path was %q, expected %q", srv.PidPath, actual)
+	}
+}
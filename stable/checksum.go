@@ -0,0 +1,80 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// checksumAlgorithms are the supported algorithms for verifying the
+// checksum of distribution archives.
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha256": sha256.New,
+}
+
+// checksum is used to verify the digest of an archive. The contents
+// of the archive are written to it while the archive is read.
+type checksum struct {
+	hash.Hash
+	algo, expected string
+}
+
+// newChecksum will return a checksum using the algorithm to verify
+// the expected digest, given as a hexadecimal string.
+func newChecksum(algo, expected string) (*checksum, error) {
+	newHash, ok := checksumAlgorithms[algo]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported checksum algorithm %q", algo)
+	}
+	expected = strings.ToLower(strings.TrimSpace(expected))
+	if _, err := hex.DecodeString(expected); err != nil || len(expected) != 2*newHash().Size() {
+		return nil, fmt.Errorf("Invalid %s digest %q", algo, expected)
+	}
+	return &checksum{newHash(), algo, expected}, nil
+}
+
+// verify will check that the digest of the contents written match the
+// expected digest.
+func (sum *checksum) verify(path string) error {
+	if digest := hex.EncodeToString(sum.Sum(nil)); digest != sum.expected {
+		return fmt.Errorf("Checksum mismatch for %s: expected %s digest %s, got %s",
+			path, sum.algo, sum.expected, digest)
+	}
+	return nil
+}
+
+// verifyFile will compute the digest of the file at the path and
+// check that it match the expected digest.
+func (sum *checksum) verifyFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := io.Copy(sum, file); err != nil {
+		return err
+	}
+	return sum.verify(path)
+}
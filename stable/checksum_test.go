@@ -0,0 +1,88 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"archive/tar"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAddDistChecksum(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	stable, err := CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+
+	mysqld := "#!/bin/sh\necho 'mysqld  Ver 5.7.20 for linux-glibc2.12 on x86_64'\n"
+	archive := filepath.Join(root, "mysql-5.7.20.tar.gz")
+	makeTarball(t, archive, []*tar.Header{
+		{Name: "mysql-5.7.20/bin/mysqld", Typeflag: tar.TypeReg, Mode: 0755},
+	}, map[string]string{"mysql-5.7.20/bin/mysqld": mysqld})
+
+	data, err := ioutil.ReadFile(archive)
+	if err != nil {
+		t.Fatalf("Unable to read archive: %s", err)
+	}
+	sha := sha256.Sum256(data)
+	md := md5.Sum(data)
+	digest := hex.EncodeToString(sha[:])
+
+	// A digest that does not match leaves nothing behind
+	wrong := strings.Repeat("0", len(digest))
+	if _, err := stable.AddDistChecksum(archive, "sha256", wrong); err == nil || !strings.Contains(err.Error(), digest) {
+		t.Errorf("Expected checksum mismatch reporting %s, got %v", digest, err)
+	}
+	if _, err := os.Lstat(filepath.Join(stable.distDir, "mysql-5.7.20")); !os.IsNotExist(err) {
+		t.Errorf("Expected no distribution after checksum mismatch, got %v", err)
+	}
+	if len(stable.Distro) != 0 {
+		t.Errorf("Expected no distribution added, got %v", stable.Distro)
+	}
+
+	// Bad algorithms and digests are rejected
+	if _, err := stable.AddDistChecksum(archive, "sha1", digest); err == nil {
+		t.Errorf("Expected error for unsupported algorithm")
+	}
+	if _, err := stable.AddDistChecksum(archive, "sha256", "xyz"); err == nil {
+		t.Errorf("Expected error for invalid digest")
+	}
+
+	if _, err := stable.AddDistChecksum(archive, "sha256", strings.ToUpper(digest)); err != nil {
+		t.Errorf("Unable to add distribution with matching checksum: %s", err)
+	}
+	if _, err := stable.AddDistNamedChecksum(archive, "md5", "md5", hex.EncodeToString(md[:])); err != nil {
+		t.Errorf("Unable to add distribution with matching checksum: %s", err)
+	}
+
+	// Directories do not have checksums
+	if _, err := stable.AddDistNamedChecksum(root, "dir", "sha256", digest); err == nil {
+		t.Errorf("Expected error for checksum of directory")
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"fmt"
+	"io"
+	"mysqld/cnf"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cloneSkipFiles are files in the data directory that are not copied
+// when cloning a server. The auto.cnf file hold the server UUID, which
+// has to be unique for servers that replicate.
+var cloneSkipFiles = []string{"auto.cnf"}
+
+// CloneServer will add a new server under a name with a copy of the
+// data directory of the source server. The new server uses the same
+// distribution and options as the source server, but get a fresh
+// port, server id, socket, and PID and log files. The source server
+// has to be stopped.
+func (stable *Stable) CloneServer(src *Server, name string) (*Server, error) {
+	if _, exists := stable.Server[name]; exists {
+		return nil, fmt.Errorf("Server %q already exists", name)
+	}
+	if src.Status() == SERVER_RUNNING {
+		return nil, fmt.Errorf("Server %s is running", src.Name)
+	}
+
+	server, err := stable.newServer(name, src.Dist)
+	if err != nil {
+		return nil, err
+	}
+	server.User = src.User
+	server.Password = src.Password
+	if len(src.StartLimits) > 0 {
+		server.StartLimits = make(map[string]uint64)
+		for name, value := range src.StartLimits {
+			server.StartLimits[name] = value
+		}
+	}
+	if len(src.Env) > 0 {
+		server.Env = make(map[string]string)
+		for name, value := range src.Env {
+			server.Env[name] = value
+		}
+	}
+
+	// Options of the source server are kept, except those
+	// specific to the source server, which are replaced with the
+	// options of the new server.
+	options := cnf.New()
+	if src.Options != nil {
+		options.Merge(src.Options)
+	}
+	if sec, ok := options.Section["mysqld"]; ok {
+		sec.Remove("log_error")
+	}
+	options.Merge(server.Options)
+	server.Options = options
+
+	if err := server.setup(stable); err != nil {
+		os.RemoveAll(server.BaseDir)
+		stable.releaseServer(server)
+		return nil, err
+	}
+	if err := copyDataDir(src.DataDir, server.DataDir); err != nil {
+		os.RemoveAll(server.BaseDir)
+		stable.releaseServer(server)
+		return nil, err
+	}
+
+	stable.Server[name] = server
+	return server, nil
+}
+
+// copyDataDir will copy the contents of a data directory into another
+// data directory. PID files, sockets, and the files in cloneSkipFiles
+// are not copied.
+func copyDataDir(srcDir, dstDir string) error {
+	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if skipCloneFile(rel) {
+			return nil
+		}
+
+		target := filepath.Join(dstDir, rel)
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			return os.Mkdir(target, mode.Perm())
+		case mode&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case mode.IsRegular():
+			return copyFile(path, target, mode.Perm())
+		}
+		return nil
+	})
+}
+
+// skipCloneFile will return true if the file, relative to the data
+// directory, should not be copied when cloning a server.
+func skipCloneFile(rel string) bool {
+	if strings.HasSuffix(rel, ".pid") {
+		return true
+	}
+	for _, name := range cloneSkipFiles {
+		if rel == name {
+			return true
+		}
+	}
+	return false
+}
+
+// copyFile will copy the contents of the file to a new file with the
+// permissions given.
+func copyFile(srcPath, dstPath string, perm os.FileMode) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
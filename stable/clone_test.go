@@ -0,0 +1,121 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"io/ioutil"
+	"mysqld/cnf"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCloneServer(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	stable, err := CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+
+	dist := &Dist{Name: "fake", Root: filepath.Join(root, "fake")}
+	src, err := stable.newServer("source", dist)
+	if err != nil {
+		t.Fatalf("Unable to create server: %s", err)
+	}
+	src.Options.Section["mysqld"].SetString("log_bin", "master-bin")
+	if err := src.setup(stable); err != nil {
+		t.Fatalf("Unable to set up server: %s", err)
+	}
+	stable.Server[src.Name] = src
+	files := map[string]string{
+		"ibdata1":        "data",
+		"mysql/user.frm": "user",
+		"auto.cnf":       "[auto]\nserver_uuid=1234\n",
+		"source.pid":     "4711\n",
+	}
+	for path, content := range files {
+		fullpath := filepath.Join(src.DataDir, path)
+		os.MkdirAll(filepath.Dir(fullpath), 0755)
+		ioutil.WriteFile(fullpath, []byte(content), 0644)
+	}
+
+	// A running server cannot be cloned
+	probe := newFakeProbe()
+	src.SetProbe(probe)
+	probe.start(t, src, 4711, time.Hour)
+	if _, err := stable.CloneServer(src, "clone"); err == nil {
+		t.Errorf("Expected error when cloning running server")
+	}
+	os.Remove(src.PidPath)
+
+	dst, err := stable.CloneServer(src, "clone")
+	if err != nil {
+		t.Fatalf("Unable to clone server: %s", err)
+	}
+	if stable.Server["clone"] != dst {
+		t.Errorf("Clone not registered in stable")
+	}
+	if dst.Port == src.Port || dst.ServerId == src.ServerId || dst.Socket == src.Socket {
+		t.Errorf("Clone share port, server id, or socket with source: %v and %v", dst, src)
+	}
+	if dst.PidPath == src.PidPath || dst.LogPath == src.LogPath {
+		t.Errorf("Clone share PID or log file with source")
+	}
+
+	// Data files are copied, but not the server UUID or PID files
+	for path, content := range files {
+		data, err := ioutil.ReadFile(filepath.Join(dst.DataDir, path))
+		switch path {
+		case "auto.cnf", "source.pid":
+			if err == nil {
+				t.Errorf("Expected %s not to be copied", path)
+			}
+		default:
+			if err != nil || string(data) != content {
+				t.Errorf("Expected %s to contain %q, got %q (error: %v)", path, content, data, err)
+			}
+		}
+	}
+
+	// Options of the source are kept, but not those specific to
+	// the source server.
+	options, err := cnf.ReadFile(dst.ConfigFile)
+	if err != nil {
+		t.Fatalf("Unable to read options of clone: %s", err)
+	}
+	mysqld := options.Section["mysqld"]
+	if value := mysqld.GetString("log_bin"); value != "master-bin" {
+		t.Errorf("Expected log_bin to be kept, got %q", value)
+	}
+	if value := mysqld.GetString("datadir"); value != dst.DataDir {
+		t.Errorf("Expected datadir %q, got %q", dst.DataDir, value)
+	}
+	if value := mysqld.GetString("server_id"); value != strconv.Itoa(dst.ServerId) {
+		t.Errorf("Expected server_id %d, got %q", dst.ServerId, value)
+	}
+
+	if _, err := stable.CloneServer(src, "clone"); err == nil {
+		t.Errorf("Expected error when cloning to existing server")
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// LIMIT_SHIM is the program name used when the running program is
+// executed again to set the resource limits of a server before
+// executing it, see forkDaemon.
+const LIMIT_SHIM = "mysqld-limit-shim"
+
+// The limit shim has to run before anything else in the program, so
+// it is checked for when the package is initialized.
+func init() {
+	if len(os.Args) > 2 && os.Args[0] == LIMIT_SHIM {
+		runLimitShim(os.Args[1], os.Args[2], os.Args[3:])
+	}
+}
+
+// runLimitShim will set the resource limits, given in JSON form, and
+// then execute the binary with the arguments. If either fails, the
+// error is written to file descriptor 3, which is otherwise closed
+// when the binary is executed, and the process exits.
+func runLimitShim(binPath, encoded string, argv []string) {
+	report := os.NewFile(3, "report")
+	syscall.CloseOnExec(3)
+	fail := func(err error) {
+		fmt.Fprint(report, err)
+		os.Exit(1)
+	}
+
+	var limits []ResourceLimit
+	if err := json.Unmarshal([]byte(encoded), &limits); err != nil {
+		fail(err)
+	}
+	for _, limit := range limits {
+		if err := syscall.Setrlimit(limit.Resource, &limit.Rlimit); err != nil {
+			fail(fmt.Errorf("Unable to set resource limit %d: %s", limit.Resource, err))
+		}
+	}
+	err := syscall.Exec(binPath, argv, os.Environ())
+	fail(fmt.Errorf("Unable to execute %s: %s", binPath, err))
+}
+
+// forkDaemon will start a server as a daemon. The path to the binary
+// to execute is given by binPath and the arguments, including the
+// program name, by argv. The current directory of the daemon will
+// be runDir and the file where standard output and standard error
+// will be directed is given by outPath. Note that the outPath will
+// be opened in append mode, and created if it does not exists. The
+// server is executed with the environment in env in a session of its
+// own, with standard input read from /dev/null.
+//
+// If there are resource limits, the running program is executed
+// again as a shim that set the limits and then execute the binary,
+// so the limits are in place before the server start. The server
+// process is waited for in the background, so that it does not
+// linger as a zombie if it exits while this program is running.
+func forkDaemon(binPath, runDir, outPath string, argv, env []string, limits []ResourceLimit) error {
+	out, err := os.OpenFile(outPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	cmd := &exec.Cmd{
+		Path:        binPath,
+		Args:        argv,
+		Env:         env,
+		Dir:         runDir,
+		Stdout:      out,
+		Stderr:      out,
+		SysProcAttr: &syscall.SysProcAttr{Setsid: true},
+	}
+	if len(limits) == 0 {
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("Unable to execute %s: %s", binPath, err)
+		}
+		go cmd.Wait()
+		return nil
+	}
+
+	shim, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(limits)
+	if err != nil {
+		return err
+	}
+	rd, wr, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+	cmd.Path = shim
+	cmd.Args = append([]string{LIMIT_SHIM, binPath, string(encoded)}, argv...)
+	cmd.ExtraFiles = []*os.File{wr}
+	err = cmd.Start()
+	wr.Close()
+	if err != nil {
+		return fmt.Errorf("Unable to execute %s: %s", shim, err)
+	}
+
+	// The report is closed without anything written to it when
+	// the binary is executed.
+	report, err := ioutil.ReadAll(rd)
+	if err != nil || len(report) > 0 {
+		cmd.Wait()
+		if err == nil {
+			err = errors.New(string(report))
+		}
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
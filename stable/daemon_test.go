@@ -0,0 +1,70 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestForkDaemonExit(t *testing.T) {
+	root, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	// A daemon that exits while this program is running should
+	// not be reported as alive.
+	pidPath := filepath.Join(root, "mysqld.pid")
+	binPath := filepath.Join(root, "mysqld")
+	script := "#!/bin/sh\necho $$ > " + pidPath + ".tmp\nmv " + pidPath + ".tmp " + pidPath + "\n"
+	if err := ioutil.WriteFile(binPath, []byte(script), 0755); err != nil {
+		t.Fatalf("Unable to write script: %s", err)
+	}
+	err = forkDaemon(binPath, root, filepath.Join(root, "mysqld.err"), []string{"mysqld"}, nil, nil)
+	if err != nil {
+		t.Fatalf("Unable to start daemon: %s", err)
+	}
+
+	pid := 0
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if content, err := ioutil.ReadFile(pidPath); err == nil {
+			if pid, err = strconv.Atoi(strings.TrimSpace(string(content))); err != nil {
+				t.Fatalf("Bad PID %q: %s", content, err)
+			}
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if pid == 0 {
+		t.Fatalf("Daemon did not write its PID")
+	}
+	for time.Now().Before(deadline) {
+		if !(systemProbe{}).Alive(pid) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Daemon with PID %d still alive after exiting", pid)
+}
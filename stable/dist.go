@@ -29,11 +29,14 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"mysqld/cnf"
 	"mysqld/log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -42,38 +45,110 @@ import (
 type Dist struct {
 	Root                         string
 	Name, Version, ServerVersion string
-	stable                       *Stable
-	defaultPort                  int
+
+	// Flavor is the flavor of the server, one of FLAVOR_MYSQL,
+	// FLAVOR_MARIADB, or FLAVOR_PERCONA. Distributions added
+	// before the flavor was recorded have an empty flavor and are
+	// treated as MySQL.
+	Flavor string
+
+	// Size is the cached install size of the distribution, or
+	// zero if it has not been computed.
+	Size int64
+
+	// LibDir is the directory with shared libraries bundled with
+	// the distribution, or empty if there is none.
+	LibDir string
+
+	// BinDir, SbinDir, and ShareDir are set for distributions
+	// where the files are not in a single directory tree, such as
+	// the system installation. If BinDir or ShareDir are empty,
+	// the "bin" and "share" directories under Root are used. If
+	// SbinDir is set, binaries found there are used before the
+	// ones in BinDir.
+	BinDir, SbinDir, ShareDir string
+
+	stable      *Stable
+	defaultPort int
 }
 
-// validateTar check a tar archive (compressed or not) to ensure that
-// it has all the components needed to bootstrap a slave.
-func (dt *Dist) unpackTar(root, path string) error {
-	base := filepath.Base(path)
-	dt.Name = strings.TrimSuffix(base, ".tar.gz")
-	dt.Root = filepath.Join(root, dt.Name)
+// extractArchive will extract an archive into the directory root
+// using the extract function, which is called with the directory to
+// extract the archive into. The archive is first extracted into a
+// temporary directory under root, which is moved into place only if
+// the extraction succeeds, so a failed extraction never leaves a
+// partially extracted distribution behind. If the archive contains a
+// single top directory, that directory is the distribution.
+func (dt *Dist) extractArchive(root, name string, extract func(dir string) error) error {
+	target := filepath.Join(root, name)
+	if _, err := os.Lstat(target); err == nil {
+		return fmt.Errorf("Distribution %q already exists", name)
+	}
 
-	// Extract the contents of the library
-	cmd := exec.Command("tar", "xzf", path, "-C", root)
-	if err := cmd.Run(); err != nil {
+	tmpDir, err := ioutil.TempDir(root, ".unpack-")
+	if err != nil {
 		return err
 	}
-	return nil
-}
+	defer os.RemoveAll(tmpDir)
 
-func (dt *Dist) unpackZip(root, path string) error {
-	base := filepath.Base(path)
-	dt.Name = strings.TrimSuffix(base, ".zip")
-	dt.Root = filepath.Join(root, dt.Name)
+	if err := extract(tmpDir); err != nil {
+		return err
+	}
 
-	// Extract the contents of the library
-	cmd := exec.Command("unzip", "-qq", "-d", dt.Name, path)
-	if err := cmd.Run(); err != nil {
+	source := tmpDir
+	if entries, err := ioutil.ReadDir(tmpDir); err != nil {
 		return err
+	} else if len(entries) == 1 && entries[0].IsDir() {
+		source = filepath.Join(tmpDir, entries[0].Name())
 	}
+
+	// The temporary directory is created with restricted
+	// permissions, so fix them if it is moved into place.
+	if source == tmpDir {
+		if err := os.Chmod(source, 0755); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(source, target); err != nil {
+		return err
+	}
+	dt.Name = name
+	dt.Root = target
 	return nil
 }
 
+// unpackTar will unpack a tar archive, which can be uncompressed or
+// compressed using gzip, bzip2, or xz.
+func (dt *Dist) unpackTar(root, path, name string, sum *checksum) error {
+	return dt.extractArchive(root, name, func(dir string) error {
+		return extractTar(path, dir, sum)
+	})
+}
+
+func (dt *Dist) unpackZip(root, path, name string, sum *checksum) error {
+	return dt.extractArchive(root, name, func(dir string) error {
+		return extractZip(path, dir, sum)
+	})
+}
+
+// distName will return the default name of a distribution added from
+// the path, which is the name of the directory or archive without
+// the archive extension.
+func distName(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	name := filepath.Base(path)
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext), nil
+		}
+	}
+	return name, nil
+}
+
 type DistType int
 
 const (
@@ -82,14 +157,24 @@ const (
 	TAR_PATH
 	ZIP_PATH
 	DIR_PATH
+	TBZ2_PATH
+	TXZ_PATH
 )
 
+// archiveExtensions are the extensions of the archives that can be
+// used as distributions.
+var archiveExtensions = []string{".tar.gz", ".tar.bz2", ".tar.xz", ".tar", ".zip"}
+
 // pathType will check the name of the of the file and based on this
 // decide what type of distribution it is.
 func pathType(path string) DistType {
 	base := filepath.Base(path)
 	if isTgz, _ := filepath.Match("*.tar.gz", base); isTgz {
 		return TGZ_PATH
+	} else if isTbz2, _ := filepath.Match("*.tar.bz2", base); isTbz2 {
+		return TBZ2_PATH
+	} else if isTxz, _ := filepath.Match("*.tar.xz", base); isTxz {
+		return TXZ_PATH
 	} else if isTar, _ := filepath.Match("*.tar", base); isTar {
 		return TAR_PATH
 	} else if isZip, _ := filepath.Match("*.zip", base); isZip {
@@ -102,19 +187,29 @@ func pathType(path string) DistType {
 
 // unpackFrom will ensure that the distribution is unpacked and
 // installed in the distribution tree under the root directory for
-// distributions. If this function finishes successfully, nil is
-// returned, otherwise, an error is returned.
-func (dt *Dist) unpackDist(root, path string) error {
+// distributions, using the name for the directory. If this function
+// finishes successfully, nil is returned, otherwise, an error is
+// returned. If a checksum is given, the archive has to match it.
+func (dt *Dist) unpackDist(root, path, name string, sum *checksum) error {
 	log.Infof("Unpacking distribution %s into %s\n", path, root)
 	switch pathType(path) {
-	case TGZ_PATH:
-		return dt.unpackTar(root, path)
+	case TGZ_PATH, TAR_PATH, TBZ2_PATH, TXZ_PATH:
+		return dt.unpackTar(root, path, name, sum)
 	case ZIP_PATH:
-		return dt.unpackZip(root, path)
+		return dt.unpackZip(root, path, name, sum)
 	case DIR_PATH:
-		dt.Name = filepath.Base(path)
-		dt.Root = filepath.Join(root, dt.Name)
-		os.Symlink(path, dt.Name)
+		if sum != nil {
+			return fmt.Errorf("Checksum can only be verified for archives, %s is a directory", path)
+		}
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		if err := os.Symlink(path, filepath.Join(root, name)); err != nil {
+			return err
+		}
+		dt.Name = name
+		dt.Root = filepath.Join(root, name)
 		return nil
 	default:
 		return ErrInvalidDist
@@ -133,6 +228,10 @@ var includeFiles = []string{
 	"include/mysql_version.h",
 }
 
+var installDbFiles = []string{
+	"scripts/mysql_install_db",
+}
+
 func (dt *Dist) scanVersionFile(src io.Reader) (outerr error) {
 	// Scan the file to find the version. Right now, only the
 	// server version is extracted but there could be other
@@ -170,12 +269,51 @@ func (dt *Dist) checkDistFiles(files []string) error {
 	return nil
 }
 
+// Flavors of servers that distributions can contain.
+const (
+	FLAVOR_MYSQL   = "MySQL"
+	FLAVOR_MARIADB = "MariaDB"
+	FLAVOR_PERCONA = "Percona"
+)
+
+// versionFlavor will return the flavor of the server given the version
+// string printed by the server.
+func versionFlavor(version string) string {
+	switch {
+	case strings.Contains(version, "MariaDB"):
+		return FLAVOR_MARIADB
+	case strings.Contains(version, "Percona"):
+		return FLAVOR_PERCONA
+	default:
+		return FLAVOR_MYSQL
+	}
+}
+
 // Example: "mysqld  Ver 5.5.32-0ubuntu0.12.04.1-log for debian-linux-gnu on i686 ((Ubuntu))"
+// Example: "mysqld  Ver 10.6.12-MariaDB for Linux on x86_64 (MariaDB Server)"
+// Example: "mysqld  Ver 8.0.32-24 for Linux on x86_64 (Percona Server (GPL), Release 24, Revision e5c6e9d2)"
 func (dt *Dist) parseVersionString(version string) {
 	re := regexp.MustCompile(`^\S+\s+Ver\s+(\d+\.\d+\.\d+\S*)\s+for\s+(\S+)`)
 	if match := re.FindStringSubmatch(version); match != nil {
 		dt.ServerVersion = match[1]
+		dt.Flavor = versionFlavor(version)
+	}
+}
+
+// mysqlVersion will return the version of MySQL that the version of
+// the distribution correspond to, which is used to decide what the
+// server support. MariaDB use its own version numbers from 10.0,
+// which correspond roughly to MySQL 5.6 since MariaDB does not
+// support the features added in MySQL 5.7, such as --initialize.
+func (dt *Dist) mysqlVersion() string {
+	version := dt.Version
+	if len(version) == 0 {
+		version = dt.ServerVersion
+	}
+	if dt.Flavor == FLAVOR_MARIADB && compareVersions(version, "10.0") >= 0 {
+		return "5.6.0"
 	}
+	return version
 }
 
 // readVersionFile will extract information from the version file of
@@ -192,7 +330,7 @@ func (dt *Dist) readVersionFile() error {
 }
 
 func (dt *Dist) readServerInfo() error {
-	mysqld := filepath.Join(dt.Root, "bin", "mysqld")
+	mysqld := dt.binary("mysqld")
 	if ver, err := exec.Command(mysqld, "--version").Output(); err != nil {
 		return err
 	} else {
@@ -201,6 +339,176 @@ func (dt *Dist) readServerInfo() error {
 	return nil
 }
 
+// defaultOptions will return the options that all servers using the
+// distribution start with, which depend on the version of the
+// distribution.
+func (dt *Dist) defaultOptions() *cnf.Config {
+	options := cnf.New()
+	mysqld, _ := options.AddSection("mysqld")
+
+	version := dt.mysqlVersion()
+	if compareVersions(version, "5.1.6") >= 0 {
+		mysqld.SetString("log_output", "file")
+	}
+
+	// Set up the language configuration correctly for the version of the server.
+	if compareVersions(version, "5.5.0") <= 0 {
+		mysqld.SetString("language", filepath.Join(dt.shareDir(), "english"))
+	} else {
+		mysqld.SetString("lc_messages_dir", dt.shareDir())
+		mysqld.SetString("lc_messages", "en_US")
+	}
+	return options
+}
+
+// binDir will return the directory containing the binaries of the
+// distribution.
+func (dt *Dist) binDir() string {
+	if len(dt.BinDir) > 0 {
+		return dt.BinDir
+	}
+	return filepath.Join(dt.Root, "bin")
+}
+
+// binary will return the path to the binary with the name.
+func (dt *Dist) binary(name string) string {
+	if len(dt.SbinDir) > 0 {
+		path := filepath.Join(dt.SbinDir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(dt.binDir(), name)
+}
+
+// shareDir will return the directory containing the SQL files and
+// error messages of the distribution.
+func (dt *Dist) shareDir() string {
+	if len(dt.ShareDir) > 0 {
+		return dt.ShareDir
+	}
+	return filepath.Join(dt.Root, "share")
+}
+
+// Binaries will return the names of all executables available in the
+// distribution, sorted by name.
+func (dt *Dist) Binaries() ([]string, error) {
+	dir := dt.binDir()
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	binaries := []string{}
+	for _, entry := range entries {
+		// Stat the file to follow any symbolic links, since
+		// some distributions link the binaries.
+		finfo, err := os.Stat(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if finfo.Mode().IsRegular() && finfo.Mode().Perm()&0111 != 0 {
+			binaries = append(binaries, entry.Name())
+		}
+	}
+	return binaries, nil
+}
+
+// versionNumbers will return the numeric components of the version
+// string, ignoring any suffix. For example, "5.6.14-log" will give
+// [5 6 14].
+func versionNumbers(version string) []int {
+	numbers := []int{}
+	for _, part := range strings.Split(version, ".") {
+		end := 0
+		for end < len(part) && '0' <= part[end] && part[end] <= '9' {
+			end++
+		}
+		if end == 0 {
+			break
+		}
+		number, _ := strconv.Atoi(part[:end])
+		numbers = append(numbers, number)
+		if end < len(part) {
+			break
+		}
+	}
+	return numbers
+}
+
+// compareVersions will compare two version strings numerically,
+// returning -1, 0, or 1 if the first version is less than, equal to,
+// or greater than the second version. Missing components are treated
+// as zero.
+func compareVersions(a, b string) int {
+	lhs, rhs := versionNumbers(a), versionNumbers(b)
+	for i := 0; i < len(lhs) || i < len(rhs); i++ {
+		var x, y int
+		if i < len(lhs) {
+			x = lhs[i]
+		}
+		if i < len(rhs) {
+			y = rhs[i]
+		}
+		if x < y {
+			return -1
+		} else if x > y {
+			return 1
+		}
+	}
+	return 0
+}
+
+// InitMethod is the method used to initialize the data directory of
+// a new server.
+type InitMethod int
+
+const (
+	// Feed the system tables to "mysqld --bootstrap"
+	INIT_BOOTSTRAP InitMethod = iota
+	// Run the "scripts/mysql_install_db" script
+	INIT_INSTALL_DB
+	// Run "mysqld --initialize-insecure"
+	INIT_INITIALIZE
+)
+
+// hasFiles will return true if all the files exist in the
+// distribution.
+func (dt *Dist) hasFiles(files []string) bool {
+	return dt.checkDistFiles(files) == nil
+}
+
+// InitMethod will return the method to use for initializing the data
+// directory of servers created from the distribution.
+//
+// Servers from version 5.7.6 use "mysqld --initialize", while earlier
+// versions are bootstrapped using the SQL files for the system tables
+// that are shipped with the distribution. If the SQL files are not
+// available, the "mysql_install_db" script is used instead.
+//
+// If the version of the distribution is unknown, the help text of the
+// server is checked for the "--initialize" option. MariaDB does not
+// support "--initialize", regardless of version.
+func (dt *Dist) InitMethod() InitMethod {
+	version := dt.mysqlVersion()
+	if len(version) > 0 {
+		if compareVersions(version, "5.7.6") >= 0 {
+			return INIT_INITIALIZE
+		}
+	} else {
+		mysqld := dt.binary("mysqld")
+		help, _ := exec.Command(mysqld, "--verbose", "--help").Output()
+		if strings.Contains(string(help), "--initialize") {
+			return INIT_INITIALIZE
+		}
+	}
+
+	if !dt.hasFiles(bootstrapFiles) && dt.hasFiles(installDbFiles) {
+		return INIT_INSTALL_DB
+	}
+	return INIT_BOOTSTRAP
+}
+
 // newDist is used to create a new distribution memory structure.
 func (stable *Stable) newDist() (*Dist, error) {
 	dist := &Dist{
@@ -210,29 +518,80 @@ func (stable *Stable) newDist() (*Dist, error) {
 	return dist, nil
 }
 
-func (dt *Dist) setup(stable *Stable, path string) error {
+func (dt *Dist) setup(stable *Stable, path, name string, sum *checksum) error {
 	// Unpack the distribution into the stable.
-	if err := dt.unpackDist(stable.distDir, path); err != nil {
+	if err := dt.unpackDist(stable.distDir, path, name, sum); err != nil {
 		return err
 	}
 
-	// Check that all files needed exists
-	if err := dt.checkDistFiles(sqlFiles); err != nil {
+	dt.findLibDir()
+
+	// Extract information from the distribution.
+	if err := dt.readVersion(); err != nil {
 		return err
 	}
-	if err := dt.checkDistFiles(includeFiles); err != nil {
+
+	// Check that the files needed to initialize servers exist
+	return dt.checkInitFiles()
+}
+
+// checkInitFiles will check that the files needed to initialize the
+// data directory of servers exist in the distribution. Which files
+// are needed depend on the initialization method: distributions
+// using "mysqld --initialize" do not ship the SQL files for the
+// system tables.
+func (dt *Dist) checkInitFiles() error {
+	switch dt.InitMethod() {
+	case INIT_INITIALIZE:
+		_, err := os.Stat(dt.binary("mysqld"))
 		return err
+	case INIT_INSTALL_DB:
+		return dt.checkDistFiles(installDbFiles)
+	default:
+		return dt.checkDistFiles(bootstrapFiles)
 	}
+}
 
-	// Extract information from the distribution.
-	if err := dt.readVersionFile(); err != nil {
-		return err
+// findLibDir will record the directory with shared libraries, if the
+// distribution bundle the libraries that the server need, as
+// relocatable distributions do.
+func (dt *Dist) findLibDir() {
+	libDir := filepath.Join(dt.Root, "lib")
+	if finfo, err := os.Stat(libDir); err == nil && finfo.IsDir() {
+		dt.LibDir = libDir
+	}
+}
+
+// readVersion will read the version of the distribution from the
+// include file and the server version from the server. Binary-only
+// distributions may lack the include file, in which case the version
+// is taken from the server version instead. It is an error if neither
+// gives a version.
+func (dt *Dist) readVersion() error {
+	if dt.hasFiles(includeFiles) {
+		if err := dt.readVersionFile(); err != nil {
+			return err
+		}
 	}
 
 	if err := dt.readServerInfo(); err != nil {
 		return err
 	}
+	if strings.Contains(dt.Version, "MariaDB") {
+		dt.Flavor = FLAVOR_MARIADB
+	}
 
+	if len(dt.Version) == 0 {
+		numbers := versionNumbers(dt.ServerVersion)
+		if len(numbers) == 0 {
+			return ErrVersionNotFound
+		}
+		parts := make([]string, len(numbers))
+		for i, number := range numbers {
+			parts[i] = strconv.Itoa(number)
+		}
+		dt.Version = strings.Join(parts, ".")
+	}
 	return nil
 }
 
@@ -241,8 +600,73 @@ func (dt *Dist) setup(stable *Stable, path string) error {
 // it can be either a tar file, an unpacked directory, or a zip file
 // with the binary distribution.  If it is a archive of any form, it
 // is unpacked into the stable, but if it is a directory, a soft link
-// is created in the stable to the real directory.
+// is created in the stable to the real directory. If the path is an
+// HTTP or HTTPS URL, the archive is downloaded first.
+//
+// The distribution is named after the directory or the archive, see
+// AddDistNamed to give it a different name.
 func (stable *Stable) AddDist(path string) (*Dist, error) {
+	return stable.AddDistNamed(path, "")
+}
+
+// AddDistNamed is used to create a new distribution from some source
+// given by the path, in the same way as AddDist, but naming the
+// distribution name instead. If name is empty, the name of the
+// directory or archive is used. It is an error if there is already a
+// distribution with the name.
+func (stable *Stable) AddDistNamed(path, name string) (*Dist, error) {
+	return stable.addDist(path, name, nil)
+}
+
+// AddDistChecksum is used to create a new distribution from an
+// archive in the same way as AddDist, but the archive is first
+// verified to have the expected digest using the algorithm, which is
+// either "sha256" or "md5". If the digest does not match, nothing is
+// added to the stable.
+func (stable *Stable) AddDistChecksum(path, algo, expected string) (*Dist, error) {
+	return stable.AddDistNamedChecksum(path, "", algo, expected)
+}
+
+// AddDistNamedChecksum is used to create a new distribution from an
+// archive, verifying the digest of the archive as AddDistChecksum and
+// naming the distribution as AddDistNamed.
+func (stable *Stable) AddDistNamedChecksum(path, name, algo, expected string) (*Dist, error) {
+	sum, err := newChecksum(algo, expected)
+	if err != nil {
+		return nil, err
+	}
+	return stable.addDist(path, name, sum)
+}
+
+func (stable *Stable) addDist(path, name string, sum *checksum) (*Dist, error) {
+	if len(name) == 0 {
+		var err error
+		if name, err = distName(path); err != nil {
+			return nil, err
+		}
+	}
+	if name == "." || name == ".." || strings.ContainsRune(name, filepath.Separator) {
+		return nil, fmt.Errorf("Invalid distribution name %q", name)
+	}
+	if _, exists := stable.Distro[name]; exists {
+		return nil, fmt.Errorf("Distribution %q already exists", name)
+	}
+
+	// A tree left in the distribution directory, for example by an
+	// earlier attempt that was interrupted, is never overwritten.
+	if _, err := os.Lstat(filepath.Join(stable.distDir, name)); err == nil {
+		return nil, fmt.Errorf("Distribution %q already exists in %s", name, stable.distDir)
+	}
+
+	if isURL(path) {
+		file, err := stable.download(path)
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(file)
+		path = file
+	}
+
 	dt, err := stable.newDist()
 	if err != nil {
 		return nil, err
@@ -250,9 +674,10 @@ func (stable *Stable) AddDist(path string) (*Dist, error) {
 
 	// Try to set up the distribution. If it is not possible due
 	// to some error, the distribution is removed and the error
-	// reported.
-	if err := dt.setup(stable, path); err != nil {
-		if len(dt.Root) == 0 {
+	// reported. The root is only set once the distribution is in
+	// place, so an existing distribution is never removed.
+	if err := dt.setup(stable, path, name, sum); err != nil {
+		if len(dt.Root) > 0 {
 			os.RemoveAll(dt.Root)
 		}
 		return nil, err
@@ -262,19 +687,78 @@ func (stable *Stable) AddDist(path string) (*Dist, error) {
 	return dt, nil
 }
 
-// DelDist will remove the distribution from the stable, including all
-// servers using the distribution.
+// distsByName sort distributions by name.
+type distsByName []*Dist
+
+func (s distsByName) Len() int           { return len(s) }
+func (s distsByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+func (s distsByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// FindMatchingDists find all distributions matching any of the
+// patterns in the slice, in the same way as FindMatchingServers. The
+// only possible returned error is filepath.ErrBadPattern. Otherwise,
+// an array of matching distributions is returned, sorted by name.
+func (stable *Stable) FindMatchingDists(patterns []string) ([]*Dist, error) {
+	var dists []*Dist
+	seen := make(map[string]bool)
+
+	for _, pattern := range patterns {
+		for name, dist := range stable.Distro {
+			matched, err := filepath.Match(pattern, name)
+			if err != nil {
+				return nil, err
+			} else if matched && !seen[name] {
+				dists = append(dists, dist)
+				seen[name] = true
+			}
+		}
+	}
+
+	sort.Sort(distsByName(dists))
+
+	return dists, nil
+}
+
+// ServersUsingDist will return the servers of the stable that are
+// created from, or associated with, the distribution, sorted by name.
+func (stable *Stable) ServersUsingDist(dist *Dist) []*Server {
+	servers := []*Server{}
+	for _, srv := range stable.Server {
+		if srv.Dist != nil && srv.Dist.Name == dist.Name {
+			servers = append(servers, srv)
+		}
+	}
+	sort.Sort(byName(servers))
+	return servers
+}
+
+// DelDistByName will remove the distribution from the stable,
+// including all servers using the distribution. The distribution is
+// removed from the distribution directory of the stable as well. For
+// distributions added from a directory, only the link to the
+// directory is removed.
 func (stable *Stable) DelDistByName(name string) error {
 	dist, exists := stable.Distro[name]
 	if !exists {
 		return fmt.Errorf("No distribution named %q exists", name)
 	}
-	for _, srv := range stable.Server {
-		if srv.Dist == dist {
-			stable.DelServer(srv)
+
+	// Collect the servers first, since deleting a server remove
+	// it from the map.
+	for _, srv := range stable.ServersUsingDist(dist) {
+		if err := stable.DelServer(srv); err != nil {
+			return err
 		}
 	}
 
+	path := filepath.Join(stable.distDir, dist.Name)
+	if !insideDir(stable.distDir, path) || path == stable.distDir {
+		return fmt.Errorf("Invalid distribution name %q", dist.Name)
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+
 	delete(stable.Distro, dist.Name)
 	return nil
 }
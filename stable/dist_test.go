@@ -17,10 +17,16 @@
 package stable
 
 import (
+	"archive/tar"
 	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
 	"mysqld/log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -37,6 +43,12 @@ func TestPathType(t *testing.T) {
 	if pathType := pathType("foo/mysql-9.9.9.tar"); pathType != TAR_PATH {
 		t.Errorf("Path type %v expected, was %v", pathType, TAR_PATH)
 	}
+	if pathType := pathType("mysql-9.9.9.tar.bz2"); pathType != TBZ2_PATH {
+		t.Errorf("Path type %v expected, was %v", pathType, TBZ2_PATH)
+	}
+	if pathType := pathType("mysql-9.9.9.tar.xz"); pathType != TXZ_PATH {
+		t.Errorf("Path type %v expected, was %v", pathType, TXZ_PATH)
+	}
 	if pathType := pathType("foo/mysql-9.9.9.zip"); pathType != ZIP_PATH {
 		t.Errorf("Path type %v expected, was %v", pathType, ZIP_PATH)
 	}
@@ -59,17 +71,52 @@ func TestPathType(t *testing.T) {
 }
 
 func TestParseVersionString(t *testing.T) {
-	strings := map[string]string{
-		"5.5.32-0ubuntu0.12.04.1-log": "mysqld  Ver 5.5.32-0ubuntu0.12.04.1-log for debian-linux-gnu on i686 ((Ubuntu)))",
-		"5.6.14":                      "mysql-5.6.14-linux-glibc2.5-i686/bin/mysqld  Ver 5.6.14 for linux-glibc2.5 on i686 (MySQL Community Server (GPL))",
+	tests := []struct {
+		banner, version, flavor string
+	}{
+		{"mysqld  Ver 5.5.32-0ubuntu0.12.04.1-log for debian-linux-gnu on i686 ((Ubuntu)))",
+			"5.5.32-0ubuntu0.12.04.1-log", FLAVOR_MYSQL},
+		{"mysql-5.6.14-linux-glibc2.5-i686/bin/mysqld  Ver 5.6.14 for linux-glibc2.5 on i686 (MySQL Community Server (GPL))",
+			"5.6.14", FLAVOR_MYSQL},
+		{"mysqld  Ver 10.6.12-MariaDB for Linux on x86_64 (MariaDB Server)",
+			"10.6.12-MariaDB", FLAVOR_MARIADB},
+		{"/usr/sbin/mysqld  Ver 10.1.48-MariaDB-0ubuntu0.18.04.1 for debian-linux-gnu on x86_64 (Ubuntu 18.04)",
+			"10.1.48-MariaDB-0ubuntu0.18.04.1", FLAVOR_MARIADB},
+		{"/usr/sbin/mysqld  Ver 5.7.41-44 for Linux on x86_64 (Percona Server (GPL), Release 44, Revision 7d5ac6c)",
+			"5.7.41-44", FLAVOR_PERCONA},
+		{"/usr/sbin/mysqld  Ver 8.0.32-24 for Linux on x86_64 (Percona Server (GPL), Release 24, Revision e5c6e9d2)",
+			"8.0.32-24", FLAVOR_PERCONA},
 	}
 
-	for expected, versionString := range strings {
+	for _, test := range tests {
 		dist := &Dist{}
-		dist.parseVersionString(versionString)
-		if dist.ServerVersion != expected {
-			t.Errorf("Expected version string %q, found %q", expected, dist.ServerVersion)
+		dist.parseVersionString(test.banner)
+		if dist.ServerVersion != test.version {
+			t.Errorf("Expected version string %q, found %q", test.version, dist.ServerVersion)
 		}
+		if dist.Flavor != test.flavor {
+			t.Errorf("Expected flavor %q for %q, found %q", test.flavor, test.banner, dist.Flavor)
+		}
+	}
+}
+
+func TestMariaDBVersion(t *testing.T) {
+	dist := &Dist{Root: "/nonexistent", Version: "10.6.12", Flavor: FLAVOR_MARIADB}
+	if method := dist.InitMethod(); method == INIT_INITIALIZE {
+		t.Errorf("Expected MariaDB not to use --initialize")
+	}
+	mysqld := dist.defaultOptions().Section["mysqld"]
+	if value := mysqld.GetString("lc_messages"); value != "en_US" {
+		t.Errorf("Expected lc_messages to be set for MariaDB, got %q", value)
+	}
+	if mysqld.Has("language") {
+		t.Errorf("Expected no language option for MariaDB")
+	}
+
+	// MariaDB before 10.0 use the MySQL version numbers
+	dist = &Dist{Version: "5.5.68", Flavor: FLAVOR_MARIADB}
+	if version := dist.mysqlVersion(); version != "5.5.68" {
+		t.Errorf("Expected version 5.5.68, got %s", version)
 	}
 }
 
@@ -92,6 +139,138 @@ func TestScanVersionFile(t *testing.T) {
 	}
 }
 
+func TestBinaries(t *testing.T) {
+	root, err := ioutil.TempDir("", "dist")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	binDir := filepath.Join(root, "bin")
+	os.MkdirAll(filepath.Join(binDir, "subdir"), 0755)
+	files := map[string]os.FileMode{
+		"mysql":       0755,
+		"mysqladmin":  0755,
+		"mysqlbinlog": 0700,
+		"README":      0644,
+	}
+	for name, mode := range files {
+		if err := ioutil.WriteFile(filepath.Join(binDir, name), []byte("#!/bin/sh\n"), mode); err != nil {
+			t.Fatalf("Unable to create %q: %s", name, err)
+		}
+	}
+	os.Symlink("mysql", filepath.Join(binDir, "mysql-link"))
+
+	dist := &Dist{Root: root}
+	binaries, err := dist.Binaries()
+	if err != nil {
+		t.Fatalf("Binaries: unexpected error %s", err)
+	}
+	compareStrings(t, binaries, []string{"mysql", "mysql-link", "mysqladmin", "mysqlbinlog"})
+
+	dist = &Dist{Root: filepath.Join(root, "missing")}
+	if _, err := dist.Binaries(); err == nil {
+		t.Errorf("Binaries: expected error for missing directory, got none")
+	}
+}
+
+// compareStrings will check that two slices of strings are equal.
+func compareStrings(t *testing.T, result, expected []string) {
+	if fmt.Sprint(result) != fmt.Sprint(expected) {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	samples := []struct {
+		lhs, rhs string
+		result   int
+	}{
+		{"5.1.71", "5.1.71", 0},
+		{"5.1.6", "5.1.71", -1},
+		{"5.10.0", "5.9.9", 1},
+		{"5.5", "5.5.0", 0},
+		{"5.5.32-0ubuntu0.12.04.1-log", "5.5.32", 0},
+		{"5.7.5-m15", "5.7.6", -1},
+		{"8.0.11", "5.7.6", 1},
+		{"10.6.12-MariaDB", "10.6.2", 1},
+	}
+
+	for _, sample := range samples {
+		if result := compareVersions(sample.lhs, sample.rhs); result != sample.result {
+			t.Errorf("Comparing %q and %q gave %d, expected %d",
+				sample.lhs, sample.rhs, result, sample.result)
+		}
+	}
+}
+
+// makeDistTree will create the files in a fake distribution tree
+// under root. Files in the "bin" or "scripts" directory are made
+// executable.
+func makeDistTree(t *testing.T, root string, files map[string]string) {
+	for path, content := range files {
+		fullpath := filepath.Join(root, path)
+		mode := os.FileMode(0644)
+		if dir := filepath.Dir(path); dir == "bin" || dir == "scripts" {
+			mode = 0755
+		}
+		os.MkdirAll(filepath.Dir(fullpath), 0755)
+		if err := ioutil.WriteFile(fullpath, []byte(content), mode); err != nil {
+			t.Fatalf("Unable to create %q: %s", fullpath, err)
+		}
+	}
+}
+
+func TestInitMethod(t *testing.T) {
+	root, err := ioutil.TempDir("", "dist")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	legacy := map[string]string{}
+	for _, path := range bootstrapFiles {
+		legacy[path] = "-- SQL"
+	}
+	installDb := map[string]string{
+		"scripts/mysql_install_db": "#!/bin/sh\n",
+	}
+	initialize := map[string]string{
+		"bin/mysqld": "#!/bin/sh\necho '  --initialize        Create the default database and exit.'\n",
+	}
+	noInitialize := map[string]string{
+		"bin/mysqld": "#!/bin/sh\necho '  --bootstrap         Used by mysql installation scripts.'\n",
+	}
+
+	samples := []struct {
+		name, version string
+		files         map[string]string
+		method        InitMethod
+	}{
+		{"mysql-5.1.71", "5.1.71", legacy, INIT_BOOTSTRAP},
+		{"mysql-5.5.32", "5.5.32", legacy, INIT_BOOTSTRAP},
+		{"mysql-5.6.14", "5.6.14", legacy, INIT_BOOTSTRAP},
+		{"mysql-5.6.14-install", "5.6.14", installDb, INIT_INSTALL_DB},
+		{"mysql-5.7.5", "5.7.5-m15", legacy, INIT_BOOTSTRAP},
+		{"mysql-5.7.6", "5.7.6-m16", installDb, INIT_INITIALIZE},
+		{"mysql-8.0.11", "8.0.11", map[string]string{}, INIT_INITIALIZE},
+		{"unknown-initialize", "", initialize, INIT_INITIALIZE},
+		{"unknown-bootstrap", "", noInitialize, INIT_BOOTSTRAP},
+	}
+
+	for _, sample := range samples {
+		dist := &Dist{
+			Name:    sample.name,
+			Root:    filepath.Join(root, sample.name),
+			Version: sample.version,
+		}
+		makeDistTree(t, dist.Root, sample.files)
+		if method := dist.InitMethod(); method != sample.method {
+			t.Errorf("Distribution %q: expected method %d, got %d", dist.Name, sample.method, method)
+		}
+	}
+}
+
 var flagDist, flagVersion string
 
 func init() {
@@ -144,3 +323,447 @@ func TestDistSetup(t *testing.T) {
 
 	stable.Destroy()
 }
+
+// writeTarball will write a compressed tar archive with the files
+// under a top directory with the same name as the archive. Files in
+// the "bin" directory are made executable.
+func writeTarball(t *testing.T, path string, files map[string][]byte) {
+	top := filepath.Base(path[:len(path)-len(".tar.gz")])
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	entries := []*tar.Header{}
+	contents := map[string]string{}
+	for _, name := range names {
+		mode := int64(0644)
+		if filepath.Dir(name) == "bin" {
+			mode = 0755
+		}
+		entry := &tar.Header{Name: filepath.Join(top, name), Typeflag: tar.TypeReg, Mode: mode}
+		entries = append(entries, entry)
+		contents[entry.Name] = string(files[name])
+	}
+	makeTarball(t, path, entries, contents)
+}
+
+func TestAddDistFailure(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	stable, err := CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+
+	// Create an archive where the first file can be extracted
+	// but the rest of the archive is missing.
+	data := make([]byte, 1<<20)
+	rand.Read(data)
+	archive := filepath.Join(root, "mysql-9.9.9.tar.gz")
+	writeTarball(t, archive, map[string][]byte{
+		"include/mysql_version.h": []byte("#define MYSQL_SERVER_VERSION \"9.9.9\"\n"),
+		"lib/libmysqld.a":         data,
+	})
+	content, _ := ioutil.ReadFile(archive)
+	ioutil.WriteFile(archive, content[:len(content)/2], 0644)
+
+	if _, err := stable.AddDist(archive); err == nil {
+		t.Errorf("Expected error when adding broken archive")
+	}
+	if entries, _ := ioutil.ReadDir(stable.distDir); len(entries) > 0 {
+		t.Errorf("Expected empty distribution directory, found %q", entries[0].Name())
+	}
+	if len(stable.Distro) > 0 {
+		t.Errorf("Expected no distributions, got %v", stable.Distro)
+	}
+
+	// An archive that can be extracted but is not a valid
+	// distribution should not leave anything behind either.
+	writeTarball(t, archive, map[string][]byte{
+		"README": []byte("Not a distribution"),
+	})
+	if _, err := stable.AddDist(archive); err == nil {
+		t.Errorf("Expected error when adding invalid distribution")
+	}
+	if entries, _ := ioutil.ReadDir(stable.distDir); len(entries) > 0 {
+		t.Errorf("Expected empty distribution directory, found %q", entries[0].Name())
+	}
+}
+
+func TestReadVersion(t *testing.T) {
+	root, err := ioutil.TempDir("", "dist")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	// A binary-only distribution without include directory
+	dist := &Dist{Root: filepath.Join(root, "binary")}
+	makeDistTree(t, dist.Root, map[string]string{
+		"bin/mysqld": "#!/bin/sh\necho 'mysqld  Ver 5.6.19-log for linux-glibc2.5 on x86_64 (MySQL Community Server (GPL))'\n",
+	})
+	if err := dist.readVersion(); err != nil {
+		t.Fatalf("Unable to read version: %s", err)
+	}
+	if dist.Version != "5.6.19" || dist.ServerVersion != "5.6.19-log" {
+		t.Errorf("Expected version 5.6.19 and server version 5.6.19-log, got %s and %s",
+			dist.Version, dist.ServerVersion)
+	}
+
+	// The include file take precedence over the server version
+	dist = &Dist{Root: filepath.Join(root, "full")}
+	makeDistTree(t, dist.Root, map[string]string{
+		"bin/mysqld":              "#!/bin/sh\necho 'mysqld  Ver 5.6.19-log for linux-glibc2.5 on x86_64'\n",
+		"include/mysql_version.h": "#define MYSQL_SERVER_VERSION \"5.6.20\"\n",
+	})
+	if err := dist.readVersion(); err != nil {
+		t.Fatalf("Unable to read version: %s", err)
+	}
+	if dist.Version != "5.6.20" {
+		t.Errorf("Expected version 5.6.20, got %s", dist.Version)
+	}
+
+	// Neither include file nor usable version from the server
+	dist = &Dist{Root: filepath.Join(root, "unknown")}
+	makeDistTree(t, dist.Root, map[string]string{
+		"bin/mysqld": "#!/bin/sh\necho 'Unknown'\n",
+	})
+	if err := dist.readVersion(); err != ErrVersionNotFound {
+		t.Errorf("Expected ErrVersionNotFound, got %v", err)
+	}
+}
+
+func TestAddDistNamed(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	stable, err := CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+
+	source := filepath.Join(root, "mysql-5.6.20")
+	files := map[string]string{
+		"bin/mysqld": "#!/bin/sh\necho 'mysqld  Ver 5.6.20 for linux-glibc2.5 on x86_64'\n",
+	}
+	for _, fname := range bootstrapFiles {
+		files[fname] = "-- SQL"
+	}
+	makeDistTree(t, source, files)
+
+	dist, err := stable.AddDistNamed(source, "mysql-debug")
+	if err != nil {
+		t.Fatalf("Unable to add distribution: %s", err)
+	}
+	if dist.Name != "mysql-debug" || stable.Distro["mysql-debug"] != dist {
+		t.Errorf("Expected distribution named %q, got %q", "mysql-debug", dist.Name)
+	}
+	if dist.Root != filepath.Join(stable.distDir, "mysql-debug") {
+		t.Errorf("Expected root in %s, got %s", filepath.Join(stable.distDir, "mysql-debug"), dist.Root)
+	}
+
+	// Without a name, the name of the directory is used
+	if dist, err := stable.AddDist(source); err != nil {
+		t.Errorf("Unable to add distribution: %s", err)
+	} else if dist.Name != "mysql-5.6.20" {
+		t.Errorf("Expected distribution named %q, got %q", "mysql-5.6.20", dist.Name)
+	}
+
+	// Adding a distribution with an existing name is an error
+	// and does not replace the existing distribution.
+	if _, err := stable.AddDistNamed(source, "mysql-debug"); err == nil {
+		t.Errorf("Expected error when adding distribution with existing name")
+	}
+	if stable.Distro["mysql-debug"] != dist {
+		t.Errorf("Existing distribution was replaced")
+	}
+	if _, err := stable.AddDistNamed(source, "../escape"); err == nil {
+		t.Errorf("Expected error for invalid distribution name")
+	}
+}
+
+func TestAddDistDuplicate(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	stable, err := CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+
+	archive := filepath.Join(root, "mysql-5.7.20.tar.gz")
+	writeTarball(t, archive, map[string][]byte{
+		"bin/mysqld": []byte("#!/bin/sh\necho 'mysqld  Ver 5.7.20 for linux-glibc2.5 on x86_64'\n"),
+	})
+	dist, err := stable.AddDist(archive)
+	if err != nil {
+		t.Fatalf("Unable to add distribution: %s", err)
+	}
+
+	// Adding the same archive again should fail without touching
+	// the existing distribution.
+	if _, err := stable.AddDist(archive); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected error when adding duplicate distribution, got %v", err)
+	}
+	if stable.Distro[dist.Name] != dist {
+		t.Errorf("Existing distribution was replaced")
+	}
+	if _, err := os.Stat(dist.binary("mysqld")); err != nil {
+		t.Errorf("Existing distribution was removed: %s", err)
+	}
+
+	// A tree left in the distribution directory should not be
+	// overwritten either, even if it is not in the stable.
+	delete(stable.Distro, dist.Name)
+	if _, err := stable.AddDist(archive); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected error when adding over existing tree, got %v", err)
+	}
+	if _, err := os.Stat(dist.binary("mysqld")); err != nil {
+		t.Errorf("Existing tree was removed: %s", err)
+	}
+}
+
+func TestDelDistReAdd(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	stable, err := CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+
+	archive := filepath.Join(root, "mysql-5.7.20.tar.gz")
+	writeTarball(t, archive, map[string][]byte{
+		"bin/mysqld": []byte("#!/bin/sh\necho 'mysqld  Ver 5.7.20 for linux-glibc2.5 on x86_64'\n"),
+	})
+	source := filepath.Join(root, "mysql-5.6.20")
+	files := map[string]string{
+		"bin/mysqld": "#!/bin/sh\necho 'mysqld  Ver 5.6.20 for linux-glibc2.5 on x86_64'\n",
+	}
+	for _, fname := range bootstrapFiles {
+		files[fname] = "-- SQL"
+	}
+	makeDistTree(t, source, files)
+
+	// Removing a distribution should remove the unpacked tree, or
+	// the link for a directory, so that it can be added again.
+	for _, path := range []string{archive, source} {
+		for i := 0; i < 2; i++ {
+			dist, err := stable.AddDist(path)
+			if err != nil {
+				t.Fatalf("Unable to add distribution %s: %s", path, err)
+			}
+			if err := stable.DelDistByName(dist.Name); err != nil {
+				t.Fatalf("Unable to remove distribution %s: %s", dist.Name, err)
+			}
+			if _, err := os.Lstat(filepath.Join(stable.distDir, dist.Name)); !os.IsNotExist(err) {
+				t.Errorf("Expected %s to be removed from disk, got %v", dist.Name, err)
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(source, "bin", "mysqld")); err != nil {
+		t.Errorf("Directory of distribution was removed: %s", err)
+	}
+}
+
+func TestAddDistInitFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	stable, err := CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+
+	mysqld := func(version string) map[string]string {
+		script := fmt.Sprintf("#!/bin/sh\necho 'mysqld  Ver %s for linux-glibc2.5 on x86_64'\n", version)
+		return map[string]string{"bin/mysqld": script}
+	}
+
+	// Distributions using --initialize do not need the SQL files
+	source := filepath.Join(root, "mysql-5.7.20")
+	makeDistTree(t, source, mysqld("5.7.20"))
+	if dist, err := stable.AddDist(source); err != nil {
+		t.Errorf("Unable to add distribution without SQL files: %s", err)
+	} else if method := dist.InitMethod(); method != INIT_INITIALIZE {
+		t.Errorf("Expected init method %v, got %v", INIT_INITIALIZE, method)
+	}
+
+	// Older distributions need the SQL files to bootstrap
+	source = filepath.Join(root, "mysql-5.6.20")
+	makeDistTree(t, source, mysqld("5.6.20"))
+	if _, err := stable.AddDist(source); err == nil {
+		t.Errorf("Expected error for distribution without SQL files")
+	}
+}
+
+func TestAddDirDist(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	os.Mkdir(filepath.Join(root, "stable"), 0755)
+	stable, err := CreateStable(filepath.Join(root, "stable"))
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+
+	source := filepath.Join(root, "src", "mysql-5.6.20")
+	files := map[string]string{
+		"bin/mysqld": "#!/bin/sh\necho 'mysqld  Ver 5.6.20 for linux-glibc2.5 on x86_64'\n",
+	}
+	for _, fname := range bootstrapFiles {
+		files[fname] = "-- SQL"
+	}
+	makeDistTree(t, source, files)
+
+	// Add the distribution using a path relative to a working
+	// directory that is neither the stable nor the distribution
+	// directory.
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Unable to get working directory: %s", err)
+	}
+	defer os.Chdir(cwd)
+	if err := os.Chdir(filepath.Join(root, "src")); err != nil {
+		t.Fatalf("Unable to change directory: %s", err)
+	}
+
+	dist, err := stable.AddDist("mysql-5.6.20")
+	if err != nil {
+		t.Fatalf("Unable to add distribution: %s", err)
+	}
+	if dist.Root != filepath.Join(stable.distDir, "mysql-5.6.20") {
+		t.Errorf("Expected root %s, got %s", filepath.Join(stable.distDir, "mysql-5.6.20"), dist.Root)
+	}
+	if link, err := os.Readlink(dist.Root); err != nil || link != source {
+		t.Errorf("Expected symlink to %s, got %q (error: %v)", source, link, err)
+	}
+	if _, err := os.Lstat("mysql-5.6.20/mysql-5.6.20"); !os.IsNotExist(err) {
+		t.Errorf("Expected no symlink in the working directory, got %v", err)
+	}
+
+	// The same directory can be added under another name, but
+	// failing to create the symlink is an error.
+	if _, err := stable.AddDistNamed("mysql-5.6.20", "again"); err != nil {
+		t.Errorf("Unable to add distribution under a new name: %s", err)
+	}
+	if err := os.Symlink(source, filepath.Join(stable.distDir, "taken")); err != nil {
+		t.Fatalf("Unable to create symlink: %s", err)
+	}
+	if _, err := stable.AddDistNamed("mysql-5.6.20", "taken"); err == nil {
+		t.Errorf("Expected error when the symlink cannot be created")
+	}
+}
+
+func TestFindMatchingDists(t *testing.T) {
+	stable := &Stable{Distro: map[string]*Dist{}}
+	for _, name := range []string{"mysql-5.6.14", "mysql-5.6.20", "mysql-5.7.10", "mariadb-10.1.9"} {
+		stable.Distro[name] = &Dist{Name: name}
+	}
+
+	// Overlapping patterns give each distribution once, sorted by
+	// name
+	dists, err := stable.FindMatchingDists([]string{"mysql-5.6.*", "*.20", "mariadb-10.1.9"})
+	if err != nil {
+		t.Fatalf("Unable to match distributions: %s", err)
+	}
+	names := make([]string, len(dists))
+	for i, dist := range dists {
+		names[i] = dist.Name
+	}
+	compareStrings(t, names, []string{"mariadb-10.1.9", "mysql-5.6.14", "mysql-5.6.20"})
+
+	if dists, err := stable.FindMatchingDists([]string{"mysql-8*"}); err != nil || len(dists) > 0 {
+		t.Errorf("Expected no distributions, got %v (error %v)", dists, err)
+	}
+	if _, err := stable.FindMatchingDists([]string{"["}); err == nil {
+		t.Errorf("Expected error for bad pattern")
+	}
+}
+
+func TestDelDistByName(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	stable, err := CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+
+	dist := &Dist{Name: "fake", Root: filepath.Join(root, "fake"), Version: "5.6.20"}
+	makeDistTree(t, dist.Root, map[string]string{
+		"bin/mysqld":               "#!/bin/sh\ncat >/dev/null\n",
+		"scripts/mysql_install_db": "#!/bin/sh\n",
+	})
+	other := &Dist{Name: "other", Root: dist.Root, Version: "5.6.20"}
+	stable.Distro[dist.Name] = dist
+	stable.Distro[other.Name] = other
+
+	for _, name := range []string{"first", "second"} {
+		if _, err := stable.AddServer(name, dist); err != nil {
+			t.Fatalf("Unable to add server %s: %s", name, err)
+		}
+	}
+	if _, err := stable.AddServer("third", other); err != nil {
+		t.Fatalf("Unable to add server third: %s", err)
+	}
+
+	// Reopen the stable, so that the distributions of the servers
+	// are decoded from the configuration as when running a command.
+	if err := stable.WriteConfig(); err != nil {
+		t.Fatalf("Unable to write configuration: %s", err)
+	}
+	if stable, err = OpenStable(root); err != nil {
+		t.Fatalf("Unable to open stable: %s", err)
+	}
+	dist = stable.Distro[dist.Name]
+
+	using := []string{}
+	for _, srv := range stable.ServersUsingDist(dist) {
+		using = append(using, srv.Name)
+	}
+	compareStrings(t, using, []string{"first", "second"})
+
+	if err := stable.DelDistByName(dist.Name); err != nil {
+		t.Fatalf("Unable to remove distribution: %s", err)
+	}
+	if _, exists := stable.Distro[dist.Name]; exists {
+		t.Errorf("Distribution %s still in stable", dist.Name)
+	}
+	for _, name := range []string{"first", "second"} {
+		if _, exists := stable.Server[name]; exists {
+			t.Errorf("Server %s still in stable", name)
+		}
+	}
+	if _, exists := stable.Server["third"]; !exists {
+		t.Errorf("Server third using another distribution was removed")
+	}
+
+	if err := stable.DelDistByName(dist.Name); err == nil {
+		t.Errorf("Expected error when removing missing distribution")
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SOCKET_PATH_MAX is the longest path that can be used for a Unix
+// socket, which is limited by the size of sun_path in sockaddr_un.
+// SOCKET_NAME_HEADROOM is the length of server name that the
+// socket path of a new server should leave room for.
+const (
+	SOCKET_PATH_MAX      = 107
+	SOCKET_NAME_HEADROOM = 16
+)
+
+// Diagnosis is the result of one environment check. Err is nil if
+// the check passed, otherwise Hint tell how to fix the problem. If
+// Critical is set, the stable cannot be used until it is fixed.
+type Diagnosis struct {
+	Check    string
+	Critical bool
+	Err      error
+	Hint     string
+}
+
+// checkSocketPaths will check that the socket paths of the servers
+// are not too long, and that there is room for the socket of new
+// servers below the server directory.
+func (stable *Stable) checkSocketPaths() []Diagnosis {
+	newSocket := filepath.Join(stable.serverDir, strings.Repeat("x", SOCKET_NAME_HEADROOM), "run", "mysqld.sock")
+	diag := Diagnosis{
+		Check:    "Socket paths are short enough",
+		Critical: true,
+	}
+	if len(newSocket) > SOCKET_PATH_MAX {
+		diag.Err = fmt.Errorf("Socket path for new servers can be %d characters, maximum is %d", len(newSocket), SOCKET_PATH_MAX)
+		diag.Hint = "Create the stable in a directory with a shorter path"
+	}
+	result := []Diagnosis{diag}
+
+	names := make([]string, 0, len(stable.Server))
+	for name := range stable.Server {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		socket := stable.Server[name].Socket
+		if len(socket) > SOCKET_PATH_MAX {
+			result = append(result, Diagnosis{
+				Check:    fmt.Sprintf("Socket path of server %s is short enough", name),
+				Critical: true,
+				Err:      fmt.Errorf("Socket path %q is %d characters, maximum is %d", socket, len(socket), SOCKET_PATH_MAX),
+				Hint:     "Adopt the server again with a shorter socket path",
+			})
+		}
+	}
+	return result
+}
+
+// checkWritable will check that files can be created in the root of
+// the stable.
+func (stable *Stable) checkWritable() Diagnosis {
+	diag := Diagnosis{
+		Check:    "Stable directory is writable",
+		Critical: true,
+	}
+	file, err := ioutil.TempFile(stable.Root, ".doctor")
+	if err != nil {
+		diag.Err = err
+		diag.Hint = fmt.Sprintf("Give the user write permission on %s", stable.Root)
+		return diag
+	}
+	file.Close()
+	os.Remove(file.Name())
+	return diag
+}
+
+// checkDists will check that the mysqld of each distribution can be
+// executed.
+func (stable *Stable) checkDists(launcher Launcher) []Diagnosis {
+	names := make([]string, 0, len(stable.Distro))
+	for name := range stable.Distro {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := []Diagnosis{}
+	for _, name := range names {
+		diag := Diagnosis{
+			Check:    fmt.Sprintf("Distribution %s can run mysqld", name),
+			Critical: true,
+		}
+		mysqld := stable.Distro[name].binary("mysqld")
+		if err := launcher.Run(mysqld, "--version"); err != nil {
+			diag.Err = err
+			diag.Hint = "Check that the distribution is for this platform and that shared libraries such as libaio are installed"
+		}
+		result = append(result, diag)
+	}
+	return result
+}
+
+// checkXz will check that the xz program, which is needed to add
+// distributions from xz archives, can be found.
+func checkXz() Diagnosis {
+	diag := Diagnosis{Check: "Program xz is available for unpacking xz archives"}
+	if _, err := findXz(); err != nil {
+		diag.Err = err
+		diag.Hint = "Install xz, for example from the xz-utils package"
+	}
+	return diag
+}
+
+// Doctor will run a series of checks of the environment that the
+// stable is used in and return the result of each check.
+func (stable *Stable) Doctor(launcher Launcher) []Diagnosis {
+	result := stable.checkSocketPaths()
+	result = append(result, stable.checkWritable(), checkXz())
+	return append(result, stable.checkDists(launcher)...)
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckSocketPaths(t *testing.T) {
+	short, err := newStable("/tmp")
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+	short.Server["long"] = &Server{Socket: "/" + strings.Repeat("x", SOCKET_PATH_MAX)}
+	result := short.checkSocketPaths()
+	if len(result) != 2 {
+		t.Fatalf("Expected 2 diagnoses, got %d", len(result))
+	}
+	if result[0].Err != nil {
+		t.Errorf("Expected %q to pass, got %s", result[0].Check, result[0].Err)
+	}
+	if result[1].Err == nil {
+		t.Errorf("Expected %q to fail", result[1].Check)
+	}
+
+	long, err := newStable("/" + strings.Repeat("x", SOCKET_PATH_MAX-40))
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+	if result := long.checkSocketPaths(); result[0].Err == nil {
+		t.Errorf("Expected %q to fail for %s", result[0].Check, long.Root)
+	}
+}
+
+func TestCheckDists(t *testing.T) {
+	root, err := ioutil.TempDir("", "doctor")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	stable, err := CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+	stable.Distro["good"] = &Dist{Root: filepath.Join(root, "good")}
+	stable.Distro["bad"] = &Dist{Root: filepath.Join(root, "bad")}
+
+	failure := errors.New("exec format error")
+	launcher := &fakeLauncher{
+		fail: map[string]error{
+			filepath.Join(root, "bad", "bin", "mysqld"): failure,
+		},
+	}
+	result := stable.checkDists(launcher)
+	if len(result) != 2 {
+		t.Fatalf("Expected 2 diagnoses, got %d", len(result))
+	}
+	if result[0].Err != failure {
+		t.Errorf("Expected %q to fail with %q, got %v", result[0].Check, failure, result[0].Err)
+	}
+	if result[1].Err != nil {
+		t.Errorf("Expected %q to pass, got %s", result[1].Check, result[1].Err)
+	}
+
+	if diag := stable.checkWritable(); diag.Err != nil {
+		t.Errorf("Expected %q to pass, got %s", diag.Check, diag.Err)
+	}
+}
+
+func TestCheckXz(t *testing.T) {
+	saved := xzProgram
+	defer func() { xzProgram = saved }()
+
+	xzProgram = "no-such-xz-program"
+	if diag := checkXz(); diag.Err == nil || diag.Critical {
+		t.Errorf("Expected %q to fail without being critical, got %+v", diag.Check, diag)
+	}
+	if _, err := decompress(strings.NewReader(""), "mysql-5.7.20.tar.xz"); err == nil || !strings.Contains(err.Error(), xzProgram) {
+		t.Errorf("Expected error naming %s, got %v", xzProgram, err)
+	}
+}
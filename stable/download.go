@@ -0,0 +1,167 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"fmt"
+	"io"
+	"mysqld/log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DOWNLOAD_ATTEMPTS is the number of times a download is attempted
+// before giving up. Each new attempt resume the download where the
+// previous attempt stopped, if the server supports it.
+const DOWNLOAD_ATTEMPTS = 3
+
+// DOWNLOAD_PROGRESS_STEP is the number of bytes downloaded between
+// each progress report.
+const DOWNLOAD_PROGRESS_STEP = 16 * 1024 * 1024
+
+// downloadClient is the client used for downloading distributions.
+var downloadClient = http.DefaultClient
+
+// isURL will return true if the path is a URL that a distribution can
+// be downloaded from.
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// progressWriter count the bytes written and log the progress of a
+// download.
+type progressWriter struct {
+	name         string
+	total, count int64
+	reported     int64
+}
+
+func (pw *progressWriter) Write(data []byte) (int, error) {
+	pw.count += int64(len(data))
+	if pw.count-pw.reported >= DOWNLOAD_PROGRESS_STEP {
+		pw.reported = pw.count
+		if pw.total > 0 {
+			log.Infof("Downloaded %d of %d bytes of %s (%d%%)\n",
+				pw.count, pw.total, pw.name, 100*pw.count/pw.total)
+		} else {
+			log.Infof("Downloaded %d bytes of %s\n", pw.count, pw.name)
+		}
+	}
+	return len(data), nil
+}
+
+// download will download the file at the URL into the temporary
+// directory of the stable and return the path to it. The file keeps
+// the name it has in the URL, so that the type of archive can be
+// decided from it. The caller is responsible for removing the file.
+//
+// The file is downloaded to a partial file first, which is only
+// renamed once the download is complete. If the connection drops,
+// the download is resumed from where it stopped.
+func (stable *Stable) download(rawurl string) (string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", err
+	}
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		return "", fmt.Errorf("No file name in URL %s", rawurl)
+	}
+
+	target := filepath.Join(stable.tmpDir, name)
+	partial := target + ".part"
+	log.Infof("Downloading %s into %s\n", rawurl, target)
+	for attempt := 1; ; attempt++ {
+		resume, err := downloadPart(rawurl, partial, name)
+		if err == nil {
+			break
+		}
+		if !resume || attempt == DOWNLOAD_ATTEMPTS {
+			os.Remove(partial)
+			return "", fmt.Errorf("Unable to download %s: %s", rawurl, err)
+		}
+		log.Warningf("Download of %s failed, resuming: %s", rawurl, err)
+	}
+
+	if err := os.Rename(partial, target); err != nil {
+		os.Remove(partial)
+		return "", err
+	}
+	return target, nil
+}
+
+// downloadPart will download the file at the URL into the partial
+// file. If the partial file already has contents, only the rest of
+// the file is requested. If the download fails, it is also returned
+// if it is meaningful to resume the download.
+func downloadPart(rawurl, partial, name string) (bool, error) {
+	file, err := os.OpenFile(partial, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequest("GET", rawurl, nil)
+	if err != nil {
+		return false, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		// The server resume the download at the offset.
+	case http.StatusOK:
+		// The server send the complete file, so start over.
+		if offset, err = file.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+		if err := file.Truncate(0); err != nil {
+			return false, err
+		}
+	default:
+		return false, fmt.Errorf("Server responded %s", resp.Status)
+	}
+
+	progress := &progressWriter{name: name, count: offset, reported: offset}
+	if resp.ContentLength >= 0 {
+		progress.total = offset + resp.ContentLength
+	}
+	if _, err := io.Copy(io.MultiWriter(file, progress), resp.Body); err != nil {
+		return true, err
+	}
+	if progress.total > 0 && progress.count != progress.total {
+		return true, fmt.Errorf("Download ended after %d of %d bytes", progress.count, progress.total)
+	}
+	return false, nil
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAddDistURL(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	stable, err := CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+
+	mysqld := "#!/bin/sh\necho 'mysqld  Ver 5.7.20 for linux-glibc2.12 on x86_64'\n"
+	archive := filepath.Join(root, "mysql-5.7.20.tar.gz")
+	makeTarball(t, archive, []*tar.Header{
+		{Name: "mysql-5.7.20/bin/mysqld", Typeflag: tar.TypeReg, Mode: 0755},
+	}, map[string]string{"mysql-5.7.20/bin/mysqld": mysqld})
+	data, err := ioutil.ReadFile(archive)
+	if err != nil {
+		t.Fatalf("Unable to read archive: %s", err)
+	}
+
+	// The first request drops the connection halfway through the
+	// file, so the download has to be resumed.
+	var mutex sync.Mutex
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests = append(requests, r.Header.Get("Range"))
+		count := len(requests)
+		mutex.Unlock()
+		if r.URL.Path != "/mysql-5.7.20.tar.gz" {
+			http.NotFound(w, r)
+			return
+		}
+		if count == 1 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.Write(data[:len(data)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "mysql-5.7.20.tar.gz", time.Now(), bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	dist, err := stable.AddDist(server.URL + "/mysql-5.7.20.tar.gz")
+	if err != nil {
+		t.Fatalf("Unable to add distribution from URL: %s", err)
+	}
+	if dist.Name != "mysql-5.7.20" || dist.Version != "5.7.20" {
+		t.Errorf("Expected distribution mysql-5.7.20 with version 5.7.20, got %s with version %s", dist.Name, dist.Version)
+	}
+	mutex.Lock()
+	if len(requests) != 2 || requests[0] != "" || requests[1] != "bytes="+strconv.Itoa(len(data)/2)+"-" {
+		t.Errorf("Expected download to be resumed, got requests with ranges %q", requests)
+	}
+	mutex.Unlock()
+
+	// The downloaded files are removed
+	if files, _ := ioutil.ReadDir(stable.tmpDir); len(files) > 0 {
+		t.Errorf("Expected no files left in %s, got %d", stable.tmpDir, len(files))
+	}
+
+	// A missing file is an error that is not retried, and leave
+	// nothing behind
+	if _, err := stable.AddDistNamed(server.URL+"/missing.tar.gz", "missing"); err == nil {
+		t.Errorf("Expected error for missing file")
+	}
+	mutex.Lock()
+	if len(requests) != 3 {
+		t.Errorf("Expected missing file to be requested once, got %d requests", len(requests)-2)
+	}
+	mutex.Unlock()
+	if files, _ := ioutil.ReadDir(stable.tmpDir); len(files) > 0 {
+		t.Errorf("Expected no files left in %s, got %d", stable.tmpDir, len(files))
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mysqld/log"
+)
+
+// Dump will write a dump of all databases of the server to the
+// writer, using the mysqldump program of the distribution of the
+// server. Stored routines and events are included in the dump.
+func (srv *Server) Dump(wr io.Writer) error {
+	argv, err := srv.clientArgs("--all-databases", "--routines", "--events")
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd := srv.command(srv.bin("mysqldump"), argv...)
+	cmd.Stdout = wr
+	cmd.Stderr = &stderr
+	log.Debugf("Executing %v", cmd.Args)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Server %s: %s: %s", srv.Name, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return nil
+}
+
+// Restore will execute the statements in the reader on the server,
+// using the mysql client of the distribution of the server. This is
+// used to restore a dump created with Dump.
+func (srv *Server) Restore(rd io.Reader) error {
+	argv, err := srv.mysqlArgs()
+	if err != nil {
+		return err
+	}
+	cmd := srv.command(srv.bin("mysql"), argv...)
+	cmd.Stdin = rd
+	log.Debugf("Executing %v", cmd.Args)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("Server %s: %s: %s", srv.Name, err, bytes.TrimSpace(output))
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDumpRestore(t *testing.T) {
+	root, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	// The fake mysqldump print its arguments and the fake mysql
+	// client save the statements it is given.
+	restored := filepath.Join(root, "restored.sql")
+	dist := &Dist{Name: "fake", Root: filepath.Join(root, "fake")}
+	makeDistTree(t, dist.Root, map[string]string{
+		"bin/mysqldump": "#!/bin/sh\necho \"-- $*\"\necho 'CREATE DATABASE test;'\n",
+		"bin/mysql":     "#!/bin/sh\ncat > " + restored + "\n",
+	})
+	srv := &Server{
+		Name:   "my_server",
+		Host:   "localhost",
+		Port:   12000,
+		Socket: "/tmp/mysql.sock",
+		User:   "root",
+		Dist:   dist,
+	}
+
+	var buf bytes.Buffer
+	if err := srv.Dump(&buf); err != nil {
+		t.Fatalf("Dump failed: %s", err)
+	}
+	expected := "-- -S/tmp/mysql.sock -hlocalhost -P12000 -uroot --all-databases --routines --events\nCREATE DATABASE test;\n"
+	if buf.String() != expected {
+		t.Errorf("Expected dump %q, got %q", expected, buf.String())
+	}
+
+	if err := srv.Restore(strings.NewReader(buf.String())); err != nil {
+		t.Fatalf("Restore failed: %s", err)
+	}
+	content, err := ioutil.ReadFile(restored)
+	if err != nil {
+		t.Fatalf("Unable to read restored statements: %s", err)
+	}
+	if string(content) != expected {
+		t.Errorf("Expected restored statements %q, got %q", expected, content)
+	}
+
+	// Errors from the programs should be reported together with
+	// their output.
+	makeDistTree(t, dist.Root, map[string]string{
+		"bin/mysqldump": "#!/bin/sh\necho 'Access denied' >&2\nexit 2\n",
+		"bin/mysql":     "#!/bin/sh\necho 'Syntax error'\nexit 1\n",
+	})
+	if err := srv.Dump(&buf); err == nil || !strings.Contains(err.Error(), "Access denied") {
+		t.Errorf("Expected dump error with output, got %v", err)
+	}
+	if err := srv.Restore(strings.NewReader("")); err == nil || !strings.Contains(err.Error(), "Syntax error") {
+		t.Errorf("Expected restore error with output, got %v", err)
+	}
+}
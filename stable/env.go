@@ -0,0 +1,107 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// mergeEnv will merge the variables into the environment, replacing
+// any existing definitions of the variables. The added variables are
+// placed last, ordered by name.
+func mergeEnv(environ []string, vars map[string]string) []string {
+	result := []string{}
+	for _, entry := range environ {
+		name := entry
+		if i := strings.Index(entry, "="); i >= 0 {
+			name = entry[:i]
+		}
+		if _, ok := vars[name]; !ok {
+			result = append(result, entry)
+		}
+	}
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		result = append(result, name+"="+vars[name])
+	}
+	return result
+}
+
+// prependPath will add the directory first in the path list held by
+// the variable in the environment.
+func prependPath(environ []string, name, dir string) []string {
+	prefix := name + "="
+	for i, entry := range environ {
+		if strings.HasPrefix(entry, prefix) {
+			result := append([]string{}, environ...)
+			if value := entry[len(prefix):]; len(value) > 0 {
+				result[i] = prefix + dir + string(os.PathListSeparator) + value
+			} else {
+				result[i] = prefix + dir
+			}
+			return result
+		}
+	}
+	return append(environ, prefix+dir)
+}
+
+// Environ will return the environment to use when running programs
+// for the server, which is the environment of the process with the
+// variables of the server added. If the distribution of the server
+// bundle shared libraries, the library directory is added first to
+// LD_LIBRARY_PATH.
+func (srv *Server) Environ() []string {
+	environ := mergeEnv(os.Environ(), srv.Env)
+	if srv.Dist != nil && len(srv.Dist.LibDir) > 0 {
+		environ = prependPath(environ, "LD_LIBRARY_PATH", srv.Dist.LibDir)
+	}
+	return environ
+}
+
+// SetEnv will set an environment variable for the server. If the
+// value is empty, the variable is removed.
+func (srv *Server) SetEnv(name, value string) error {
+	if len(name) == 0 || strings.Contains(name, "=") {
+		return fmt.Errorf("Bad environment variable name %q", name)
+	}
+	if len(value) == 0 {
+		delete(srv.Env, name)
+		return nil
+	}
+	if srv.Env == nil {
+		srv.Env = make(map[string]string)
+	}
+	srv.Env[name] = value
+	return nil
+}
+
+// command will create a command for running a program for the server
+// with the environment of the server.
+func (srv *Server) command(name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.Env = srv.Environ()
+	return cmd
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergeEnv(t *testing.T) {
+	environ := []string{"HOME=/home/mats", "LANG=C", "PATH=/bin:/usr/bin"}
+	vars := map[string]string{
+		"LD_LIBRARY_PATH": "/opt/mysql/lib",
+		"LANG":            "en_US.UTF-8",
+	}
+	expect := []string{
+		"HOME=/home/mats",
+		"PATH=/bin:/usr/bin",
+		"LANG=en_US.UTF-8",
+		"LD_LIBRARY_PATH=/opt/mysql/lib",
+	}
+	if result := mergeEnv(environ, vars); !reflect.DeepEqual(result, expect) {
+		t.Errorf("Expected %q, got %q", expect, result)
+	}
+}
+
+func TestServerCommandEnv(t *testing.T) {
+	os.Setenv("GOMYSQL_TEST_VAR", "outer")
+	defer os.Unsetenv("GOMYSQL_TEST_VAR")
+
+	srv := &Server{Name: "one"}
+	if err := srv.SetEnv("GOMYSQL_TEST_VAR", "inner"); err != nil {
+		t.Fatalf("Unable to set variable: %s", err)
+	}
+	if err := srv.SetEnv("TZ", "UTC"); err != nil {
+		t.Fatalf("Unable to set variable: %s", err)
+	}
+	if err := srv.SetEnv("BAD=NAME", "x"); err == nil {
+		t.Errorf("Expected error for bad variable name")
+	}
+
+	cmd := srv.command("mysqld", "--version")
+	found := map[string]int{}
+	for _, entry := range cmd.Env {
+		found[entry]++
+	}
+	if found["GOMYSQL_TEST_VAR=inner"] != 1 || found["GOMYSQL_TEST_VAR=outer"] != 0 {
+		t.Errorf("Expected server variable to replace process variable, got %q", cmd.Env)
+	}
+	if found["TZ=UTC"] != 1 {
+		t.Errorf("Expected TZ=UTC in environment, got %q", cmd.Env)
+	}
+
+	// Removing a variable should give the process environment
+	srv.SetEnv("GOMYSQL_TEST_VAR", "")
+	cmd = srv.command("mysqld", "--version")
+	for _, entry := range cmd.Env {
+		if entry == "GOMYSQL_TEST_VAR=inner" {
+			t.Errorf("Expected variable to be removed, got %q", cmd.Env)
+		}
+	}
+}
+
+func TestLibraryPath(t *testing.T) {
+	root, err := ioutil.TempDir("", "dist")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	os.Setenv("LD_LIBRARY_PATH", "/usr/local/lib")
+	defer os.Unsetenv("LD_LIBRARY_PATH")
+
+	// Without a bundled library directory, the environment is
+	// left alone.
+	dist := &Dist{Root: filepath.Join(root, "plain")}
+	makeDistTree(t, dist.Root, map[string]string{
+		"bin/mysqld": "",
+	})
+	dist.findLibDir()
+	srv := &Server{Dist: dist}
+	if env := envValue(srv.Environ(), "LD_LIBRARY_PATH"); env != "/usr/local/lib" {
+		t.Errorf("Expected LD_LIBRARY_PATH to be unchanged, got %q", env)
+	}
+
+	dist = &Dist{Root: filepath.Join(root, "bundled")}
+	makeDistTree(t, dist.Root, map[string]string{
+		"bin/mysqld":            "",
+		"lib/libmysqlclient.so": "",
+	})
+	dist.findLibDir()
+	if dist.LibDir != filepath.Join(dist.Root, "lib") {
+		t.Errorf("Expected library directory %q, got %q", filepath.Join(dist.Root, "lib"), dist.LibDir)
+	}
+	srv = &Server{Dist: dist}
+	expect := dist.LibDir + ":/usr/local/lib"
+	if env := envValue(srv.Environ(), "LD_LIBRARY_PATH"); env != expect {
+		t.Errorf("Expected LD_LIBRARY_PATH %q, got %q", expect, env)
+	}
+
+	os.Unsetenv("LD_LIBRARY_PATH")
+	if env := envValue(srv.Environ(), "LD_LIBRARY_PATH"); env != dist.LibDir {
+		t.Errorf("Expected LD_LIBRARY_PATH %q, got %q", dist.LibDir, env)
+	}
+}
+
+// envValue will return the value of the variable in the environment.
+func envValue(environ []string, name string) string {
+	for _, entry := range environ {
+		if strings.HasPrefix(entry, name+"=") {
+			return entry[len(name)+1:]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,244 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// BOOTSTRAP_LOG is the name of the bootstrap log in the log directory
+// of a server.
+const BOOTSTRAP_LOG = "bootstrap.log"
+
+// LogReader will return a reader for the error log of the server. It
+// is the responsibility of the caller to close the reader.
+func (srv *Server) LogReader() (io.ReadCloser, error) {
+	return os.Open(srv.LogPath)
+}
+
+// FOLLOW_INTERVAL is the interval between checks for new data when
+// following a log.
+const FOLLOW_INTERVAL = 250 * time.Millisecond
+
+// followReader is a reader that wait for more data to be written
+// when reaching end of file, until the stop channel is closed.
+type followReader struct {
+	io.ReadCloser
+	stop <-chan struct{}
+}
+
+func (rd *followReader) Read(buf []byte) (int, error) {
+	for {
+		n, err := rd.ReadCloser.Read(buf)
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+		select {
+		case <-rd.stop:
+			return 0, io.EOF
+		case <-time.After(FOLLOW_INTERVAL):
+		}
+	}
+}
+
+// FollowLog will return a reader that continue reading from rd when
+// more data is written to it, like "tail -f". The reader returns end
+// of file once the stop channel is closed. Closing the returned
+// reader closes rd.
+func FollowLog(rd io.ReadCloser, stop <-chan struct{}) io.ReadCloser {
+	return &followReader{ReadCloser: rd, stop: stop}
+}
+
+// TAIL_SIZE is the number of bytes at the end of a log that is read
+// when looking for the last lines of the log.
+const TAIL_SIZE = 8192
+
+// tailFile will return the last lines of the file, at most count
+// lines. Only the last TAIL_SIZE bytes of the file are read.
+func tailFile(path string, count int) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	finfo, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := finfo.Size() - TAIL_SIZE
+	if offset > 0 {
+		if _, err := file.Seek(offset, os.SEEK_SET); err != nil {
+			return nil, err
+		}
+	}
+
+	lines := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// The first line is partial if we started in the middle of
+	// the file.
+	if offset > 0 && len(lines) > 0 {
+		lines = lines[1:]
+	}
+	if len(lines) > count {
+		lines = lines[len(lines)-count:]
+	}
+	return lines, nil
+}
+
+// failedBootstrapLog will return the path where the bootstrap log is
+// saved for a server that failed to bootstrap.
+func (stable *Stable) failedBootstrapLog(name string) string {
+	return filepath.Join(stable.tmpDir, name+"-"+BOOTSTRAP_LOG)
+}
+
+// saveBootstrapLog will save a copy of the bootstrap log of the
+// server in the temporary directory of the stable, so that it is
+// available after the server directory has been removed.
+func (stable *Stable) saveBootstrapLog(srv *Server) error {
+	src, err := os.Open(srv.log(BOOTSTRAP_LOG))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(stable.failedBootstrapLog(srv.Name))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// BootstrapLogReader will return a reader for the bootstrap log of
+// the server with the name. If there is no such server, but a server
+// with the name failed to bootstrap, the saved bootstrap log is
+// returned instead. It is the responsibility of the caller to close
+// the reader.
+func (stable *Stable) BootstrapLogReader(name string) (io.ReadCloser, error) {
+	if srv, ok := stable.Server[name]; ok {
+		return os.Open(srv.log(BOOTSTRAP_LOG))
+	}
+	return os.Open(stable.failedBootstrapLog(name))
+}
+
+var (
+	// Timestamps used by MySQL 5.7 and later, for example
+	// "2014-03-12T10:23:45.123456Z".
+	isoTimeRegex = regexp.MustCompile(`^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(\.\d+)?(Z|[+-]\d\d:\d\d)`)
+
+	// Timestamps used by MySQL 5.6, for example "2014-03-12
+	// 10:23:45".
+	dateTimeRegex = regexp.MustCompile(`^\d{4}-\d\d-\d\d \d\d:\d\d:\d\d`)
+
+	// Timestamps used by MySQL 5.5 and earlier, for example
+	// "140312  9:23:45". Note that the hour is padded with space.
+	legacyTimeRegex = regexp.MustCompile(`^(\d\d)(\d\d)(\d\d) +(\d?\d):(\d\d):(\d\d)`)
+)
+
+// parseLogTime will parse the timestamp at the beginning of a line
+// from the error log. If the line does not start with a timestamp in
+// any of the formats used by the server, false is returned.
+func parseLogTime(line string) (time.Time, bool) {
+	if match := isoTimeRegex.FindString(line); match != "" {
+		if t, err := time.Parse(time.RFC3339Nano, match); err == nil {
+			return t, true
+		}
+	} else if match := dateTimeRegex.FindString(line); match != "" {
+		if t, err := time.ParseInLocation("2006-01-02 15:04:05", match, time.Local); err == nil {
+			return t, true
+		}
+	} else if match := legacyTimeRegex.FindStringSubmatch(line); match != nil {
+		field := make([]int, len(match)-1)
+		for i, str := range match[1:] {
+			field[i], _ = strconv.Atoi(str)
+		}
+		t := time.Date(2000+field[0], time.Month(field[1]), field[2],
+			field[3], field[4], field[5], 0, time.Local)
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// LogFilter is used to select lines from a server log. If Match is
+// set, only lines matching the regular expression are selected. If
+// Since is set, only lines with a timestamp that is not before Since
+// are selected, which means that lines without a timestamp are
+// skipped. If Last is positive, only the last Last selected lines are
+// copied.
+type LogFilter struct {
+	Match *regexp.Regexp
+	Since time.Time
+	Last  int
+}
+
+// Selects will return true if the line is selected by the filter.
+func (filter *LogFilter) Selects(line string) bool {
+	if !filter.Since.IsZero() {
+		if t, ok := parseLogTime(line); !ok || t.Before(filter.Since) {
+			return false
+		}
+	}
+	return filter.Match == nil || filter.Match.MatchString(line)
+}
+
+// Copy will copy all lines selected by the filter from the reader to
+// the writer, prefixing each line with the provided prefix.
+func (filter *LogFilter) Copy(wr io.Writer, rd io.Reader, prefix string) error {
+	var last []string
+	scanner := bufio.NewScanner(rd)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !filter.Selects(line) {
+			continue
+		}
+		if filter.Last > 0 {
+			if len(last) == filter.Last {
+				last = last[1:]
+			}
+			last = append(last, line)
+		} else if _, err := fmt.Fprintf(wr, "%s%s\n", prefix, line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	for _, line := range last {
+		if _, err := fmt.Fprintf(wr, "%s%s\n", prefix, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLogTime(t *testing.T) {
+	lines := map[string]time.Time{
+		"2014-03-12T10:23:45.123456Z 0 [Note] mysqld: ready for connections.":      time.Date(2014, 3, 12, 10, 23, 45, 123456000, time.UTC),
+		"2014-03-12 10:23:45 1234 [Note] mysqld: ready for connections.":           time.Date(2014, 3, 12, 10, 23, 45, 0, time.Local),
+		"140312 10:23:45 [Note] mysqld: ready for connections.":                    time.Date(2014, 3, 12, 10, 23, 45, 0, time.Local),
+		"140312  9:23:45 [Note] mysqld: ready for connections.":                    time.Date(2014, 3, 12, 9, 23, 45, 0, time.Local),
+		"140312 09:23:45 InnoDB: Completed initialization of buffer pool":          time.Date(2014, 3, 12, 9, 23, 45, 0, time.Local),
+		"2014-03-12T10:23:45+01:00 0 [Note] mysqld: ready for connections.":        time.Date(2014, 3, 12, 9, 23, 45, 0, time.UTC),
+		"2014-03-12T10:23:45.000000-01:00 0 [Note] mysqld: ready for connections.": time.Date(2014, 3, 12, 11, 23, 45, 0, time.UTC),
+	}
+
+	for line, expected := range lines {
+		if result, ok := parseLogTime(line); !ok {
+			t.Errorf("No timestamp found in %q", line)
+		} else if !result.Equal(expected) {
+			t.Errorf("Expected %v for %q, got %v", expected, line, result)
+		}
+	}
+
+	for _, line := range []string{"", "Version: '5.6.14'  socket: '/tmp/mysql.sock'", "InnoDB: Starting shutdown..."} {
+		if result, ok := parseLogTime(line); ok {
+			t.Errorf("Expected no timestamp for %q, got %v", line, result)
+		}
+	}
+}
+
+const sampleLog = `2014-03-12 10:00:00 1234 [Note] Plugin 'FEDERATED' is disabled.
+2014-03-12 10:00:01 1234 [Warning] Buffered warning: Changed limits
+2014-03-12 11:00:00 1234 [Note] Server hostname (bind-address): '*'; port: 12000
+Version: '5.6.14'  socket: '/tmp/mysql.sock'  port: 12000
+2014-03-12 12:00:00 1234 [ERROR] Can't start server: Bind on TCP/IP port
+`
+
+func filterLog(t *testing.T, filter *LogFilter) []string {
+	var buf bytes.Buffer
+	if err := filter.Copy(&buf, strings.NewReader(sampleLog), "my_server: "); err != nil {
+		t.Fatalf("Copy failed: %s", err)
+	}
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
+
+func TestLogFilterMatch(t *testing.T) {
+	lines := filterLog(t, &LogFilter{Match: regexp.MustCompile(`(?i)warning|error`)})
+	expected := []string{
+		"my_server: 2014-03-12 10:00:01 1234 [Warning] Buffered warning: Changed limits",
+		"my_server: 2014-03-12 12:00:00 1234 [ERROR] Can't start server: Bind on TCP/IP port",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+
+	// Lines without timestamps should be printed when there is
+	// no cutoff.
+	if lines := filterLog(t, &LogFilter{Match: regexp.MustCompile(`(?i)version`)}); len(lines) != 1 {
+		t.Errorf("Expected one line, got %q", lines)
+	}
+}
+
+func TestLogFilterSince(t *testing.T) {
+	since := time.Date(2014, 3, 12, 11, 0, 0, 0, time.Local)
+	lines := filterLog(t, &LogFilter{Since: since})
+	expected := []string{
+		"my_server: 2014-03-12 11:00:00 1234 [Note] Server hostname (bind-address): '*'; port: 12000",
+		"my_server: 2014-03-12 12:00:00 1234 [ERROR] Can't start server: Bind on TCP/IP port",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+
+	// Both filters should apply at the same time
+	filter := &LogFilter{Since: since, Match: regexp.MustCompile(`(?i)note`)}
+	if lines := filterLog(t, filter); len(lines) != 1 || lines[0] != expected[0] {
+		t.Errorf("Expected %q, got %q", expected[:1], lines)
+	}
+}
+
+func TestLogFilterLast(t *testing.T) {
+	lines := filterLog(t, &LogFilter{Last: 2, Match: regexp.MustCompile(`(?i)warning|error`)})
+	expected := []string{
+		"my_server: 2014-03-12 10:00:01 1234 [Warning] Buffered warning: Changed limits",
+		"my_server: 2014-03-12 12:00:00 1234 [ERROR] Can't start server: Bind on TCP/IP port",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+
+	if lines := filterLog(t, &LogFilter{Last: 1}); len(lines) != 1 || lines[0] != expected[1] {
+		t.Errorf("Expected %q, got %q", expected[1:], lines)
+	}
+}
+
+func TestFollowLog(t *testing.T) {
+	file, err := ioutil.TempFile("", "mysqld.err")
+	if err != nil {
+		t.Fatalf("Unable to create file: %s", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	file.WriteString("first\n")
+
+	srv := &Server{Name: "my_server", LogPath: file.Name()}
+	rd, err := srv.LogReader()
+	if err != nil {
+		t.Fatalf("Unable to open log: %s", err)
+	}
+	stop := make(chan struct{})
+	follow := FollowLog(rd, stop)
+	defer follow.Close()
+
+	// Lines written after reaching end of file should be read
+	// until the reader is stopped.
+	done := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		if err := (&LogFilter{}).Copy(&buf, follow, "my_server: "); err != nil {
+			t.Errorf("Copy failed: %s", err)
+		}
+		done <- buf.String()
+	}()
+	time.Sleep(2 * FOLLOW_INTERVAL)
+	file.WriteString("second\n")
+	time.Sleep(2 * FOLLOW_INTERVAL)
+	close(stop)
+
+	expected := "my_server: first\nmy_server: second\n"
+	if content := <-done; content != expected {
+		t.Errorf("Expected %q, got %q", expected, content)
+	}
+}
+
+func TestBootstrapLogReader(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	stable, err := CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+
+	// A distribution where the server fails to initialize
+	dist := &Dist{Name: "fake", Root: filepath.Join(root, "fake"), Version: "5.7.10"}
+	makeDistTree(t, dist.Root, map[string]string{
+		"bin/mysqld": "#!/bin/sh\necho 'Unable to initialize'\nexit 1\n",
+	})
+
+	if _, err := stable.AddServer("broken", dist); err == nil {
+		t.Fatalf("Expected bootstrap to fail")
+	}
+	rd, err := stable.BootstrapLogReader("broken")
+	if err != nil {
+		t.Fatalf("Unable to read saved bootstrap log: %s", err)
+	}
+	content, _ := ioutil.ReadAll(rd)
+	rd.Close()
+	if string(content) != "Unable to initialize\n" {
+		t.Errorf("Expected saved bootstrap log, got %q", content)
+	}
+
+	// For an existing server, the log in the server directory is
+	// read.
+	makeDistTree(t, dist.Root, map[string]string{
+		"bin/mysqld": "#!/bin/sh\necho 'Initialized'\n",
+	})
+	if _, err := stable.AddServer("working", dist); err != nil {
+		t.Fatalf("Unable to add server: %s", err)
+	}
+	rd, err = stable.BootstrapLogReader("working")
+	if err != nil {
+		t.Fatalf("Unable to read bootstrap log: %s", err)
+	}
+	content, _ = ioutil.ReadAll(rd)
+	rd.Close()
+	if string(content) != "Initialized\n" {
+		t.Errorf("Expected bootstrap log, got %q", content)
+	}
+
+	if _, err := stable.BootstrapLogReader("missing"); err == nil {
+		t.Errorf("Expected error for missing server")
+	}
+}
+
+func TestTailFile(t *testing.T) {
+	root, err := ioutil.TempDir("", "errlog")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	path := filepath.Join(root, "mysqld.err")
+	var buf bytes.Buffer
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&buf, "Line %d\n", i)
+	}
+	ioutil.WriteFile(path, buf.Bytes(), 0644)
+
+	lines, err := tailFile(path, 3)
+	if err != nil {
+		t.Fatalf("Unable to read %s: %s", path, err)
+	}
+	if strings.Join(lines, ",") != "Line 1997,Line 1998,Line 1999" {
+		t.Errorf("Expected last three lines, got %v", lines)
+	}
+
+	ioutil.WriteFile(path, []byte("Only line\n"), 0644)
+	if lines, err := tailFile(path, 3); err != nil || len(lines) != 1 || lines[0] != "Only line" {
+		t.Errorf("Expected the only line, got %v (%v)", lines, err)
+	}
+}
@@ -16,11 +16,28 @@
 
 package stable
 
-import "errors"
+import (
+	"errors"
+	"strings"
+)
 
 var (
 	ErrInvalidDist     = errors.New("invalid distribution")
 	ErrUnpackFailure   = errors.New("unable to unpack distribution")
 	ErrVersionNotFound = errors.New("version not found")
 	ErrStableExists    = errors.New("stable exists")
+	ErrNoOpener        = errors.New("no program to open URL with")
+	ErrDistNotFound    = errors.New("no installed distribution found")
+	ErrStableBusy      = errors.New("stable is busy")
 )
+
+// MultiError collect the errors from an operation on several servers.
+type MultiError []error
+
+func (errs MultiError) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
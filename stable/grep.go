@@ -0,0 +1,66 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"regexp"
+	"sort"
+)
+
+// ConfigMatch is an option of a server that matched when searching
+// the configuration of the servers.
+type ConfigMatch struct {
+	Server, Section, Option, Value string
+}
+
+// GrepConfig will search the options of all servers in the stable
+// and return the options where either the name or the value match
+// the regular expression. If section is not empty, only options in
+// that section are searched. The matches are ordered by server name,
+// section, and option.
+func (stable *Stable) GrepConfig(re *regexp.Regexp, section string) []ConfigMatch {
+	names := make([]string, 0, len(stable.Server))
+	for name := range stable.Server {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	matches := []ConfigMatch{}
+	for _, name := range names {
+		options := stable.Server[name].Options
+		if options == nil {
+			continue
+		}
+
+		sections := make([]string, 0, len(options.Section))
+		for sec := range options.Section {
+			if len(section) == 0 || sec == section {
+				sections = append(sections, sec)
+			}
+		}
+		sort.Strings(sections)
+
+		for _, sec := range sections {
+			options.Section[sec].Each(func(option, value string) {
+				if re.MatchString(option) || re.MatchString(value) {
+					matches = append(matches, ConfigMatch{name, sec, option, value})
+				}
+			})
+		}
+	}
+	return matches
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"mysqld/cnf"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestGrepConfig(t *testing.T) {
+	makeServer := func(name string, options map[string]map[string]string) *Server {
+		srv := &Server{Name: name, Options: cnf.New()}
+		srv.Options.Import(options)
+		return srv
+	}
+
+	stable := &Stable{
+		Server: map[string]*Server{
+			"beta": makeServer("beta", map[string]map[string]string{
+				"mysqld": {"log-bin": "master-bin", "port": "3307"},
+				"mysql":  {"port": "3307"},
+			}),
+			"alpha": makeServer("alpha", map[string]map[string]string{
+				"mysqld": {"binlog_format": "ROW", "port": "3306"},
+			}),
+		},
+	}
+
+	// Match on both option name and value
+	matches := stable.GrepConfig(regexp.MustCompile("bin"), "")
+	expect := []ConfigMatch{
+		{"alpha", "mysqld", "binlog_format", "ROW"},
+		{"beta", "mysqld", "log_bin", "master-bin"},
+	}
+	if !reflect.DeepEqual(matches, expect) {
+		t.Errorf("Expected %v, got %v", expect, matches)
+	}
+
+	matches = stable.GrepConfig(regexp.MustCompile("^3307$"), "")
+	expect = []ConfigMatch{
+		{"beta", "mysql", "port", "3307"},
+		{"beta", "mysqld", "port", "3307"},
+	}
+	if !reflect.DeepEqual(matches, expect) {
+		t.Errorf("Expected %v, got %v", expect, matches)
+	}
+
+	// Restricting the search to a section
+	matches = stable.GrepConfig(regexp.MustCompile("port"), "mysql")
+	expect = []ConfigMatch{
+		{"beta", "mysql", "port", "3307"},
+	}
+	if !reflect.DeepEqual(matches, expect) {
+		t.Errorf("Expected %v, got %v", expect, matches)
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+// ServerInfo hold the details needed to connect to a server.
+type ServerInfo struct {
+	Name     string `json:"name"`
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	ServerId int    `json:"server_id"`
+	Socket   string `json:"socket"`
+}
+
+// Info will return the connection details of the server.
+func (srv *Server) Info() ServerInfo {
+	return ServerInfo{
+		Name:     srv.Name,
+		Host:     srv.Host,
+		Port:     srv.Port,
+		ServerId: srv.ServerId,
+		Socket:   srv.Socket,
+	}
+}
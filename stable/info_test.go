@@ -0,0 +1,65 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServerInfo(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	stable, err := CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+
+	dist := &Dist{Name: "fake", Root: filepath.Join(root, "fake"), Version: "5.6.19"}
+	for i, name := range []string{"slave.1", "slave.2"} {
+		srv, err := stable.newServer(name, dist)
+		if err != nil {
+			t.Fatalf("Unable to create server: %s", err)
+		}
+
+		info := srv.Info()
+		if info.Name != name || info.Port != 12000+i || info.ServerId != 1+i {
+			t.Errorf("Expected %s with port %d and server id %d, got %+v",
+				name, 12000+i, 1+i, info)
+		}
+		if info.Socket != srv.Socket {
+			t.Errorf("Expected socket %q, got %q", srv.Socket, info.Socket)
+		}
+	}
+
+	info := ServerInfo{"master", "localhost", 12000, 1, "/tmp/master.sock"}
+	text, err := json.Marshal(info)
+	if err != nil {
+		t.Fatalf("Unable to marshal info: %s", err)
+	}
+	expect := `{"name":"master","host":"localhost","port":12000,"server_id":1,"socket":"/tmp/master.sock"}`
+	if string(text) != expect {
+		t.Errorf("Expected %s, got %s", expect, text)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"syscall"
+)
+
+// RLIM_INFINITY is the value used for limits that are unlimited.
+const RLIM_INFINITY = ^uint64(0)
+
+// limitResources map the names of the start limits to the resources
+// they limit.
+var limitResources = map[string]int{
+	"nofile": syscall.RLIMIT_NOFILE,
+	"core":   syscall.RLIMIT_CORE,
+	"memory": syscall.RLIMIT_AS,
+	"data":   syscall.RLIMIT_DATA,
+	"stack":  syscall.RLIMIT_STACK,
+}
+
+// LimitNames will return the names of the limits that can be set for
+// a server, sorted by name.
+func LimitNames() []string {
+	names := make([]string, 0, len(limitResources))
+	for name := range limitResources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResourceLimit is a limit to set for a resource of the server
+// process, using setrlimit(2).
+type ResourceLimit struct {
+	Resource int
+	syscall.Rlimit
+}
+
+// SetLimit will set the start limit with the name to the value,
+// which is either a number or "unlimited".
+func (srv *Server) SetLimit(name, value string) error {
+	if _, ok := limitResources[name]; !ok {
+		return fmt.Errorf("Unknown limit %q", name)
+	}
+
+	limit := RLIM_INFINITY
+	if value != "unlimited" {
+		var err error
+		if limit, err = strconv.ParseUint(value, 10, 64); err != nil {
+			return fmt.Errorf("Bad value %q for limit %q", value, name)
+		}
+	}
+
+	if srv.StartLimits == nil {
+		srv.StartLimits = make(map[string]uint64)
+	}
+	srv.StartLimits[name] = limit
+	return nil
+}
+
+// ResourceLimits will return the start limits of the server as
+// resource limits to apply to the process, ordered by limit name.
+// Both the soft and the hard limit are set to the value of the limit.
+func (srv *Server) ResourceLimits() ([]ResourceLimit, error) {
+	names := make([]string, 0, len(srv.StartLimits))
+	for name := range srv.StartLimits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	limits := []ResourceLimit{}
+	for _, name := range names {
+		resource, ok := limitResources[name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown limit %q for server %s", name, srv.Name)
+		}
+		value := srv.StartLimits[name]
+		limits = append(limits, ResourceLimit{
+			Resource: resource,
+			Rlimit:   makeRlimit(value),
+		})
+	}
+	return limits, nil
+}
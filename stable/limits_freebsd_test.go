@@ -0,0 +1,37 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestMakeRlimit(t *testing.T) {
+	tests := []struct {
+		value  uint64
+		expect syscall.Rlimit
+	}{
+		{1024, syscall.Rlimit{Cur: 1024, Max: 1024}},
+		{RLIM_INFINITY, syscall.Rlimit{Cur: -1, Max: -1}},
+	}
+	for _, test := range tests {
+		if limit := makeRlimit(test.value); limit != test.expect {
+			t.Errorf("Expected %v for %d, got %v", test.expect, test.value, limit)
+		}
+	}
+}
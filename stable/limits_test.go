@@ -0,0 +1,122 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestResourceLimits(t *testing.T) {
+	srv := &Server{Name: "one"}
+	if limits, err := srv.ResourceLimits(); err != nil || len(limits) > 0 {
+		t.Errorf("Expected no limits, got %v (error %v)", limits, err)
+	}
+
+	settings := map[string]string{
+		"nofile": "1024",
+		"core":   "unlimited",
+		"memory": "1073741824",
+	}
+	for name, value := range settings {
+		if err := srv.SetLimit(name, value); err != nil {
+			t.Errorf("Unable to set limit %q: %s", name, err)
+		}
+	}
+	if err := srv.SetLimit("cpu", "10"); err == nil {
+		t.Errorf("Expected error for unknown limit")
+	}
+	if err := srv.SetLimit("nofile", "many"); err == nil {
+		t.Errorf("Expected error for bad value")
+	}
+
+	limits, err := srv.ResourceLimits()
+	if err != nil {
+		t.Fatalf("Unable to get limits: %s", err)
+	}
+	expect := []ResourceLimit{
+		{syscall.RLIMIT_CORE, makeRlimit(RLIM_INFINITY)},
+		{syscall.RLIMIT_AS, makeRlimit(1 << 30)},
+		{syscall.RLIMIT_NOFILE, makeRlimit(1024)},
+	}
+	if !reflect.DeepEqual(limits, expect) {
+		t.Errorf("Expected limits %v, got %v", expect, limits)
+	}
+
+	// Limits read from a configuration file can be unknown
+	srv.StartLimits["cpu"] = 10
+	if _, err := srv.ResourceLimits(); err == nil {
+		t.Errorf("Expected error for unknown limit")
+	}
+}
+
+func TestStartLimits(t *testing.T) {
+	root, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	// The limits are set before the binary is executed, so the
+	// script can check them right away.
+	outPath := filepath.Join(root, "nofile")
+	binPath := filepath.Join(root, "mysqld")
+	script := "#!/bin/sh\nulimit -n > " + outPath + ".tmp\nmv " + outPath + ".tmp " + outPath + "\n"
+	if err := ioutil.WriteFile(binPath, []byte(script), 0755); err != nil {
+		t.Fatalf("Unable to write script: %s", err)
+	}
+
+	limits := []ResourceLimit{{syscall.RLIMIT_NOFILE, syscall.Rlimit{Cur: 64, Max: 64}}}
+	err = forkDaemon(binPath, root, filepath.Join(root, "mysqld.err"), []string{"mysqld"}, nil, limits)
+	if err != nil {
+		t.Fatalf("Unable to start daemon: %s", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if content, err := ioutil.ReadFile(outPath); err == nil {
+			if limit := strings.TrimSpace(string(content)); limit != "64" {
+				t.Errorf("Expected open files limit 64, got %q", limit)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Daemon did not report its limits")
+}
+
+func TestStartLimitsError(t *testing.T) {
+	root, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	// A binary that cannot be executed is reported by the shim
+	binPath := filepath.Join(root, "mysqld")
+	limits := []ResourceLimit{{syscall.RLIMIT_NOFILE, syscall.Rlimit{Cur: 64, Max: 64}}}
+	err = forkDaemon(binPath, root, filepath.Join(root, "mysqld.err"), []string{"mysqld"}, nil, limits)
+	if err == nil || !strings.Contains(err.Error(), binPath) {
+		t.Errorf("Expected error executing %s, got %v", binPath, err)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+const (
+	// LOCK_FILE is the name of the lock file in the stable
+	// directory.
+	LOCK_FILE = "lock"
+
+	// LOCK_TIMEOUT is the default time to wait for another
+	// process to release the stable.
+	LOCK_TIMEOUT = 10 * time.Second
+
+	// LOCK_POLL_INTERVAL is the interval between attempts to
+	// acquire the lock.
+	LOCK_POLL_INTERVAL = 100 * time.Millisecond
+)
+
+// Lock will acquire an exclusive lock on the stable, protecting the
+// configuration from concurrent updates by other processes. If
+// another process holds the lock, Lock will wait at most timeout for
+// it to be released before returning ErrStableBusy.
+func (stable *Stable) Lock(timeout time.Duration) error {
+	if stable.lockFile != nil {
+		return nil
+	}
+
+	file, err := os.OpenFile(filepath.Join(stable.Root, LOCK_FILE), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err != syscall.EWOULDBLOCK || !time.Now().Before(deadline) {
+			break
+		}
+		time.Sleep(LOCK_POLL_INTERVAL)
+	}
+	if err != nil {
+		file.Close()
+		if err == syscall.EWOULDBLOCK {
+			return ErrStableBusy
+		}
+		return err
+	}
+
+	stable.lockFile = file
+	return nil
+}
+
+// Unlock will release the lock on the stable, if it is held.
+func (stable *Stable) Unlock() error {
+	if stable.lockFile == nil {
+		return nil
+	}
+	file := stable.lockFile
+	stable.lockFile = nil
+	syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+	return file.Close()
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestLock(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	first, err := CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+	second, err := OpenStable(root)
+	if err != nil {
+		t.Fatalf("Unable to open stable: %s", err)
+	}
+
+	if err := first.Lock(0); err != nil {
+		t.Fatalf("Lock: %s", err)
+	}
+	if err := first.Lock(0); err != nil {
+		t.Errorf("Locking a locked stable again failed: %s", err)
+	}
+	if err := second.Lock(2 * LOCK_POLL_INTERVAL); err != ErrStableBusy {
+		t.Errorf("Expected %q, got %v", ErrStableBusy, err)
+	}
+
+	// Releasing the lock while the second stable is waiting for it
+	// should let the second stable acquire it.
+	done := make(chan error)
+	go func() { done <- second.Lock(LOCK_TIMEOUT) }()
+	if err := first.Unlock(); err != nil {
+		t.Errorf("Unlock: %s", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Lock after unlock: %s", err)
+	}
+	if err := first.Lock(0); err != ErrStableBusy {
+		t.Errorf("Expected %q, got %v", ErrStableBusy, err)
+	}
+	if err := second.Unlock(); err != nil {
+		t.Errorf("Unlock: %s", err)
+	}
+}
+
+func TestReadConfigAfterLock(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	first, err := CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+	dist, _ := first.newDist()
+	dist.Name = "my_test"
+	first.Distro[dist.Name] = dist
+	for _, name := range []string{"master", "slave"} {
+		srv, err := first.newServer(name, dist)
+		if err != nil {
+			t.Fatalf("Unable to create server %s: %s", name, err)
+		}
+		first.Server[name] = srv
+	}
+	if err := first.WriteConfig(); err != nil {
+		t.Fatalf("Unable to write configuration: %s", err)
+	}
+
+	// Another process removes a server and the distribution after
+	// the stable was opened but before the lock is taken.
+	second, err := OpenStable(root)
+	if err != nil {
+		t.Fatalf("Unable to open stable: %s", err)
+	}
+	other, err := OpenStable(root)
+	if err != nil {
+		t.Fatalf("Unable to open stable: %s", err)
+	}
+	delete(other.Server, "slave")
+	delete(other.Server, "master")
+	delete(other.Distro, "my_test")
+	if err := other.WriteConfig(); err != nil {
+		t.Fatalf("Unable to write configuration: %s", err)
+	}
+
+	if err := second.Lock(0); err != nil {
+		t.Fatalf("Lock: %s", err)
+	}
+	defer second.Unlock()
+	if err := second.ReadConfig(); err != nil {
+		t.Fatalf("Unable to read configuration: %s", err)
+	}
+	if len(second.Server) > 0 {
+		t.Errorf("Expected no servers after re-read, got %v", second.Server)
+	}
+	if len(second.Distro) > 0 {
+		t.Errorf("Expected no distributions after re-read, got %v", second.Distro)
+	}
+}
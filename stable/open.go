@@ -0,0 +1,95 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strconv"
+)
+
+// Launcher is used to find and run programs. The default launcher
+// use the real programs, but a different launcher can be provided to
+// check what would be run.
+type Launcher interface {
+	// LookPath return the path to an executable, similar to
+	// exec.LookPath.
+	LookPath(file string) (string, error)
+
+	// Run start the program with the arguments and wait for
+	// it to finish.
+	Run(path string, args ...string) error
+}
+
+type systemLauncher struct{}
+
+func (systemLauncher) LookPath(file string) (string, error) {
+	return exec.LookPath(file)
+}
+
+func (systemLauncher) Run(path string, args ...string) error {
+	return exec.Command(path, args...).Run()
+}
+
+// SystemLauncher is the launcher that runs real programs.
+var SystemLauncher Launcher = systemLauncher{}
+
+// openers are the programs used to open URLs with the registered
+// handler on each operating system.
+var openers = map[string]string{
+	"darwin":  "open",
+	"linux":   "xdg-open",
+	"freebsd": "xdg-open",
+	"openbsd": "xdg-open",
+	"netbsd":  "xdg-open",
+}
+
+// URL will return the connection URL for the server, for example
+// "mysql://root@localhost:3306". A password that is not stored
+// literally is left out of the URL.
+func (srv *Server) URL() string {
+	u := url.URL{
+		Scheme: "mysql",
+		Host:   srv.Host + ":" + strconv.Itoa(srv.Port),
+	}
+	if len(srv.Password) > 0 && !isSecretRef(srv.Password) {
+		u.User = url.UserPassword(srv.User, srv.Password)
+	} else if len(srv.User) > 0 {
+		u.User = url.User(srv.User)
+	}
+	return u.String()
+}
+
+// Open will open the connection URL of the server using the program
+// registered for opening URLs on the system, for example a MySQL
+// GUI. If there is no such program, ErrNoOpener is returned.
+func (srv *Server) Open(launcher Launcher) error {
+	return openURL(launcher, runtime.GOOS, srv.URL())
+}
+
+func openURL(launcher Launcher, goos, url string) error {
+	opener, ok := openers[goos]
+	if !ok {
+		return ErrNoOpener
+	}
+	path, err := launcher.LookPath(opener)
+	if err != nil {
+		return ErrNoOpener
+	}
+	return launcher.Run(path, url)
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"fmt"
+	"mysqld/cnf"
+	"strconv"
+	"strings"
+)
+
+// OptionChange is a change to an option in a section of the server
+// options. If Remove is set, the option is removed, otherwise it is
+// set to Value. If Flag is set, the option is set without a value.
+type OptionChange struct {
+	Section, Option, Value string
+	Flag, Remove           bool
+}
+
+// ParseOptionChange will parse a change given as
+// "section.option=value" to set an option, "section.option" to set a
+// flag, or "section.option-" to remove an option.
+func ParseOptionChange(str string) (*OptionChange, error) {
+	change := &OptionChange{}
+	name := str
+	if i := strings.Index(str, "="); i >= 0 {
+		name, change.Value = str[:i], str[i+1:]
+	} else if strings.HasSuffix(str, "-") {
+		name, change.Remove = str[:len(str)-1], true
+	} else {
+		change.Flag = true
+	}
+
+	i := strings.Index(name, ".")
+	if i <= 0 || i == len(name)-1 {
+		return nil, fmt.Errorf("Expected SECTION.OPTION, got %q", str)
+	}
+	change.Section, change.Option = name[:i], name[i+1:]
+	return change, nil
+}
+
+// ChangeOptions will apply the changes to the options of the server
+// and write the configuration file of the server. Options of adopted
+// servers cannot be changed since the configuration file is not
+// owned by the stable. Note that a running server has to be
+// restarted for the changes to take effect.
+func (srv *Server) ChangeOptions(changes []*OptionChange) error {
+	if srv.Adopted {
+		return fmt.Errorf("Cannot change options of adopted server %s", srv.Name)
+	}
+	srv.applyOptionChanges(changes)
+	return srv.Options.WriteFile(srv.ConfigFile)
+}
+
+// applyOptionChanges will apply the changes to the options of the
+// server, without writing the configuration file.
+func (srv *Server) applyOptionChanges(changes []*OptionChange) {
+	for _, change := range changes {
+		sec, ok := srv.Options.Section[change.Section]
+		if change.Remove {
+			if ok {
+				sec.Remove(change.Option)
+			}
+			continue
+		}
+		if !ok {
+			sec, _ = srv.Options.AddSection(change.Section)
+		}
+		if change.Flag {
+			sec.SetFlag(change.Option)
+		} else {
+			sec.SetString(change.Option, change.Value)
+		}
+	}
+}
+
+// overrideOptions will apply the changes to the generated options of
+// a new server. If the port, socket, or server id of the server is
+// changed, the server is updated to use them, and so are the client
+// sections unless the changes set the same option for the client.
+func (srv *Server) overrideOptions(changes []*OptionChange) error {
+	srv.applyOptionChanges(changes)
+
+	mysqld := srv.Options.Section["mysqld"]
+	port, err := mysqld.GetInt("port")
+	if err != nil {
+		return err
+	}
+	serverId, err := mysqld.GetInt("server_id")
+	if err != nil {
+		return err
+	}
+	srv.Port, srv.ServerId = port, serverId
+	srv.Socket = mysqld.GetString("socket")
+
+	changed := func(section, option string) bool {
+		for _, change := range changes {
+			if change.Section == section && cnf.CanonicalOption(change.Option) == option {
+				return true
+			}
+		}
+		return false
+	}
+	clients := map[string]map[string]string{
+		"mysqladmin": {"port": strconv.Itoa(srv.Port), "socket": srv.Socket},
+		"mysql":      {"port": strconv.Itoa(srv.Port)},
+	}
+	for name, options := range clients {
+		sec, ok := srv.Options.Section[name]
+		if !ok {
+			continue
+		}
+		for option, value := range options {
+			if sec.Has(option) && !changed(name, option) {
+				sec.SetString(option, value)
+			}
+		}
+	}
+	return nil
+}
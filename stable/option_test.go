@@ -0,0 +1,223 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"io/ioutil"
+	"mysqld/cnf"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParseOptionChange(t *testing.T) {
+	tests := map[string]*OptionChange{
+		"mysqld.log-bin=master-bin": {Section: "mysqld", Option: "log-bin", Value: "master-bin"},
+		"mysqld.init-file=":         {Section: "mysqld", Option: "init-file"},
+		"mysqld.skip-networking":    {Section: "mysqld", Option: "skip-networking", Flag: true},
+		"mysqld.log-bin-":           {Section: "mysqld", Option: "log-bin", Remove: true},
+		"client.ssl.ca=a=b":         {Section: "client", Option: "ssl.ca", Value: "a=b"},
+	}
+	for str, expected := range tests {
+		change, err := ParseOptionChange(str)
+		if err != nil {
+			t.Errorf("Parsing %q: %s", str, err)
+		} else if !reflect.DeepEqual(change, expected) {
+			t.Errorf("Parsing %q: expected %+v, got %+v", str, expected, change)
+		}
+	}
+
+	for _, str := range []string{"log-bin=master-bin", ".log-bin", "mysqld.", "mysqld.-"} {
+		if _, err := ParseOptionChange(str); err == nil {
+			t.Errorf("Parsing %q: expected error", str)
+		}
+	}
+}
+
+func TestChangeOptions(t *testing.T) {
+	root, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	srv := &Server{
+		Name:       "my_server",
+		ConfigFile: filepath.Join(root, "my.cnf"),
+		Options:    cnf.New(),
+	}
+	srv.Options.Import(map[string]map[string]string{
+		"mysqld": {"port": "12000", "log-bin": "master-bin"},
+	})
+
+	var changes []*OptionChange
+	for _, str := range []string{"mysqld.port=12345", "mysqld.log-bin-", "mysqld.skip-networking", "client.user=root", "mysqladmin.socket-"} {
+		change, err := ParseOptionChange(str)
+		if err != nil {
+			t.Fatalf("Parsing %q: %s", str, err)
+		}
+		changes = append(changes, change)
+	}
+	if err := srv.ChangeOptions(changes); err != nil {
+		t.Fatalf("ChangeOptions: %s", err)
+	}
+
+	// The configuration file should reflect the changes.
+	config, err := cnf.ReadFile(srv.ConfigFile)
+	if err != nil {
+		t.Fatalf("Unable to read configuration file: %s", err)
+	}
+	mysqld := config.Section["mysqld"]
+	if port := mysqld.GetString("port"); port != "12345" {
+		t.Errorf("Expected port 12345, got %q", port)
+	}
+	if mysqld.Has("log_bin") {
+		t.Errorf("Expected log_bin to be removed")
+	}
+	if !mysqld.IsFlag("skip_networking") {
+		t.Errorf("Expected skip_networking to be a flag")
+	}
+	if !config.HasSection("client") || config.Section["client"].GetString("user") != "root" {
+		t.Errorf("Expected user to be set in client section")
+	}
+	if config.HasSection("mysqladmin") {
+		t.Errorf("Removing an option should not add a section")
+	}
+
+	srv.Adopted = true
+	if err := srv.ChangeOptions(changes); err == nil {
+		t.Errorf("Expected error when changing options of adopted server")
+	}
+}
+
+func TestChangeOptionsReopened(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	stable, err := CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+	dist := &Dist{Name: "fake", Root: filepath.Join(root, "fake"), Version: "5.7.10"}
+	makeDistTree(t, dist.Root, map[string]string{
+		"bin/mysqld": "#!/bin/sh\necho 'Initialized'\n",
+	})
+	if _, err := stable.AddServer("my_server", dist); err != nil {
+		t.Fatalf("Unable to add server: %s", err)
+	}
+	if err := stable.WriteConfig(); err != nil {
+		t.Fatalf("Unable to write configuration: %s", err)
+	}
+
+	// The options of the server should survive reopening the
+	// stable, so that changing them keeps the other options.
+	stable, err = OpenStable(root)
+	if err != nil {
+		t.Fatalf("Unable to open stable: %s", err)
+	}
+	srv := stable.Server["my_server"]
+	port, ok := srv.Options.Get("mysqld", "port")
+	if !ok || port != strconv.Itoa(srv.Port) {
+		t.Errorf("Expected port %d after reopening, got %q", srv.Port, port)
+	}
+
+	change, err := ParseOptionChange("mysqld.log-bin=master-bin")
+	if err != nil {
+		t.Fatalf("Parsing option change: %s", err)
+	}
+	if err := srv.ChangeOptions([]*OptionChange{change}); err != nil {
+		t.Fatalf("ChangeOptions: %s", err)
+	}
+	config, err := cnf.ReadFile(srv.ConfigFile)
+	if err != nil {
+		t.Fatalf("Unable to read configuration file: %s", err)
+	}
+	if value, _ := config.Get("mysqld", "log_bin"); value != "master-bin" {
+		t.Errorf("Expected log_bin to be %q, got %q", "master-bin", value)
+	}
+	if value, _ := config.Get("mysqld", "port"); value != port {
+		t.Errorf("Expected port %q to be kept, got %q", port, value)
+	}
+}
+
+func TestAddServerOptions(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	stable, err := CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+	dist := &Dist{Name: "fake", Root: filepath.Join(root, "fake"), Version: "5.7.10"}
+	makeDistTree(t, dist.Root, map[string]string{
+		"bin/mysqld": "#!/bin/sh\necho 'Initialized'\n",
+	})
+
+	var changes []*OptionChange
+	for _, str := range []string{"mysqld.port=23456", "mysqld.innodb-buffer-pool-size=512M", "mysql.port=34567", "mysqld.log-bin=ON"} {
+		change, err := ParseOptionChange(str)
+		if err != nil {
+			t.Fatalf("Parsing %q: %s", str, err)
+		}
+		changes = append(changes, change)
+	}
+	srv, err := stable.AddServerOptions("my_server", dist, "", changes)
+	if err != nil {
+		t.Fatalf("Unable to add server: %s", err)
+	}
+
+	config, err := cnf.ReadFile(srv.ConfigFile)
+	if err != nil {
+		t.Fatalf("Unable to read configuration file: %s", err)
+	}
+	expected := map[string]map[string]string{
+		"mysqld": {
+			"port":                    "23456",
+			"innodb_buffer_pool_size": "512M",
+			"log_bin":                 "ON",
+		},
+		"mysqladmin": {"port": "23456"},
+		"mysql":      {"port": "34567"},
+	}
+	for section, options := range expected {
+		for option, value := range options {
+			if got := config.Section[section].GetString(option); got != value {
+				t.Errorf("Expected %s.%s to be %q, got %q", section, option, value, got)
+			}
+		}
+	}
+	if srv.Port != 23456 {
+		t.Errorf("Expected server port 23456, got %d", srv.Port)
+	}
+
+	// Options of other servers should not be affected.
+	other, err := stable.AddServer("other", dist)
+	if err != nil {
+		t.Fatalf("Unable to add server: %s", err)
+	}
+	if other.Options.Section["mysqld"].Has("log_bin") {
+		t.Errorf("Expected no log_bin option for other server")
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import "sync"
+
+// ForEach will call fn for each of the servers, with at most jobs
+// calls running at the same time. If jobs is less than one, the calls
+// are made one at a time. The errors returned by the calls are
+// collected in a MultiError, in the same order as the servers, and
+// nil is returned if all calls succeeded.
+func ForEach(servers []*Server, jobs int, fn func(*Server) error) error {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	results := make([]error, len(servers))
+	slots := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i, srv := range servers {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int, srv *Server) {
+			defer wg.Done()
+			results[i] = fn(srv)
+			<-slots
+		}(i, srv)
+	}
+	wg.Wait()
+
+	var errs MultiError
+	for _, err := range results {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestForEach(t *testing.T) {
+	servers := []*Server{}
+	for i := 0; i < 8; i++ {
+		servers = append(servers, &Server{Name: fmt.Sprintf("server%d", i)})
+	}
+
+	// Track the number of calls running at the same time.
+	var mutex sync.Mutex
+	running, peak := 0, 0
+	called := map[string]bool{}
+	work := func(srv *Server) error {
+		mutex.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		called[srv.Name] = true
+		mutex.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mutex.Lock()
+		running--
+		mutex.Unlock()
+		if srv.Name == "server2" || srv.Name == "server5" {
+			return errors.New(srv.Name + " failed")
+		}
+		return nil
+	}
+
+	for _, jobs := range []int{0, 1, 3} {
+		peak = 0
+		called = map[string]bool{}
+		err := ForEach(servers, jobs, work)
+		errs, ok := err.(MultiError)
+		if !ok || len(errs) != 2 || errs.Error() != "server2 failed; server5 failed" {
+			t.Errorf("Jobs %d: expected errors for server2 and server5, got %v", jobs, err)
+		}
+		if len(called) != len(servers) {
+			t.Errorf("Jobs %d: expected all servers to be handled, got %v", jobs, called)
+		}
+		if limit := jobs; peak > limit && (limit > 0 || peak > 1) {
+			t.Errorf("Jobs %d: %d calls running at the same time", jobs, peak)
+		}
+	}
+
+	if err := ForEach(servers, 4, func(*Server) error { return nil }); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestForEachStart(t *testing.T) {
+	root, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	// The servers keep running for a while, so that a server
+	// started at the same time as another one would keep any
+	// descriptor it inherited open.
+	binPath := filepath.Join(root, "mysqld")
+	if err := ioutil.WriteFile(binPath, []byte("#!/bin/sh\nsleep 2\n"), 0755); err != nil {
+		t.Fatalf("Unable to write script: %s", err)
+	}
+	servers := []*Server{}
+	for i := 0; i < 16; i++ {
+		name := fmt.Sprintf("server%d", i)
+		servers = append(servers, &Server{
+			Name:       name,
+			Host:       "localhost",
+			BaseDir:    root,
+			BinPath:    binPath,
+			ConfigFile: filepath.Join(root, name+".cnf"),
+			LogPath:    filepath.Join(root, name+".err"),
+			PidPath:    filepath.Join(root, name+".pid"),
+			probe:      newFakeProbe(),
+		})
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ForEach(servers, len(servers), func(srv *Server) error {
+			return srv.Start()
+		})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Unable to start servers: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Starting servers in parallel did not finish")
+	}
+}
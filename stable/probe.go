@@ -0,0 +1,87 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Probe is used to inspect files and processes of a server and to
+// send signals to it. The default probe use the real system calls,
+// but a different probe can be provided to simulate servers that are
+// running, stopped, or have crashed.
+type Probe interface {
+	// Alive return true if the process with the PID exists.
+	Alive(pid int) bool
+
+	// Stat return information about the file.
+	Stat(path string) (os.FileInfo, error)
+
+	// Signal send a signal to the process with the PID.
+	Signal(pid int, sig syscall.Signal) error
+
+	// Connect return nil if a connection can be made to the
+	// address, similar to net.Dial.
+	Connect(network, address string) error
+}
+
+// PING_TIMEOUT is the time to wait for a connection when checking if
+// a server accepts connections.
+const PING_TIMEOUT = 2 * time.Second
+
+type systemProbe struct{}
+
+func (systemProbe) Alive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+func (systemProbe) Stat(path string) (os.FileInfo, error) {
+	return os.Stat(path)
+}
+
+func (systemProbe) Signal(pid int, sig syscall.Signal) error {
+	return syscall.Kill(pid, sig)
+}
+
+func (systemProbe) Connect(network, address string) error {
+	conn, err := net.DialTimeout(network, address, PING_TIMEOUT)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// SystemProbe is the probe used by servers unless another probe is
+// set. It inspects the real files and processes.
+var SystemProbe Probe = systemProbe{}
+
+// SetProbe will set the probe to use for the server.
+func (srv *Server) SetProbe(probe Probe) {
+	srv.probe = probe
+}
+
+// prober will return the probe to use for the server.
+func (srv *Server) prober() Probe {
+	if srv.probe == nil {
+		return SystemProbe
+	}
+	return srv.probe
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeProbe simulate processes that are alive until a deadline. A
+// process receiving TERM will exit immediately unless ignoreTerm is
+// set, while a process receiving KILL always exit.
+type fakeProbe struct {
+	sync.Mutex
+	deadline   map[int]time.Time
+	signals    []syscall.Signal
+	listening  map[string]bool
+	ignoreTerm bool
+}
+
+func newFakeProbe() *fakeProbe {
+	return &fakeProbe{
+		deadline:  make(map[int]time.Time),
+		listening: make(map[string]bool),
+	}
+}
+
+func (probe *fakeProbe) Alive(pid int) bool {
+	probe.Lock()
+	defer probe.Unlock()
+	deadline, ok := probe.deadline[pid]
+	return ok && time.Now().Before(deadline)
+}
+
+func (probe *fakeProbe) Stat(path string) (os.FileInfo, error) {
+	return os.Stat(path)
+}
+
+func (probe *fakeProbe) Connect(network, address string) error {
+	probe.Lock()
+	defer probe.Unlock()
+	if !probe.listening[network+":"+address] {
+		return syscall.ECONNREFUSED
+	}
+	return nil
+}
+
+func (probe *fakeProbe) Signal(pid int, sig syscall.Signal) error {
+	probe.Lock()
+	defer probe.Unlock()
+	if _, ok := probe.deadline[pid]; !ok {
+		return syscall.ESRCH
+	}
+	probe.signals = append(probe.signals, sig)
+	if (sig == syscall.SIGTERM && !probe.ignoreTerm) || sig == syscall.SIGKILL {
+		probe.deadline[pid] = time.Now()
+	}
+	return nil
+}
+
+// listen will simulate that something is accepting connections on
+// the address, or stopped doing so if on is false.
+func (probe *fakeProbe) listen(network, address string, on bool) {
+	probe.Lock()
+	defer probe.Unlock()
+	probe.listening[network+":"+address] = on
+}
+
+// start will simulate that a process with the given PID is started
+// for the server and will run for the given duration.
+func (probe *fakeProbe) start(t *testing.T, srv *Server, pid int, duration time.Duration) {
+	probe.Lock()
+	probe.deadline[pid] = time.Now().Add(duration)
+	probe.Unlock()
+	if err := ioutil.WriteFile(srv.PidPath, []byte(fmt.Sprintf("%d\n", pid)), 0644); err != nil {
+		t.Fatalf("Unable to write PID file: %s", err)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	root, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	probe := newFakeProbe()
+	srv := &Server{
+		Name:    "my_server",
+		Host:    "localhost",
+		PidPath: filepath.Join(root, "mysqld.pid"),
+	}
+	srv.SetProbe(probe)
+
+	// No PID file
+	if status := srv.Status(); status != SERVER_UNAVAIL {
+		t.Errorf("Expected status %v without PID file, got %v", Status(SERVER_UNAVAIL), status)
+	}
+	if _, err := srv.Pid(); err == nil {
+		t.Errorf("Expected error from Pid without PID file, got none")
+	}
+
+	// PID file and a live process
+	probe.start(t, srv, 4711, time.Hour)
+	if status := srv.Status(); status != SERVER_RUNNING {
+		t.Errorf("Expected status %v with running process, got %v", Status(SERVER_RUNNING), status)
+	}
+	if pid, err := srv.Pid(); err != nil || pid != 4711 {
+		t.Errorf("Expected PID 4711, got %d (error: %v)", pid, err)
+	}
+
+	// PID file but the process is gone
+	if err := srv.Stop(); err != nil {
+		t.Errorf("Unexpected error when stopping: %s", err)
+	}
+	if len(probe.signals) != 1 || probe.signals[0] != syscall.SIGTERM {
+		t.Errorf("Expected TERM to be sent, got %v", probe.signals)
+	}
+	if status := srv.Status(); status != SERVER_CRASHED {
+		t.Errorf("Expected status %v with stale PID file, got %v", Status(SERVER_CRASHED), status)
+	}
+
+	// Garbage in the PID file
+	ioutil.WriteFile(srv.PidPath, []byte("garbage\n"), 0644)
+	if _, err := srv.Pid(); err == nil {
+		t.Errorf("Expected error from Pid with bad PID file, got none")
+	}
+	if status := srv.Status(); status != SERVER_CRASHED {
+		t.Errorf("Expected status %v with bad PID file, got %v", Status(SERVER_CRASHED), status)
+	}
+
+	// Removing the stale PID file make the server stopped
+	if removed, err := srv.RemoveStalePidFile(); err != nil || !removed {
+		t.Errorf("Expected stale PID file to be removed, got %v (error: %v)", removed, err)
+	}
+	if status := srv.Status(); status != SERVER_UNAVAIL {
+		t.Errorf("Expected status %v after removing PID file, got %v", Status(SERVER_UNAVAIL), status)
+	}
+	if removed, _ := srv.RemoveStalePidFile(); removed {
+		t.Errorf("Expected nothing to remove for a stopped server")
+	}
+}
+
+func TestRemoteStatus(t *testing.T) {
+	probe := newFakeProbe()
+	srv := &Server{
+		Name: "remote",
+		Host: "db.example.com",
+		Port: 3306,
+	}
+	srv.SetProbe(probe)
+
+	if status := srv.Status(); status != SERVER_UNAVAIL {
+		t.Errorf("Expected status %v without listener, got %v", Status(SERVER_UNAVAIL), status)
+	}
+
+	probe.listening["tcp:db.example.com:3306"] = true
+	if status := srv.Status(); status != SERVER_RUNNING {
+		t.Errorf("Expected status %v with listener, got %v", Status(SERVER_RUNNING), status)
+	}
+}
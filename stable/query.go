@@ -0,0 +1,166 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// QueryResult hold the result set from executing a query on a
+// server. The values in the rows are JSON-friendly: numbers are
+// numbers, NULL is nil, and binary strings that are not valid UTF-8
+// are encoded using base64.
+type QueryResult struct {
+	Server  string          `json:"server"`
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// Rows is the part of a result set from database/sql that is needed
+// to build a query result.
+type Rows interface {
+	Columns() ([]string, error)
+	Next() bool
+	Scan(dest ...interface{}) error
+	Err() error
+}
+
+// numericTypes are the column types that are rendered as numbers.
+var numericTypes = map[string]bool{
+	"TINYINT":   true,
+	"SMALLINT":  true,
+	"MEDIUMINT": true,
+	"INT":       true,
+	"INTEGER":   true,
+	"BIGINT":    true,
+	"DECIMAL":   true,
+	"FLOAT":     true,
+	"DOUBLE":    true,
+	"YEAR":      true,
+}
+
+// jsonValue will convert a value scanned from a column of the given
+// type to a value suitable for encoding as JSON.
+func jsonValue(typeName string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		typeName = strings.TrimPrefix(strings.ToUpper(typeName), "UNSIGNED ")
+		if numericTypes[typeName] {
+			return json.Number(string(v))
+		} else if utf8.Valid(v) {
+			return string(v)
+		} else {
+			return base64.StdEncoding.EncodeToString(v)
+		}
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05")
+	default:
+		return v
+	}
+}
+
+// NewQueryResult will read all rows from the result set and build a
+// query result for the server. The database type names of the
+// columns are used to decide how to represent the values.
+func NewQueryResult(server string, rows Rows, typeNames []string) (*QueryResult, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &QueryResult{
+		Server:  server,
+		Columns: columns,
+		Rows:    [][]interface{}{},
+	}
+
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make([]interface{}, len(columns))
+		for i, value := range values {
+			typeName := ""
+			if i < len(typeNames) {
+				typeName = typeNames[i]
+			}
+			row[i] = jsonValue(typeName, value)
+		}
+		result.Rows = append(result.Rows, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Objects will return the rows of the result as objects mapping the
+// column names to the values.
+func (result *QueryResult) Objects() []map[string]interface{} {
+	objects := make([]map[string]interface{}, len(result.Rows))
+	for i, row := range result.Rows {
+		objects[i] = make(map[string]interface{})
+		for j, column := range result.Columns {
+			objects[i][column] = row[j]
+		}
+	}
+	return objects
+}
+
+// Query will execute a query on the server and return the result
+// set. The query is executed using database/sql, so a MySQL driver
+// have to be registered under the name "mysql" by the program.
+func (srv *Server) Query(query string) (*QueryResult, error) {
+	dsn, err := srv.SocketDsn()
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	typeNames := make([]string, len(types))
+	for i, ct := range types {
+		typeNames[i] = ct.DatabaseTypeName()
+	}
+
+	return NewQueryResult(srv.Name, rows, typeNames)
+}
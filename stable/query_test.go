@@ -0,0 +1,98 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// fakeRows is a result set where the values are scanned the same way
+// as the MySQL driver does for the text protocol: as byte slices, or
+// nil for NULL.
+type fakeRows struct {
+	columns []string
+	rows    [][]interface{}
+	current int
+}
+
+func (rows *fakeRows) Columns() ([]string, error) {
+	return rows.columns, nil
+}
+
+func (rows *fakeRows) Next() bool {
+	rows.current++
+	return rows.current <= len(rows.rows)
+}
+
+func (rows *fakeRows) Scan(dest ...interface{}) error {
+	for i, value := range rows.rows[rows.current-1] {
+		*dest[i].(*interface{}) = value
+	}
+	return nil
+}
+
+func (rows *fakeRows) Err() error {
+	return nil
+}
+
+func TestQueryResult(t *testing.T) {
+	rows := &fakeRows{
+		columns: []string{"id", "price", "name", "data", "note"},
+		rows: [][]interface{}{
+			{[]byte("1"), []byte("9.50"), []byte("first"), []byte{0xff, 0x00}, nil},
+			{[]byte("18446744073709551615"), []byte("-1.25"), []byte("second"), []byte("text"), []byte("x")},
+		},
+	}
+	types := []string{"UNSIGNED BIGINT", "DECIMAL", "VARCHAR", "BLOB", "TEXT"}
+
+	result, err := NewQueryResult("my_server", rows, types)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	text, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("Unable to marshal result: %s", err)
+	}
+	expected := `{"server":"my_server",` +
+		`"columns":["id","price","name","data","note"],` +
+		`"rows":[[1,9.50,"first","/wA=",null],` +
+		`[18446744073709551615,-1.25,"second","text","x"]]}`
+	if string(text) != expected {
+		t.Errorf("Expected %s, got %s", expected, text)
+	}
+
+	text, err = json.Marshal(result.Objects())
+	if err != nil {
+		t.Fatalf("Unable to marshal objects: %s", err)
+	}
+	expected = `[{"data":"/wA=","id":1,"name":"first","note":null,"price":9.50},` +
+		`{"data":"text","id":18446744073709551615,"name":"second","note":"x","price":-1.25}]`
+	if string(text) != expected {
+		t.Errorf("Expected %s, got %s", expected, text)
+	}
+
+	// An empty result set should give an empty list of rows
+	result, err = NewQueryResult("my_server", &fakeRows{columns: []string{"id"}}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if text, _ := json.Marshal(result); string(text) != `{"server":"my_server","columns":["id"],"rows":[]}` {
+		t.Errorf("Unexpected JSON for empty result: %s", text)
+	}
+}
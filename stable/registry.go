@@ -0,0 +1,115 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// REGISTRY_ENV is the environment variable that can be used to give
+// the location of the registry file.
+const REGISTRY_ENV = "GOMYSQL_REGISTRY"
+
+// Registry keep track of the directories containing stables, so that
+// commands can be run against all the stables of a user.
+type Registry struct {
+	// Stables is the sorted list of stable directories.
+	Stables []string
+
+	path string
+}
+
+// DefaultRegistryPath will return the path to the registry file. It
+// is taken from the environment variable GOMYSQL_REGISTRY, if set,
+// and is otherwise the file '.gomysql/registry.json' in the home
+// directory of the user.
+func DefaultRegistryPath() string {
+	if path := os.Getenv(REGISTRY_ENV); len(path) > 0 {
+		return path
+	}
+	return filepath.Join(os.Getenv("HOME"), ".gomysql", "registry.json")
+}
+
+// ReadRegistry will read the registry from the file. If the file does
+// not exist, an empty registry is returned.
+func ReadRegistry(path string) (*Registry, error) {
+	reg := &Registry{Stables: []string{}, path: path}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return reg, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, reg); err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+// Write will write the registry back to the file it was read from.
+func (reg *Registry) Write() error {
+	if err := os.MkdirAll(filepath.Dir(reg.path), 0755); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(reg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(reg.path, content, 0644)
+}
+
+// Add will add the directory to the registry. The directory have to
+// contain a stable.
+func (reg *Registry) Add(dir string) error {
+	dir, err := absPath(dir)
+	if err != nil {
+		return err
+	}
+	dir = filepath.Clean(dir)
+	if _, err := os.Stat(filepath.Join(dir, STABLE_DIR, CONFIG_FILE)); err != nil {
+		return fmt.Errorf("No stable in %q", dir)
+	}
+	for _, root := range reg.Stables {
+		if root == dir {
+			return fmt.Errorf("Stable %q already registered", dir)
+		}
+	}
+	reg.Stables = append(reg.Stables, dir)
+	sort.Strings(reg.Stables)
+	return nil
+}
+
+// Remove will remove the directory from the registry.
+func (reg *Registry) Remove(dir string) error {
+	dir, err := absPath(dir)
+	if err != nil {
+		return err
+	}
+	dir = filepath.Clean(dir)
+	for i, root := range reg.Stables {
+		if root == dir {
+			reg.Stables = append(reg.Stables[:i], reg.Stables[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("Stable %q not registered", dir)
+}
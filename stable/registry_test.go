@@ -0,0 +1,79 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	root, err := ioutil.TempDir("", "registry")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	first, second := filepath.Join(root, "first"), filepath.Join(root, "second")
+	for _, dir := range []string{second, first} {
+		os.Mkdir(dir, 0755)
+		if _, err := CreateStable(dir); err != nil {
+			t.Fatalf("Unable to create stable: %s", err)
+		}
+	}
+
+	path := filepath.Join(root, "config", "registry.json")
+	reg, err := ReadRegistry(path)
+	if err != nil {
+		t.Fatalf("Unable to read missing registry: %s", err)
+	}
+	for _, dir := range []string{second, first} {
+		if err := reg.Add(dir); err != nil {
+			t.Errorf("Unable to add %q: %s", dir, err)
+		}
+	}
+	if err := reg.Add(first); err == nil {
+		t.Errorf("Expected error when adding stable twice")
+	}
+	if err := reg.Add(root); err == nil {
+		t.Errorf("Expected error when adding directory without stable")
+	}
+	if err := reg.Write(); err != nil {
+		t.Fatalf("Unable to write registry: %s", err)
+	}
+
+	reg, err = ReadRegistry(path)
+	if err != nil {
+		t.Fatalf("Unable to read registry: %s", err)
+	}
+	if expect := []string{first, second}; !reflect.DeepEqual(reg.Stables, expect) {
+		t.Errorf("Expected stables %v, got %v", expect, reg.Stables)
+	}
+
+	if err := reg.Remove(first); err != nil {
+		t.Errorf("Unable to remove %q: %s", first, err)
+	}
+	if err := reg.Remove(first); err == nil {
+		t.Errorf("Expected error when removing stable twice")
+	}
+	if expect := []string{second}; !reflect.DeepEqual(reg.Stables, expect) {
+		t.Errorf("Expected stables %v, got %v", expect, reg.Stables)
+	}
+}
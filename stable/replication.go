@@ -0,0 +1,215 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Executor is used to run SQL statements and queries on servers. The
+// default executor connects to the real servers, but a different
+// executor can be provided to check what would be executed.
+type Executor interface {
+	// Execute run the statements on the server.
+	Execute(srv *Server, statements ...string) error
+
+	// Query run the query on the server and return the result.
+	Query(srv *Server, query string) (*QueryResult, error)
+}
+
+type serverExecutor struct{}
+
+func (serverExecutor) Execute(srv *Server, statements ...string) error {
+	return srv.Execute(statements...)
+}
+
+func (serverExecutor) Query(srv *Server, query string) (*QueryResult, error) {
+	return srv.Query(query)
+}
+
+// ServerExecutor is the executor that runs statements on the real
+// servers.
+var ServerExecutor Executor = serverExecutor{}
+
+// masterStatus will return the current binary log file and position
+// of the server.
+func masterStatus(exec Executor, srv *Server) (string, string, error) {
+	result, err := exec.Query(srv, "SHOW MASTER STATUS")
+	if err != nil {
+		return "", "", err
+	}
+	if len(result.Rows) == 0 {
+		return "", "", fmt.Errorf("Binary log not enabled on server %s", srv.Name)
+	}
+
+	var file, pos string
+	for i, column := range result.Columns {
+		switch column {
+		case "File":
+			file = fmt.Sprint(result.Rows[0][i])
+		case "Position":
+			pos = fmt.Sprint(result.Rows[0][i])
+		}
+	}
+	return file, pos, nil
+}
+
+// slaveIORunning will return the state of the I/O thread of the
+// slave, as given by the Slave_IO_Running column of SHOW SLAVE
+// STATUS. If replication is not configured, an empty string is
+// returned.
+func slaveIORunning(exec Executor, srv *Server) (string, error) {
+	result, err := exec.Query(srv, "SHOW SLAVE STATUS")
+	if err != nil {
+		return "", err
+	}
+	if len(result.Rows) == 0 {
+		return "", nil
+	}
+	for i, column := range result.Columns {
+		if column == "Slave_IO_Running" {
+			return fmt.Sprint(result.Rows[0][i]), nil
+		}
+	}
+	return "", nil
+}
+
+// quoteString will quote the string for use as a literal in an SQL
+// statement.
+func quoteString(str string) string {
+	return "'" + strings.Replace(str, "'", "''", -1) + "'"
+}
+
+// changeMasterTo will return a CHANGE MASTER statement that make a
+// slave connect to the master as the user and replicate from the
+// binary log position.
+func changeMasterTo(master *Server, user, password, file, pos string) string {
+	host := master.Host
+	if master.IsLocal() {
+		// Replication always connects using TCP
+		host = "127.0.0.1"
+	}
+	return fmt.Sprintf("CHANGE MASTER TO MASTER_HOST = %s, MASTER_PORT = %d, "+
+		"MASTER_USER = %s, MASTER_PASSWORD = %s, "+
+		"MASTER_LOG_FILE = %s, MASTER_LOG_POS = %s",
+		quoteString(host), master.Port, quoteString(user), quoteString(password),
+		quoteString(file), pos)
+}
+
+// replicationUser will return the statements that create the
+// replication user with the password on the master, unless it already
+// exists, and allow it to replicate. Servers before MySQL 5.7.6 and
+// MariaDB 10.1.3 do not support "CREATE USER IF NOT EXISTS", so the
+// user is created by granting it the privilege instead. Servers with
+// an unknown version are assumed to be recent.
+func replicationUser(master *Server, user, password string) []string {
+	account := quoteString(user) + "@'%'"
+	if dist := master.Dist; dist != nil && len(dist.Version) > 0 {
+		minimum := "5.7.6"
+		if dist.Flavor == FLAVOR_MARIADB {
+			minimum = "10.1.3"
+		}
+		if compareVersions(dist.Version, minimum) < 0 {
+			return []string{
+				"GRANT REPLICATION SLAVE ON *.* TO " + account + " IDENTIFIED BY " + quoteString(password),
+			}
+		}
+	}
+	return []string{
+		"CREATE USER IF NOT EXISTS " + account + " IDENTIFIED BY " + quoteString(password),
+		"GRANT REPLICATION SLAVE ON *.* TO " + account,
+	}
+}
+
+// Replicate will make the slave replicate from the master, starting
+// at the current binary log position of the master. A replication
+// user with the password is created on the master and used by the
+// slave to connect. Both servers have to be running and have
+// different server ids. Once replication is started, the function
+// wait for the I/O thread of the slave to connect to the master and
+// return an error if it has not done so within the timeout.
+func Replicate(exec Executor, master, slave *Server, user, password string, timeout time.Duration) error {
+	for _, srv := range []*Server{master, slave} {
+		if srv.Status() != SERVER_RUNNING {
+			return fmt.Errorf("Server %s not running", srv.Name)
+		}
+	}
+	if master.ServerId == slave.ServerId {
+		return fmt.Errorf("Servers %s and %s have the same server id %d",
+			master.Name, slave.Name, master.ServerId)
+	}
+
+	err := exec.Execute(master, replicationUser(master, user, password)...)
+	if err != nil {
+		return fmt.Errorf("Server %s: %s", master.Name, err)
+	}
+
+	file, pos, err := masterStatus(exec, master)
+	if err != nil {
+		return err
+	}
+
+	change := changeMasterTo(master, user, password, file, pos)
+	if err := exec.Execute(slave, "STOP SLAVE", change, "START SLAVE"); err != nil {
+		return fmt.Errorf("Server %s: %s", slave.Name, err)
+	}
+
+	var state string
+	running := func() bool {
+		state, err = slaveIORunning(exec, slave)
+		return err != nil || state == "Yes"
+	}
+	if !slave.waitFor(timeout, running) {
+		return fmt.Errorf("Server %s: slave I/O thread not running within %v (state %q)",
+			slave.Name, timeout, state)
+	}
+	return err
+}
+
+// Promote will turn the slave into a master by stopping and removing
+// its replication configuration. The other slaves given are then
+// redirected to replicate from the new master, starting at its
+// current binary log position. A replication user with the password
+// is created on the new master and used by the other slaves to
+// connect, as for Replicate.
+func Promote(exec Executor, slave *Server, others []*Server, user, password string) error {
+	if err := exec.Execute(slave, "STOP SLAVE", "RESET SLAVE ALL"); err != nil {
+		return fmt.Errorf("Server %s: %s", slave.Name, err)
+	}
+	if len(others) == 0 {
+		return nil
+	}
+
+	if err := exec.Execute(slave, replicationUser(slave, user, password)...); err != nil {
+		return fmt.Errorf("Server %s: %s", slave.Name, err)
+	}
+
+	file, pos, err := masterStatus(exec, slave)
+	if err != nil {
+		return err
+	}
+
+	change := changeMasterTo(slave, user, password, file, pos)
+	for _, srv := range others {
+		if err := exec.Execute(srv, "STOP SLAVE", change, "START SLAVE"); err != nil {
+			return fmt.Errorf("Server %s: %s", srv.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeExecutor record the statements executed on each server instead
+// of executing them. Queries return the result registered for the
+// server.
+type fakeExecutor struct {
+	executed []string
+	results  map[string]*QueryResult
+}
+
+func (exec *fakeExecutor) Execute(srv *Server, statements ...string) error {
+	for _, stmt := range statements {
+		exec.executed = append(exec.executed, srv.Name+": "+stmt)
+	}
+	return nil
+}
+
+func (exec *fakeExecutor) Query(srv *Server, query string) (*QueryResult, error) {
+	exec.executed = append(exec.executed, srv.Name+": "+query)
+	return exec.results[srv.Name], nil
+}
+
+func TestPromote(t *testing.T) {
+	slave := &Server{Name: "slave.1", Host: "localhost", Port: 12001, User: "root"}
+	others := []*Server{
+		{Name: "slave.2", Host: "localhost", Port: 12002},
+		{Name: "slave.3", Host: "localhost", Port: 12003},
+	}
+
+	exec := &fakeExecutor{
+		results: map[string]*QueryResult{
+			"slave.1": {
+				Server:  "slave.1",
+				Columns: []string{"File", "Position", "Binlog_Do_DB"},
+				Rows:    [][]interface{}{{"slave-bin.000003", json.Number("1234"), ""}},
+			},
+		},
+	}
+
+	if err := Promote(exec, slave, others, "repl", "secret"); err != nil {
+		t.Fatalf("Unable to promote slave: %s", err)
+	}
+
+	change := "CHANGE MASTER TO MASTER_HOST = '127.0.0.1', MASTER_PORT = 12001, " +
+		"MASTER_USER = 'repl', MASTER_PASSWORD = 'secret', " +
+		"MASTER_LOG_FILE = 'slave-bin.000003', MASTER_LOG_POS = 1234"
+	expect := []string{
+		"slave.1: STOP SLAVE",
+		"slave.1: RESET SLAVE ALL",
+		"slave.1: CREATE USER IF NOT EXISTS 'repl'@'%' IDENTIFIED BY 'secret'",
+		"slave.1: GRANT REPLICATION SLAVE ON *.* TO 'repl'@'%'",
+		"slave.1: SHOW MASTER STATUS",
+		"slave.2: STOP SLAVE",
+		"slave.2: " + change,
+		"slave.2: START SLAVE",
+		"slave.3: STOP SLAVE",
+		"slave.3: " + change,
+		"slave.3: START SLAVE",
+	}
+	if !reflect.DeepEqual(exec.executed, expect) {
+		t.Errorf("Expected statements:\n%q\ngot:\n%q", expect, exec.executed)
+	}
+
+	// Without other slaves, only the promoted slave is touched
+	exec.executed = nil
+	if err := Promote(exec, slave, nil, "repl", "secret"); err != nil {
+		t.Fatalf("Unable to promote slave: %s", err)
+	}
+	if expect := []string{"slave.1: STOP SLAVE", "slave.1: RESET SLAVE ALL"}; !reflect.DeepEqual(exec.executed, expect) {
+		t.Errorf("Expected statements %q, got %q", expect, exec.executed)
+	}
+
+	// Redirecting slaves require the binary log to be enabled
+	exec.results["slave.1"].Rows = [][]interface{}{}
+	if err := Promote(exec, slave, others, "repl", "secret"); err == nil {
+		t.Errorf("Expected error when binary log is not enabled")
+	}
+}
+
+func TestReplicationUser(t *testing.T) {
+	tests := []struct {
+		dist   *Dist
+		create bool
+	}{
+		{nil, true},
+		{&Dist{}, true},
+		{&Dist{Version: "5.5.40"}, false},
+		{&Dist{Version: "5.7.5"}, false},
+		{&Dist{Version: "5.7.6"}, true},
+		{&Dist{Version: "8.0.30"}, true},
+		{&Dist{Version: "10.0.38", Flavor: FLAVOR_MARIADB}, false},
+		{&Dist{Version: "10.1.3", Flavor: FLAVOR_MARIADB}, true},
+	}
+	for _, test := range tests {
+		stmts := replicationUser(&Server{Dist: test.dist}, "repl", "secret")
+		if create := strings.HasPrefix(stmts[0], "CREATE USER"); create != test.create {
+			t.Errorf("Expected CREATE USER to be %v for %+v, got %q", test.create, test.dist, stmts)
+		}
+	}
+}
+
+func TestReplicate(t *testing.T) {
+	root, err := ioutil.TempDir("", "replicate")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	probe := newFakeProbe()
+	master := &Server{
+		Name:     "master",
+		Host:     "localhost",
+		Port:     12001,
+		ServerId: 1,
+		PidPath:  filepath.Join(root, "master.pid"),
+		probe:    probe,
+	}
+	slave := &Server{
+		Name:     "slave",
+		Host:     "localhost",
+		Port:     12002,
+		ServerId: 2,
+		PidPath:  filepath.Join(root, "slave.pid"),
+		probe:    probe,
+	}
+	slave.SetPollInterval(time.Millisecond)
+
+	exec := &fakeExecutor{
+		results: map[string]*QueryResult{
+			"master": {
+				Server:  "master",
+				Columns: []string{"File", "Position"},
+				Rows:    [][]interface{}{{"master-bin.000001", json.Number("154")}},
+			},
+			"slave": {
+				Server:  "slave",
+				Columns: []string{"Slave_IO_State", "Slave_IO_Running"},
+				Rows:    [][]interface{}{{"Waiting for master to send event", "Yes"}},
+			},
+		},
+	}
+
+	// Servers that are not running cannot replicate
+	if err := Replicate(exec, master, slave, "repl", "secret", time.Second); err == nil {
+		t.Errorf("Expected error when servers are not running")
+	}
+	if len(exec.executed) > 0 {
+		t.Errorf("Expected no statements, got %q", exec.executed)
+	}
+
+	probe.start(t, master, 4711, time.Hour)
+	probe.start(t, slave, 4712, time.Hour)
+	if err := Replicate(exec, master, slave, "repl", "secret", time.Second); err != nil {
+		t.Fatalf("Unable to set up replication: %s", err)
+	}
+
+	expect := []string{
+		"master: CREATE USER IF NOT EXISTS 'repl'@'%' IDENTIFIED BY 'secret'",
+		"master: GRANT REPLICATION SLAVE ON *.* TO 'repl'@'%'",
+		"master: SHOW MASTER STATUS",
+		"slave: STOP SLAVE",
+		"slave: CHANGE MASTER TO MASTER_HOST = '127.0.0.1', MASTER_PORT = 12001, " +
+			"MASTER_USER = 'repl', MASTER_PASSWORD = 'secret', " +
+			"MASTER_LOG_FILE = 'master-bin.000001', MASTER_LOG_POS = 154",
+		"slave: START SLAVE",
+		"slave: SHOW SLAVE STATUS",
+	}
+	if !reflect.DeepEqual(exec.executed, expect) {
+		t.Errorf("Expected statements:\n%q\ngot:\n%q", expect, exec.executed)
+	}
+
+	// A slave that does not connect is an error
+	exec.results["slave"].Rows[0][1] = "Connecting"
+	if err := Replicate(exec, master, slave, "repl", "secret", 10*time.Millisecond); err == nil {
+		t.Errorf("Expected error when slave does not connect")
+	}
+
+	// Older servers get the user created by the grant
+	master.Dist = &Dist{Version: "5.6.20", Flavor: FLAVOR_MYSQL}
+	exec.executed = nil
+	exec.results["slave"].Rows[0][1] = "Yes"
+	if err := Replicate(exec, master, slave, "repl", "secret", time.Second); err != nil {
+		t.Fatalf("Unable to set up replication: %s", err)
+	}
+	if grant := "master: GRANT REPLICATION SLAVE ON *.* TO 'repl'@'%' IDENTIFIED BY 'secret'"; exec.executed[0] != grant {
+		t.Errorf("Expected %q, got %q", grant, exec.executed[0])
+	}
+
+	// Servers with the same server id cannot replicate
+	slave.ServerId = master.ServerId
+	if err := Replicate(exec, master, slave, "repl", "secret", time.Second); err == nil {
+		t.Errorf("Expected error for same server id")
+	}
+}
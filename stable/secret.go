@@ -0,0 +1,64 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Prefixes for passwords that are not stored literally in the
+// configuration but are read when connecting to the server.
+const (
+	PASSWORD_ENV_PREFIX  = "env:"
+	PASSWORD_FILE_PREFIX = "file:"
+)
+
+// isSecretRef will return true if the password refers to a secret
+// stored elsewhere, false if it is a literal password.
+func isSecretRef(password string) bool {
+	return strings.HasPrefix(password, PASSWORD_ENV_PREFIX) ||
+		strings.HasPrefix(password, PASSWORD_FILE_PREFIX)
+}
+
+// ResolvePassword will return the password to use when connecting to
+// the server. A password of the form "env:NAME" is read from the
+// environment variable NAME and a password of the form "file:PATH"
+// is read from the file PATH, with any trailing newline removed. Any
+// other password is used as it is.
+func (srv *Server) ResolvePassword() (string, error) {
+	switch {
+	case strings.HasPrefix(srv.Password, PASSWORD_ENV_PREFIX):
+		name := strings.TrimPrefix(srv.Password, PASSWORD_ENV_PREFIX)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("Password for server %s: environment variable %s is not set", srv.Name, name)
+		}
+		return value, nil
+
+	case strings.HasPrefix(srv.Password, PASSWORD_FILE_PREFIX):
+		path := strings.TrimPrefix(srv.Password, PASSWORD_FILE_PREFIX)
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("Password for server %s: %s", srv.Name, err)
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	}
+	return srv.Password, nil
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolvePassword(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secret")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "pw")
+	if err := ioutil.WriteFile(path, []byte("from-file\n"), 0600); err != nil {
+		t.Fatalf("Unable to write password file: %s", err)
+	}
+	os.Setenv("GOMYSQL_TEST_PW", "from-env")
+	defer os.Unsetenv("GOMYSQL_TEST_PW")
+	os.Unsetenv("GOMYSQL_TEST_MISSING")
+
+	expected := map[string]string{
+		"":                    "",
+		"xyzzy":               "xyzzy",
+		"env:GOMYSQL_TEST_PW": "from-env",
+		"file:" + path:        "from-file",
+	}
+	for stored, password := range expected {
+		srv := &Server{Name: "one", Password: stored}
+		result, err := srv.ResolvePassword()
+		if err != nil {
+			t.Errorf("Password %q: %s", stored, err)
+		} else if result != password {
+			t.Errorf("Password %q resolved to %q, expected %q", stored, result, password)
+		}
+	}
+
+	missing := map[string]string{
+		"env:GOMYSQL_TEST_MISSING": "GOMYSQL_TEST_MISSING",
+		"file:" + path + ".none":   path + ".none",
+	}
+	for stored, name := range missing {
+		srv := &Server{Name: "one", Password: stored}
+		if _, err := srv.ResolvePassword(); err == nil {
+			t.Errorf("Password %q resolved, expected error", stored)
+		} else if !strings.Contains(err.Error(), name) {
+			t.Errorf("Error %q does not mention %s", err, name)
+		}
+	}
+
+	srv := &Server{Name: "one", User: "root", Password: "env:GOMYSQL_TEST_MISSING"}
+	if _, err := srv.SocketDsn(); err == nil {
+		t.Errorf("DSN built without password")
+	}
+}
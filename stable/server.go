@@ -17,17 +17,23 @@
 package stable
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"mysqld/cnf"
 	"mysqld/log"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
+	"text/template"
+	"time"
 )
 
 // Status is the status of a server. It overloads the String()
@@ -37,17 +43,26 @@ type Status int
 var statusString = []string{
 	"Stopped",
 	"Running",
+	"Crashed",
 }
 
 func (s Status) String() string {
 	return statusString[s]
 }
 
+// A server that has a PID file but no process is crashed. For
+// servers that are not local, the status only tells if the server
+// accept connections.
 const (
 	SERVER_UNAVAIL = iota
 	SERVER_RUNNING
+	SERVER_CRASHED
 )
 
+// DEFAULT_POLL_INTERVAL is the default interval between checks when
+// waiting for a server to change state.
+const DEFAULT_POLL_INTERVAL = 250 * time.Millisecond
+
 // Server structure contain all information about a server.
 type Server struct {
 	Name, Host, Socket        string
@@ -58,6 +73,21 @@ type Server struct {
 	Options                   *cnf.Config
 	User, Password, database  string
 	Dist                      *Dist
+
+	// Adopted is set for servers that were not created by the
+	// stable but rather imported from an existing installation.
+	Adopted bool
+
+	// StartLimits are the resource limits to apply to the server
+	// process when it is started, by name of the limit.
+	StartLimits map[string]uint64
+
+	// Env are environment variables to set when bootstrapping
+	// and starting the server and when running clients for it.
+	Env map[string]string
+
+	probe        Probe
+	pollInterval time.Duration
 }
 
 func (srv *Server) String() string {
@@ -66,7 +96,7 @@ func (srv *Server) String() string {
 
 // bin will return the path to the name of a binary for the server.
 func (srv *Server) bin(name string) string {
-	return filepath.Join(srv.Dist.Root, "bin", name)
+	return srv.Dist.binary(name)
 }
 
 // log will return the path to a name in the log directory for the
@@ -95,6 +125,15 @@ var sqlFiles = []string{
 	"fill_help_tables.sql",
 }
 
+// bootstrapFiles list the paths of the sqlFiles in the distribution.
+var bootstrapFiles = func() []string {
+	paths := make([]string, len(sqlFiles))
+	for i, fname := range sqlFiles {
+		paths[i] = filepath.Join("share", fname)
+	}
+	return paths
+}()
+
 var bsHeader = []string{
 	"SET SESSION SQL_LOG_BIN = 0;",
 	"CREATE DATABASE IF NOT EXISTS mysql;",
@@ -123,8 +162,10 @@ func appendLines(wr io.Writer, lines []string) (int, error) {
 	return count, nil
 }
 
-// createBootstrap will create a bootstrap file for the server.
-func (srv *Server) writeBootstrapFile(bs *os.File) error {
+// createBootstrap will create a bootstrap file for the server. If
+// password is not empty, the password of the root user is set as
+// well.
+func (srv *Server) writeBootstrapFile(bs *os.File, password string) error {
 	log.Debugf("Creating bootstrap file %q\n", bs.Name())
 
 	// Write the header to the bootstrap file
@@ -134,7 +175,7 @@ func (srv *Server) writeBootstrapFile(bs *os.File) error {
 
 	// Append bootstrap files from distribution
 	for _, fname := range sqlFiles {
-		fullname := filepath.Join(srv.Dist.Root, "share", fname)
+		fullname := filepath.Join(srv.Dist.shareDir(), fname)
 		rd, err := os.Open(fullname)
 		if err != nil {
 			return err
@@ -151,13 +192,107 @@ func (srv *Server) writeBootstrapFile(bs *os.File) error {
 		return err
 	}
 
+	if len(password) > 0 {
+		if _, err := fmt.Fprintln(bs, srv.rootPasswordSql(password)); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// rootPasswordSql will return a statement that set the password of
+// the root user when bootstrapping the server. Servers initialized
+// using "--initialize" no longer have a password column in the user
+// table, so the password is changed using ALTER USER for those.
+func (srv *Server) rootPasswordSql(password string) string {
+	if srv.Dist.InitMethod() == INIT_INITIALIZE {
+		return fmt.Sprintf("ALTER USER 'root'@'localhost' IDENTIFIED BY %s;", quoteString(password))
+	}
+	return fmt.Sprintf("UPDATE mysql.user SET Password = PASSWORD(%s) WHERE User = 'root';", quoteString(password))
+}
+
+// bootstrap will initialize the data directory of the server using
+// the method suitable for the distribution. The output of the
+// initialization is written to the bootstrap log of the server.
+//
+// If the server has a password, it is set as the password of the root
+// user. The password is passed to the server in files that are only
+// readable by the owner, and never written to the configuration file.
 func (srv *Server) bootstrap() error {
+	password, err := srv.ResolvePassword()
+	if err != nil {
+		return err
+	}
+	cnfOpt := fmt.Sprintf("--defaults-file=%s", srv.ConfigFile)
+
+	var cmd *exec.Cmd
+	method := srv.Dist.InitMethod()
+	switch method {
+	case INIT_INITIALIZE:
+		args := []string{cnfOpt, "--initialize-insecure"}
+		if len(password) > 0 {
+			initFile := srv.tmp("init.sql")
+			if err := writePrivateFile(initFile, srv.rootPasswordSql(password)+"\n"); err != nil {
+				return err
+			}
+			defer os.Remove(initFile)
+			args = append(args, "--init-file="+initFile)
+		}
+		cmd = srv.command(srv.bin("mysqld"), args...)
+	case INIT_INSTALL_DB:
+		script := filepath.Join(srv.Dist.Root, "scripts", "mysql_install_db")
+		cmd = srv.command(script, cnfOpt,
+			"--basedir="+srv.Dist.Root, "--datadir="+srv.DataDir)
+	default:
+		return srv.bootstrapSql(password)
+	}
+
+	bsLog, err := os.Create(srv.log(BOOTSTRAP_LOG))
+	if err != nil {
+		return err
+	}
+	defer bsLog.Close()
+
+	cmd.Stdout = bsLog
+	cmd.Stderr = bsLog
+	log.Debug("Initializing using ", cmd.Args)
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+
+	// The install script cannot set the password, so it is set
+	// by bootstrapping the server a second time.
+	if method == INIT_INSTALL_DB && len(password) > 0 {
+		cmd = srv.command(srv.bin("mysqld"), cnfOpt, "--bootstrap")
+		cmd.Stdin = strings.NewReader(srv.rootPasswordSql(password) + "\n")
+		cmd.Stdout = bsLog
+		cmd.Stderr = bsLog
+		return cmd.Run()
+	}
+	return nil
+}
+
+// writePrivateFile will write the content to a file that is only
+// readable by the owner.
+func writePrivateFile(path, content string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.WriteString(content)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// bootstrapSql will bootstrap the server by feeding the SQL files for
+// the system tables to "mysqld --bootstrap". The bootstrap file is
+// only readable by the owner since it can contain the root password.
+func (srv *Server) bootstrapSql(password string) error {
 	bsName := srv.tmp("bootstrap.sql")
-	if bs, err := os.Create(bsName); err == nil {
-		err = srv.writeBootstrapFile(bs)
+	if bs, err := os.OpenFile(bsName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600); err == nil {
+		err = srv.writeBootstrapFile(bs, password)
 		bs.Close()
 		if err != nil {
 			return err
@@ -171,11 +306,16 @@ func (srv *Server) bootstrap() error {
 	if err != nil {
 		return err
 	}
+	defer bsSql.Close()
 
 	// Run the bootstrap command
-	bsLog, err := os.Create(srv.log("bootstrap.log"))
+	bsLog, err := os.Create(srv.log(BOOTSTRAP_LOG))
+	if err != nil {
+		return err
+	}
+	defer bsLog.Close()
 	cnfOpt := fmt.Sprintf("--defaults-file=%s", srv.ConfigFile)
-	cmd := exec.Command(srv.bin("mysqld"), cnfOpt, "--bootstrap")
+	cmd := srv.command(srv.bin("mysqld"), cnfOpt, "--bootstrap")
 	cmd.Stdin = bsSql
 	cmd.Stdout = bsLog
 	cmd.Stderr = bsLog
@@ -211,7 +351,10 @@ func (stable *Stable) newServer(name string, dist *Dist) (*Server, error) {
 	baseDir := filepath.Join(stable.serverDir, name)
 	dataDir := filepath.Join(baseDir, "data")
 	cnfFile := filepath.Join(baseDir, "my.cnf")
-	port := stable.fetchPortNumber()
+	port, err := stable.ReserveFreePort()
+	if err != nil {
+		return nil, err
+	}
 	serverId := stable.fetchServerId()
 
 	// Create the server instances
@@ -223,7 +366,6 @@ func (stable *Stable) newServer(name string, dist *Dist) (*Server, error) {
 		Host:       "localhost",
 		Port:       port,
 		ServerId:   serverId,
-		Options:    cnf.New(),
 		Dist:       dist,
 		User:       "root",
 	}
@@ -231,8 +373,10 @@ func (stable *Stable) newServer(name string, dist *Dist) (*Server, error) {
 	// Set up dynamic fields
 	server.fixDynamicFields()
 
-	// Create and fill in default options
-	option := map[string]map[string]string{
+	// Options that depend on the server, which are merged with the
+	// default options of the distribution.
+	options := cnf.New()
+	options.Import(map[string]map[string]string{
 		"mysqladmin": map[string]string{
 			"socket": server.Socket,
 			"user":   "root",
@@ -253,45 +397,47 @@ func (stable *Stable) newServer(name string, dist *Dist) (*Server, error) {
 			"protocol": "tcp",
 			"host":     server.Host,
 			"port":     strconv.Itoa(server.Port),
-			"prompt":   "'" + name + "> '",
+			"prompt":   name + "> ",
 		},
-	}
-
-	if dist.Version >= "5.1.6" {
-		option["mysqld"]["log-output"] = "file"
-	}
-
-	// Set up the language configuration correctly for the version of the server.
-	if dist.Version <= "5.5.0" {
-		option["mysqld"]["language"] = filepath.Join(dist.Root, "share", "english")
-	} else {
-		option["mysqld"]["lc_messages_dir"] = filepath.Join(dist.Root, "share")
-		option["mysqld"]["lc_messages"] = "en_US"
-	}
+	})
 
-	server.Options.Import(option)
+	server.Options = dist.defaultOptions()
+	server.Options.Merge(options)
 
 	return server, nil
 }
 
+// byName sort servers by name.
+type byName []*Server
+
+func (s byName) Len() int           { return len(s) }
+func (s byName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+func (s byName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
 // FindMatchingServers find all servers matching any of the patterns
 // in the slice. The only possible returned error is
 // filepath.ErrBadPattern, which is returned if any of the provided
-// patterns is bad. Otherwise, an array of matcing servers is returned.
+// patterns is bad. Otherwise, an array of matcing servers is returned,
+// sorted by name. A server matching several patterns is only returned
+// once.
 func (stable *Stable) FindMatchingServers(patterns []string) ([]*Server, error) {
 	var servers []*Server
+	seen := make(map[string]bool)
 
 	for _, pattern := range patterns {
 		for name, srv := range stable.Server {
 			matched, err := filepath.Match(pattern, name)
 			if err != nil {
 				return nil, err
-			} else if matched {
+			} else if matched && !seen[name] {
 				servers = append(servers, srv)
+				seen[name] = true
 			}
 		}
 	}
 
+	sort.Sort(byName(servers))
+
 	return servers, nil
 }
 
@@ -314,19 +460,15 @@ func (srv *Server) setup(stable *Stable) error {
 		}
 	}
 
-	cnfFile := filepath.Join(srv.BaseDir, "my.cnf")
-	if fd, err := os.Create(cnfFile); err != nil {
-		return err
-	} else {
-		srv.Options.Write(fd)
-		fd.Close()
-	}
-
-	return nil
+	return srv.Options.WriteFile(filepath.Join(srv.BaseDir, "my.cnf"))
 }
 
 // teardown is executed to tear down the directory structure for the
 // server. If the server is running, an error is returned.
+//
+// For adopted servers, only the directory owned by the stable is
+// removed: the data directory and configuration file are left
+// untouched.
 func (srv *Server) teardown() error {
 	// TODO: Check that the server is not running
 	return os.RemoveAll(srv.BaseDir)
@@ -337,28 +479,150 @@ func (srv *Server) teardown() error {
 // for some reason, nil will be returned and the error that caused the
 // creation to fail.
 func (stable *Stable) AddServer(name string, dist *Dist) (*Server, error) {
+	return stable.AddServerOptions(name, dist, "", nil)
+}
+
+// AddServerOptions will create a new server like AddServer, but apply
+// the option changes to the generated options of the server before
+// the configuration file is written. The changes take precedence over
+// the generated options, so it is possible to, for example, use a
+// different port for the server.
+//
+// If password is not empty, it is set as the password of the root
+// user when bootstrapping the server. The password can refer to a
+// secret as described for ResolvePassword.
+func (stable *Stable) AddServerOptions(name string, dist *Dist, password string, changes []*OptionChange) (*Server, error) {
 	// Create the in-memory server structure
 	server, err := stable.newServer(name, dist)
 	if err != nil {
 		return nil, err
 	}
+	server.Password = password
+	if err := server.overrideOptions(changes); err != nil {
+		stable.releaseServer(server)
+		return nil, err
+	}
 
 	// Create the necessary files and directories
 	if err := server.setup(stable); err != nil {
+		stable.releaseServer(server)
 		return nil, err
 	}
 
-	// Bootstrap the server
+	// Bootstrap the server. If it fails, the bootstrap log is
+	// saved before the server directory is removed.
 	if err := server.bootstrap(); err != nil {
+		if serr := stable.saveBootstrapLog(server); serr == nil {
+			log.Warningf("Bootstrap log for %s saved in %s", name, stable.failedBootstrapLog(name))
+		}
 		os.RemoveAll(server.BaseDir)
+		stable.releaseServer(server)
 		return nil, err
 	}
+	os.Remove(stable.failedBootstrapLog(name))
 
 	stable.Server[name] = server
 
 	return server, nil
 }
 
+// AdoptServer will add an existing server, not created by the stable,
+// under a name. The options of the server are read from the
+// configuration file given by config, and the data directory, socket,
+// and port are taken from the "mysqld" section of it unless provided
+// explicitly (that is, given as non-zero values).
+//
+// The server is not bootstrapped and the data directory is not
+// touched. A directory is created in the stable for the server, but
+// it will only contain files created by the stable.
+func (stable *Stable) AdoptServer(name string, dist *Dist, config, dataDir, socket string, port int) (*Server, error) {
+	if _, exists := stable.Server[name]; exists {
+		return nil, fmt.Errorf("Server %q already exists", name)
+	}
+
+	config, err := absPath(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Existing configuration files can have options before the
+	// first section, so read it leniently.
+	options := cnf.New()
+	options.Lenient = true
+	if err := options.ReadFile(config); err != nil {
+		return nil, err
+	}
+
+	if len(dataDir) == 0 {
+		dataDir, _ = options.Get("mysqld", "datadir")
+	}
+	if len(dataDir) == 0 {
+		return nil, fmt.Errorf("No data directory given for server %q", name)
+	}
+	if len(socket) == 0 {
+		socket, _ = options.Get("mysqld", "socket")
+	}
+	if port == 0 {
+		port = dist.defaultPort
+		if sec, ok := options.Section["mysqld"]; ok {
+			if num, err := sec.GetInt("port"); err == nil {
+				port = num
+			} else if err != cnf.ErrOptionMissing {
+				return nil, err
+			}
+		}
+	}
+
+	server := &Server{
+		Name:       name,
+		BaseDir:    filepath.Join(stable.serverDir, name),
+		DataDir:    dataDir,
+		ConfigFile: config,
+		Host:       "localhost",
+		Port:       port,
+		Socket:     socket,
+		Options:    options,
+		Dist:       dist,
+		User:       "root",
+		Adopted:    true,
+	}
+
+	serverId, _ := options.Get("mysqld", "server_id")
+	if id, err := strconv.Atoi(serverId); err == nil {
+		server.ServerId = id
+	}
+
+	// Use the PID file that mysqld will write given the options,
+	// so that the status of the server is not misreported.
+	server.PidPath = server.configuredPidPath()
+	if logFile, _ := options.Get("mysqld", "log_error"); len(logFile) > 0 {
+		if !filepath.IsAbs(logFile) {
+			logFile = filepath.Join(dataDir, logFile)
+		}
+		server.LogPath = logFile
+	}
+
+	server.fixDynamicFields()
+
+	// Create the directories owned by the stable. The data
+	// directory is already in place.
+	dirs := []string{
+		server.BaseDir,
+		filepath.Join(server.BaseDir, "run"),
+		filepath.Join(server.BaseDir, "log"),
+		filepath.Join(server.BaseDir, "tmp"),
+	}
+	for _, dir := range dirs {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			os.RemoveAll(server.BaseDir)
+			return nil, err
+		}
+	}
+
+	stable.Server[name] = server
+	return server, nil
+}
+
 // DelServerByName will delete the server given by the name. The
 // complete server will be removed by removing all server files and it
 // will not be possible to recover the server after this. If no server
@@ -378,44 +642,179 @@ func (stable *Stable) DelServer(srv *Server) error {
 	}
 
 	delete(stable.Server, srv.Name)
+	stable.releaseServer(srv)
 	return nil
 }
 
-var replRegex = regexp.MustCompile(`\{\w+\}`)
+var replRegex = regexp.MustCompile(`\{[\w.]+\}`)
+
+// errorType is the type of the error interface.
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
 
 // fmtString will produce a formatted string from the server
-// fields. This can probably be generalized to any interface type.
+// fields. Each "{Path}" in the format is replaced by the value of the
+// field or method given by the path, which can be a dotted path
+// such as "{Dist.ServerVersion}". Methods are only called if they do
+// not take any arguments and return a single value that is not an
+// error, such as "{Status}". If the path cannot be resolved, it is
+// replaced by a marker naming the missing field.
 func (srv *Server) FormatString(format string) string {
-	rsrv := reflect.Indirect(reflect.ValueOf(srv))
 	res := replRegex.ReplaceAllFunc([]byte(format), func(m []byte) []byte {
-		name := string(m[1 : len(m)-1])
-		str := fmt.Sprintf("%v", rsrv.FieldByName(name).Interface())
-		return []byte(str)
+		path := string(m[1 : len(m)-1])
+		return []byte(formatValue(reflect.ValueOf(srv), path))
 	})
 	return string(res)
 }
 
-// Status will return the status of the server.
+// templateData is the data of the templates used by FormatTemplate.
+// Templates can call any exported method of the data, so the data is
+// a copy of the fields of the server rather than the server itself,
+// which has methods to, for example, stop the server.
+type templateData struct {
+	Name, Host, Socket        string
+	BaseDir, DataDir          string
+	ConfigFile                string
+	BinPath, LogPath, PidPath string
+	ServerId, Port            int
+	User, Password            string
+	Adopted, IsLocal          bool
+	Status                    Status
+	Dist                      *templateDist
+}
+
+// templateDist is the data of the distribution of the server in
+// templates, see templateData.
+type templateDist struct {
+	Root                         string
+	Name, Version, ServerVersion string
+	Flavor                       string
+}
+
+// templateData will return the data to use for templates of the
+// server.
+func (srv *Server) templateData() *templateData {
+	data := &templateData{
+		Name:       srv.Name,
+		Host:       srv.Host,
+		Socket:     srv.Socket,
+		BaseDir:    srv.BaseDir,
+		DataDir:    srv.DataDir,
+		ConfigFile: srv.ConfigFile,
+		BinPath:    srv.BinPath,
+		LogPath:    srv.LogPath,
+		PidPath:    srv.PidPath,
+		ServerId:   srv.ServerId,
+		Port:       srv.Port,
+		User:       srv.User,
+		Password:   srv.Password,
+		Adopted:    srv.Adopted,
+		IsLocal:    srv.IsLocal(),
+		Status:     srv.Status(),
+	}
+	if dist := srv.Dist; dist != nil {
+		data.Dist = &templateDist{
+			Root:          dist.Root,
+			Name:          dist.Name,
+			Version:       dist.Version,
+			ServerVersion: dist.ServerVersion,
+			Flavor:        dist.Flavor,
+		}
+	}
+	return data
+}
+
+// FormatTemplate will produce a formatted string from the server
+// using the format as a text/template. The data of the template are
+// the fields of the server, its status, and whether it is local,
+// but not the methods of the server. For example,
+// "{{.Host}}:{{.Port}}" gives the host and port of the server.
+func (srv *Server) FormatTemplate(format string) (string, error) {
+	tmpl, err := template.New(srv.Name).Parse(format)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, srv.templateData()); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// formatValue will resolve the dotted path of field and method names
+// starting at the value and return the result formatted as a string.
+// Pointers are followed and a nil pointer is formatted as the empty
+// string.
+func formatValue(value reflect.Value, path string) string {
+	for _, name := range strings.Split(path, ".") {
+		if value.Kind() == reflect.Ptr && value.IsNil() {
+			return ""
+		}
+		if method := value.MethodByName(name); method.IsValid() {
+			mtype := method.Type()
+			if mtype.NumIn() != 0 || mtype.NumOut() != 1 || mtype.Out(0) == errorType {
+				return fmt.Sprintf("<no field %s>", name)
+			}
+			value = method.Call(nil)[0]
+			continue
+		}
+		value = reflect.Indirect(value)
+		if value.Kind() != reflect.Struct {
+			return fmt.Sprintf("<no field %s>", name)
+		}
+		if value = value.FieldByName(name); !value.IsValid() || !value.CanInterface() {
+			return fmt.Sprintf("<no field %s>", name)
+		}
+	}
+	if value.Kind() == reflect.Ptr && value.IsNil() {
+		return ""
+	}
+	return fmt.Sprintf("%v", value.Interface())
+}
+
+// Status will return the status of the server. A server that has a
+// PID file, but where the process is gone, is not running.
 func (srv *Server) Status() Status {
-	if _, err := os.Stat(srv.PidPath); err != nil {
+	if !srv.IsLocal() {
+		address := net.JoinHostPort(srv.Host, strconv.Itoa(srv.Port))
+		if err := srv.prober().Connect("tcp", address); err != nil {
+			return SERVER_UNAVAIL
+		}
+		return SERVER_RUNNING
+	}
+
+	if _, err := srv.prober().Stat(srv.PidPath); err != nil {
 		return SERVER_UNAVAIL
+	} else if !srv.alive() {
+		return SERVER_CRASHED
 	} else {
-		// TODO: add a ping-check to kill the server if it
-		// does not reply properly
 		return SERVER_RUNNING
 	}
 }
 
+// RemoveStalePidFile will remove the PID file of a crashed server, so
+// that it is reported as stopped. It returns true if the file was
+// removed.
+func (srv *Server) RemoveStalePidFile() (bool, error) {
+	if srv.Status() != SERVER_CRASHED {
+		return false, nil
+	}
+	if err := os.Remove(srv.PidPath); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // Pid will get the server PID from the PID file, or return an error
 // if the PID cannot be retrieved for some reason (such as that the
 // file cannot be read, or does not exist).
 func (srv *Server) Pid() (int, error) {
-	if _, err := os.Stat(srv.PidPath); err != nil {
+	if _, err := srv.prober().Stat(srv.PidPath); err != nil {
 		return -1, fmt.Errorf("Server %q not running", srv.Name)
 	}
 	if file, err := os.Open(srv.PidPath); err != nil {
 		return -1, fmt.Errorf("Open %q failed: %s", srv.Name, err)
 	} else {
+		defer file.Close()
 		var pid int
 		if count, err := fmt.Fscanln(file, &pid); count < 1 {
 			return -1, fmt.Errorf("Cannot read PID from file: %s", err)
@@ -424,6 +823,199 @@ func (srv *Server) Pid() (int, error) {
 	}
 }
 
+// alive will return true if the process of the server is alive. It
+// is only possible to check this for local servers.
+func (srv *Server) alive() bool {
+	pid, err := srv.Pid()
+	if err != nil {
+		return false
+	}
+	return srv.prober().Alive(pid)
+}
+
+// Stop will stop the server by sending TERM to it, which is the
+// normal procedure for a graceful shutdown of the server. It only
+// works for local servers and it is not an error if the process is
+// already gone. Note that the function does not wait for the server
+// to stop: use WaitStopped for that.
+func (srv *Server) Stop() error {
+	return srv.signal(syscall.SIGTERM)
+}
+
+// Kill will kill the server by sending KILL to it. This should only be
+// used when the server does not stop by itself.
+func (srv *Server) Kill() error {
+	return srv.signal(syscall.SIGKILL)
+}
+
+// Reload will send HUP to the server, which makes mysqld reload the
+// grant tables and flush the logs, tables, and caches without
+// restarting. This can be used after rotating the log files. It only
+// works for running local servers.
+func (srv *Server) Reload() error {
+	if !srv.IsLocal() {
+		return fmt.Errorf("Non-local server: server is at %s", srv.Host)
+	}
+	if srv.Status() != SERVER_RUNNING {
+		return fmt.Errorf("Server %s not running", srv.Name)
+	}
+	return srv.signal(syscall.SIGHUP)
+}
+
+// Shutdown will stop the server and wait for it to stop. If the server
+// has not stopped within the timeout and force is true, the server is
+// killed and it is waited on again. An error is returned if the server
+// is still alive when done.
+//
+// Servers that are not local are stopped using mysqladmin shutdown
+// and cannot be killed, so force has no effect for them.
+func (srv *Server) Shutdown(timeout time.Duration, force bool) error {
+	if !srv.IsLocal() {
+		if err := srv.adminShutdown(); err != nil {
+			return err
+		}
+		return srv.WaitStopped(timeout)
+	}
+
+	if err := srv.Stop(); err != nil {
+		return err
+	}
+	err := srv.WaitStopped(timeout)
+	if err == nil || !force {
+		return err
+	}
+
+	log.Warningf("Server %s did not stop within %v: killing it", srv.Name, timeout)
+	if err := srv.Kill(); err != nil {
+		return err
+	}
+	return srv.WaitStopped(timeout)
+}
+
+// adminShutdown will ask the server to shut down using mysqladmin.
+func (srv *Server) adminShutdown() error {
+	argv, err := srv.clientArgs("shutdown")
+	if err != nil {
+		return err
+	}
+	cmd := srv.command(srv.bin("mysqladmin"), argv...)
+	log.Debugf("Executing %v", cmd.Args)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("Server %s: %s: %s", srv.Name, err, bytes.TrimSpace(output))
+	}
+	return nil
+}
+
+func (srv *Server) signal(sig syscall.Signal) error {
+	if !srv.IsLocal() {
+		return fmt.Errorf("Non-local server: server is at %s", srv.Host)
+	}
+
+	pid, err := srv.Pid()
+	if err != nil {
+		return fmt.Errorf("Server %s: %s", srv.Name, err)
+	}
+
+	// If the process is already gone, there is nothing to stop.
+	if err := srv.prober().Signal(pid, sig); err != nil && err != syscall.ESRCH {
+		return err
+	}
+	return nil
+}
+
+// Start will start the server as a daemon in the background, with
+// standard output and standard error written to the error log of the
+// server. The extra arguments are passed to mysqld after the options
+// file. If the server is already running, an error is returned.
+func (srv *Server) Start(extraArgs ...string) error {
+	if srv.Status() == SERVER_RUNNING {
+		return fmt.Errorf("Server %q already running", srv.Name)
+	}
+
+	argv := []string{
+		filepath.Base(srv.BinPath),
+		fmt.Sprintf("--defaults-file=%s", srv.ConfigFile),
+	}
+	argv = append(argv, extraArgs...)
+	limits, err := srv.ResourceLimits()
+	if err != nil {
+		return err
+	}
+	err = forkDaemon(srv.BinPath, srv.BaseDir, srv.LogPath, argv, srv.Environ(), limits)
+	if err != nil {
+		return srv.startError(err)
+	}
+	return nil
+}
+
+// Restart will stop the server if it is running, wait for it to stop,
+// and then start it again with the extra arguments. A server that is
+// not running is just started.
+func (srv *Server) Restart(timeout time.Duration, extraArgs ...string) error {
+	if srv.Status() == SERVER_RUNNING {
+		if err := srv.Shutdown(timeout, false); err != nil {
+			return err
+		}
+	}
+	if _, err := srv.RemoveStalePidFile(); err != nil {
+		return err
+	}
+	return srv.Start(extraArgs...)
+}
+
+// START_ERROR_LINES is the number of lines from the end of the error
+// log that is included in the error when a server fails to start.
+const START_ERROR_LINES = 10
+
+// startError will return an error for a server that failed to start
+// with the last lines of the error log of the server added, if there
+// are any.
+func (srv *Server) startError(err error) error {
+	lines, lerr := tailFile(srv.LogPath, START_ERROR_LINES)
+	if lerr != nil || len(lines) == 0 {
+		return fmt.Errorf("Server %s failed to start: %s", srv.Name, err)
+	}
+	return fmt.Errorf("Server %s failed to start: %s\nLast lines of %s:\n%s",
+		srv.Name, err, srv.LogPath, strings.Join(lines, "\n"))
+}
+
+// SetPollInterval will set the interval between checks when waiting
+// for the server. If the interval is zero, DEFAULT_POLL_INTERVAL is
+// used.
+func (srv *Server) SetPollInterval(interval time.Duration) {
+	srv.pollInterval = interval
+}
+
+// waitFor will check the condition repeatedly, using the poll
+// interval of the server, until it is true or the timeout expires.
+// It returns true if the condition was satisfied and false if the
+// wait timed out.
+func (srv *Server) waitFor(timeout time.Duration, cond func() bool) bool {
+	interval := srv.pollInterval
+	if interval <= 0 {
+		interval = DEFAULT_POLL_INTERVAL
+	}
+
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(interval)
+	}
+	return true
+}
+
+// WaitStopped will wait for the server to stop running. If the server
+// is still running after the timeout, an error is returned.
+func (srv *Server) WaitStopped(timeout time.Duration) error {
+	stopped := func() bool { return srv.Status() != SERVER_RUNNING }
+	if !srv.waitFor(timeout, stopped) {
+		return fmt.Errorf("Server %s did not stop within %v", srv.Name, timeout)
+	}
+	return nil
+}
+
 // IsLocal will return true if the server is on the local host, false
 // otherwise.
 func (srv *Server) IsLocal() bool {
@@ -431,17 +1023,43 @@ func (srv *Server) IsLocal() bool {
 	return srv.Host == "localhost" || strings.HasPrefix(srv.Host, "127.0.0")
 }
 
-func (s *Server) SocketDsn() string {
-	return fmt.Sprintf("%v:%v@unix(%v)/%v", s.User, s.Password, s.Socket, s.database)
+// SetDatabase will set the database that is used when connecting to
+// the server, both by the mysql client and in the DSNs of the server.
+func (srv *Server) SetDatabase(database string) {
+	srv.database = database
 }
 
-func (s *Server) TcpDsn() string {
-	return fmt.Sprintf("%v:%v@tcp(%v:%v)/%v", s.User, s.Password, s.Host, s.Port, s.database)
+// Database will return the database that is used when connecting to
+// the server, or the empty string if no database is selected.
+func (srv *Server) Database() string {
+	return srv.database
 }
 
-// mysqlArgs return an array of default arguments for using a mysql
-// client with the server.
-func (srv *Server) mysqlArgs(args ...string) []string {
+func (s *Server) SocketDsn() (string, error) {
+	password, err := s.ResolvePassword()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%v:%v@unix(%v)/%v", s.User, password, s.Socket, s.database), nil
+}
+
+func (s *Server) TcpDsn() (string, error) {
+	password, err := s.ResolvePassword()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%v:%v@tcp(%v:%v)/%v", s.User, password, s.Host, s.Port, s.database), nil
+}
+
+// clientArgs return an array of default arguments for connecting to
+// the server using any of the client programs, such as mysqladmin
+// and mysqldump.
+func (srv *Server) clientArgs(args ...string) ([]string, error) {
+	password, err := srv.ResolvePassword()
+	if err != nil {
+		return nil, err
+	}
+
 	argv := []string{
 		fmt.Sprintf("-S%s", srv.Socket),
 		fmt.Sprintf("-h%s", srv.Host),
@@ -450,20 +1068,64 @@ func (srv *Server) mysqlArgs(args ...string) []string {
 	if len(srv.User) > 0 {
 		argv = append(argv, fmt.Sprintf("-u%s", srv.User))
 	}
-	if len(srv.Password) > 0 {
-		argv = append(argv, fmt.Sprintf("-p%s", srv.Password))
+	if len(password) > 0 {
+		argv = append(argv, fmt.Sprintf("-p%s", password))
 	}
 
-	return append(argv, args...)
+	return append(argv, args...), nil
+}
+
+// mysqlArgs return an array of default arguments for using a mysql
+// client with the server. In addition to the arguments given by
+// clientArgs, the database of the server is selected, if set.
+func (srv *Server) mysqlArgs(args ...string) ([]string, error) {
+	if len(srv.database) > 0 {
+		args = append([]string{fmt.Sprintf("-D%s", srv.database)}, args...)
+	}
+	return srv.clientArgs(args...)
 }
 
 // Execute is used to execute a command using the mysql client for the
 // server and return the result.
 func (srv *Server) Execute(commands ...string) error {
-	argv := srv.mysqlArgs("-e" + strings.Join(commands, ";"))
-	cmd := exec.Command(srv.bin("mysql"), argv...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	return srv.ExecuteTo(os.Stdout, os.Stderr, commands...)
+}
+
+// ExecuteTo is used to execute a command using the mysql client for
+// the server, writing the standard output and standard error of the
+// client to the writers.
+func (srv *Server) ExecuteTo(stdout, stderr io.Writer, commands ...string) error {
+	argv, err := srv.mysqlArgs("-e" + strings.Join(commands, ";"))
+	if err != nil {
+		return err
+	}
+	cmd := srv.command(srv.bin("mysql"), argv...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	log.Debugf("Executing %v", cmd.Args)
+	return cmd.Run()
+}
+
+// ExecuteReader is used to execute the statements read from the
+// reader using the mysql client for the server and return the result.
+func (srv *Server) ExecuteReader(rd io.Reader) error {
+	return srv.ExecuteReaderTo(os.Stdout, os.Stderr, rd)
+}
+
+// ExecuteReaderTo is used to execute the statements read from the
+// reader using the mysql client for the server, writing the standard
+// output and standard error of the client to the writers. The
+// statements are piped to the standard input of the client, so
+// scripts with several statements can be executed.
+func (srv *Server) ExecuteReaderTo(stdout, stderr io.Writer, rd io.Reader) error {
+	argv, err := srv.mysqlArgs()
+	if err != nil {
+		return err
+	}
+	cmd := srv.command(srv.bin("mysql"), argv...)
+	cmd.Stdin = rd
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 	log.Debugf("Executing %v", cmd.Args)
 	return cmd.Run()
 }
@@ -471,11 +1133,14 @@ func (srv *Server) Execute(commands ...string) error {
 // Connect is used to connect a terminal to the server and run a
 // prompt.
 func (srv *Server) Connect(args ...string) error {
-	argv := srv.mysqlArgs(args...)
-	cmd := exec.Command(srv.bin("mysql"), argv...)
+	argv, err := srv.mysqlArgs(args...)
+	if err != nil {
+		return err
+	}
+	cmd := srv.command(srv.bin("mysql"), argv...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	log.Debugf("Executing %v", srv.Name, cmd.Args)
+	log.Debugf("Executing %v", cmd.Args)
 	return cmd.Run()
 }
@@ -17,7 +17,16 @@
 package stable
 
 import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestDSN(t *testing.T) {
@@ -30,8 +39,10 @@ func TestDSN(t *testing.T) {
 		database: "test",
 	}
 	expected = "mats:xyzzy@tcp(localhost:3306)/test"
-	if tcp.TcpDsn() != expected {
-		t.Errorf("DSN was %s, expected %s", tcp.TcpDsn(), expected)
+	if dsn, err := tcp.TcpDsn(); err != nil {
+		t.Errorf("Unable to build DSN: %s", err)
+	} else if dsn != expected {
+		t.Errorf("DSN was %s, expected %s", dsn, expected)
 	}
 
 	unix := &Server{
@@ -41,8 +52,48 @@ func TestDSN(t *testing.T) {
 		database: "test",
 	}
 	expected = "mats:xyzzy@unix(/var/run/mysqld/mysqld.sock)/test"
-	if unix.SocketDsn() != expected {
-		t.Errorf("DSN was %s, expected %s", unix.SocketDsn(), expected)
+	if dsn, err := unix.SocketDsn(); err != nil {
+		t.Errorf("Unable to build DSN: %s", err)
+	} else if dsn != expected {
+		t.Errorf("DSN was %s, expected %s", dsn, expected)
+	}
+}
+
+func TestMysqlArgs(t *testing.T) {
+	srv := &Server{
+		Host:   "localhost",
+		Port:   3306,
+		Socket: "/tmp/mysql.sock",
+		User:   "root",
+	}
+	expected := []string{"-S/tmp/mysql.sock", "-hlocalhost", "-P3306", "-uroot", "-e", "SELECT 1"}
+	if argv, err := srv.mysqlArgs("-e", "SELECT 1"); err != nil {
+		t.Errorf("Unable to build arguments: %s", err)
+	} else if !reflect.DeepEqual(argv, expected) {
+		t.Errorf("Expected arguments %v, got %v", expected, argv)
+	}
+
+	// The database is only given to the mysql client, since
+	// programs like mysqladmin and mysqldump do not accept it.
+	srv.SetDatabase("mysql")
+	expected = []string{"-S/tmp/mysql.sock", "-hlocalhost", "-P3306", "-uroot", "-Dmysql", "-e", "SELECT 1"}
+	if argv, err := srv.mysqlArgs("-e", "SELECT 1"); err != nil {
+		t.Errorf("Unable to build arguments: %s", err)
+	} else if !reflect.DeepEqual(argv, expected) {
+		t.Errorf("Expected arguments %v, got %v", expected, argv)
+	}
+	expected = []string{"-S/tmp/mysql.sock", "-hlocalhost", "-P3306", "-uroot", "shutdown"}
+	if argv, err := srv.clientArgs("shutdown"); err != nil {
+		t.Errorf("Unable to build arguments: %s", err)
+	} else if !reflect.DeepEqual(argv, expected) {
+		t.Errorf("Expected arguments %v, got %v", expected, argv)
+	}
+
+	expectedDsn := "root:@tcp(localhost:3306)/mysql"
+	if dsn, err := srv.TcpDsn(); err != nil {
+		t.Errorf("Unable to build DSN: %s", err)
+	} else if dsn != expectedDsn {
+		t.Errorf("DSN was %s, expected %s", dsn, expectedDsn)
 	}
 }
 
@@ -59,6 +110,62 @@ func TestFormatString(t *testing.T) {
 	if result != expect {
 		t.Errorf("Expected %q, got %q", expect, result)
 	}
+
+	// Nested fields, methods, and unknown fields
+	probe := newFakeProbe()
+	srv.SetProbe(probe)
+	srv.PidPath = "/nonexistent/mysqld.pid"
+	tests := map[string]string{
+		"{Dist.ServerVersion}":      "",
+		"{Status}":                  "Stopped",
+		"{IsLocal} {Name}":          "true ",
+		"{Missing}":                 "<no field Missing>",
+		"{Host.Name}":               "<no field Name>",
+		"{database}":                "<no field database>",
+		"{Stop} {Pid}":              "<no field Stop> <no field Pid>",
+		"{Dist.Name}-{Dist.Flavor}": "-",
+	}
+	for format, expect := range tests {
+		if result := srv.FormatString(format); result != expect {
+			t.Errorf("Format %q: expected %q, got %q", format, expect, result)
+		}
+	}
+
+	srv.Dist = &Dist{Name: "mysql-5.7.10", ServerVersion: "5.7.10-log"}
+	expect = "mysql-5.7.10 runs 5.7.10-log"
+	if result := srv.FormatString("{Dist.Name} runs {Dist.ServerVersion}"); result != expect {
+		t.Errorf("Expected %q, got %q", expect, result)
+	}
+}
+
+func TestFormatTemplate(t *testing.T) {
+	srv := &Server{
+		Name: "my_server",
+		Host: "localhost",
+		Port: 3306,
+	}
+	tests := map[string]string{
+		"{{.Host}}:{{.Port}}":                        "localhost:3306",
+		"{{if .IsLocal}}local{{else}}remote{{end}}":  "local",
+		`{{printf "%06d" .Port}}`:                    "003306",
+		"{{with .Dist}}{{.Name}}{{else}}none{{end}}": "none",
+		"{Name} is {{.Name}}":                        "{Name} is my_server",
+		"{{.Status}}":                                "Stopped",
+	}
+	for format, expect := range tests {
+		if result, err := srv.FormatTemplate(format); err != nil {
+			t.Errorf("Format %q: unexpected error: %s", format, err)
+		} else if result != expect {
+			t.Errorf("Format %q: expected %q, got %q", format, expect, result)
+		}
+	}
+
+	// Methods that change the server cannot be called
+	for _, format := range []string{"{{.Host", "{{.Missing}}", "{{.Stop}}", "{{.Start}}", "{{.Reload}}"} {
+		if _, err := srv.FormatTemplate(format); err == nil {
+			t.Errorf("Format %q: expected error", format)
+		}
+	}
 }
 
 func TestAddServer(t *testing.T) {
@@ -95,3 +202,513 @@ func TestAddServer(t *testing.T) {
 
 	stable.Destroy()
 }
+
+func TestAdoptServer(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	// Create an external installation with a data directory and
+	// a configuration file.
+	extDir := filepath.Join(root, "external")
+	dataDir := filepath.Join(extDir, "data")
+	dataFile := filepath.Join(dataDir, "ibdata1")
+	config := filepath.Join(extDir, "my.cnf")
+	os.MkdirAll(dataDir, 0755)
+	ioutil.WriteFile(dataFile, []byte("data"), 0644)
+	ioutil.WriteFile(config, []byte(`
+[mysqld]
+datadir = `+dataDir+`
+port = 3307
+socket = /tmp/external.sock
+server_id = 17
+`), 0644)
+
+	stable, err := CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+
+	dist := &Dist{Name: "fake", Root: filepath.Join(root, "fake")}
+	srv, err := stable.AdoptServer("external", dist, config, "", "", 0)
+	if err != nil {
+		t.Fatalf("Unable to adopt server: %s", err)
+	}
+
+	if !srv.Adopted {
+		t.Errorf("Server not marked as adopted")
+	}
+	if srv.DataDir != dataDir {
+		t.Errorf("Data directory was %q, expected %q", srv.DataDir, dataDir)
+	}
+	if srv.Port != 3307 || srv.ServerId != 17 {
+		t.Errorf("Port and server id was %d and %d, expected 3307 and 17", srv.Port, srv.ServerId)
+	}
+	if srv.Socket != "/tmp/external.sock" {
+		t.Errorf("Socket was %q, expected %q", srv.Socket, "/tmp/external.sock")
+	}
+	if stable.Server["external"] != srv {
+		t.Errorf("Server not registered in stable")
+	}
+
+	if _, err := stable.AdoptServer("external", dist, config, "", "", 0); err == nil {
+		t.Errorf("Expected error when adopting server twice, got none")
+	}
+
+	// Removing the server should leave the external data
+	// directory alone.
+	if err := stable.DelServer(srv); err != nil {
+		t.Errorf("Unable to remove server: %s", err)
+	}
+	if _, err := os.Stat(dataFile); err != nil {
+		t.Errorf("External data file missing after remove: %s", err)
+	}
+	if _, err := os.Stat(config); err != nil {
+		t.Errorf("External configuration file missing after remove: %s", err)
+	}
+}
+
+func TestWaitStopped(t *testing.T) {
+	root, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	probe := newFakeProbe()
+	srv := &Server{
+		Name:    "my_server",
+		Host:    "localhost",
+		PidPath: filepath.Join(root, "mysqld.pid"),
+		probe:   probe,
+	}
+
+	// No PID file means that the server is stopped
+	if err := srv.WaitStopped(0); err != nil {
+		t.Errorf("Expected no error for stopped server, got %s", err)
+	}
+
+	// A process that exits after a short while
+	probe.start(t, srv, 4711, 300*time.Millisecond)
+	start := time.Now()
+	if err := srv.WaitStopped(5 * time.Second); err != nil {
+		t.Errorf("Expected no error, got %s", err)
+	} else if time.Since(start) < 200*time.Millisecond {
+		t.Errorf("Returned before process stopped")
+	}
+
+	// A process that does not exit before the timeout
+	probe.start(t, srv, 4712, time.Hour)
+	if err := srv.WaitStopped(200 * time.Millisecond); err == nil {
+		t.Errorf("Expected timeout error, got none")
+	}
+
+	// Stopping the process should make the wait succeed
+	if err := srv.Stop(); err != nil {
+		t.Errorf("Unable to stop server: %s", err)
+	}
+	if err := srv.WaitStopped(5 * time.Second); err != nil {
+		t.Errorf("Expected no error after stop, got %s", err)
+	}
+}
+
+func TestPollInterval(t *testing.T) {
+	root, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	probe := newFakeProbe()
+	srv := &Server{
+		Name:    "my_server",
+		Host:    "localhost",
+		PidPath: filepath.Join(root, "mysqld.pid"),
+		probe:   probe,
+	}
+
+	// With a short poll interval, the wait should return shortly
+	// after the process is gone.
+	srv.SetPollInterval(5 * time.Millisecond)
+	probe.start(t, srv, 4711, 50*time.Millisecond)
+	start := time.Now()
+	if err := srv.WaitStopped(5 * time.Second); err != nil {
+		t.Errorf("Expected no error, got %s", err)
+	} else if elapsed := time.Since(start); elapsed >= DEFAULT_POLL_INTERVAL {
+		t.Errorf("Wait took %v, expected less than %v", elapsed, DEFAULT_POLL_INTERVAL)
+	}
+
+	// With the default interval, the first check after the
+	// process is gone happen after a full interval.
+	srv.SetPollInterval(0)
+	probe.start(t, srv, 4712, 50*time.Millisecond)
+	start = time.Now()
+	if err := srv.WaitStopped(5 * time.Second); err != nil {
+		t.Errorf("Expected no error, got %s", err)
+	} else if elapsed := time.Since(start); elapsed < DEFAULT_POLL_INTERVAL {
+		t.Errorf("Wait took %v, expected at least %v", elapsed, DEFAULT_POLL_INTERVAL)
+	}
+}
+
+func TestFindMatchingServers(t *testing.T) {
+	stable := &Stable{Server: map[string]*Server{}}
+	for _, name := range []string{"master", "slave1", "slave2", "backup1"} {
+		stable.Server[name] = &Server{Name: name}
+	}
+
+	// Overlapping patterns give each server once, sorted by name
+	servers, err := stable.FindMatchingServers([]string{"slave*", "*1", "slave1", "backup*"})
+	if err != nil {
+		t.Fatalf("Unable to match servers: %s", err)
+	}
+	names := make([]string, len(servers))
+	for i, srv := range servers {
+		names[i] = srv.Name
+	}
+	if strings.Join(names, " ") != "backup1 slave1 slave2" {
+		t.Errorf("Expected servers backup1, slave1, and slave2, got %v", names)
+	}
+
+	if _, err := stable.FindMatchingServers([]string{"["}); err == nil {
+		t.Errorf("Expected error for bad pattern")
+	}
+}
+
+func TestStartRunning(t *testing.T) {
+	root, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	probe := newFakeProbe()
+	srv := &Server{
+		Name:    "my_server",
+		Host:    "localhost",
+		PidPath: filepath.Join(root, "mysqld.pid"),
+		probe:   probe,
+	}
+
+	// A running server is not started again
+	probe.start(t, srv, 4711, time.Hour)
+	if err := srv.Start(); err == nil {
+		t.Errorf("Expected error when starting running server")
+	}
+}
+
+func TestStartFailure(t *testing.T) {
+	root, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	srv := &Server{
+		Name:       "my_server",
+		Host:       "localhost",
+		BaseDir:    root,
+		BinPath:    filepath.Join(root, "missing", "mysqld"),
+		ConfigFile: filepath.Join(root, "my.cnf"),
+		LogPath:    filepath.Join(root, "mysqld.err"),
+		PidPath:    filepath.Join(root, "mysqld.pid"),
+		probe:      newFakeProbe(),
+	}
+	ioutil.WriteFile(srv.LogPath, []byte("First line\nLast line\n"), 0644)
+
+	// A binary that cannot be executed is reported together with
+	// the end of the error log.
+	err = srv.Start()
+	if err == nil {
+		t.Fatalf("Expected error when starting missing binary")
+	}
+	if !strings.Contains(err.Error(), srv.BinPath) || !strings.Contains(err.Error(), "Last line") {
+		t.Errorf("Expected binary and error log in error, got %q", err)
+	}
+
+	// A binary that can be executed is not an error
+	srv.BinPath = "/bin/true"
+	if _, err := os.Stat(srv.BinPath); err != nil {
+		t.Skipf("No %s to execute", srv.BinPath)
+	}
+	if err := srv.Start(); err != nil {
+		t.Errorf("Unable to start %s: %s", srv.BinPath, err)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	root, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	if _, err := os.Stat("/bin/true"); err != nil {
+		t.Skipf("No /bin/true to execute")
+	}
+
+	probe := newFakeProbe()
+	srv := &Server{
+		Name:       "my_server",
+		Host:       "localhost",
+		BaseDir:    root,
+		BinPath:    "/bin/true",
+		ConfigFile: filepath.Join(root, "my.cnf"),
+		LogPath:    filepath.Join(root, "mysqld.err"),
+		PidPath:    filepath.Join(root, "mysqld.pid"),
+		probe:      probe,
+	}
+	srv.SetPollInterval(time.Millisecond)
+
+	// A running server is stopped before it is started again
+	probe.start(t, srv, 4711, time.Hour)
+	if err := srv.Restart(time.Second); err != nil {
+		t.Errorf("Unable to restart running server: %s", err)
+	}
+	if len(probe.signals) != 1 || probe.signals[0] != syscall.SIGTERM {
+		t.Errorf("Expected TERM to be sent, got %v", probe.signals)
+	}
+	if _, err := os.Stat(srv.PidPath); !os.IsNotExist(err) {
+		t.Errorf("Expected stale PID file to be removed, got %v", err)
+	}
+
+	// A stopped server is just started
+	if err := srv.Restart(time.Second); err != nil {
+		t.Errorf("Unable to restart stopped server: %s", err)
+	}
+	if len(probe.signals) != 1 {
+		t.Errorf("Expected no signal for stopped server, got %v", probe.signals)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	root, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	probe := newFakeProbe()
+	srv := &Server{
+		Name:    "my_server",
+		Host:    "localhost",
+		PidPath: filepath.Join(root, "mysqld.pid"),
+		probe:   probe,
+	}
+	srv.SetPollInterval(time.Millisecond)
+
+	// A server that stops on TERM is not killed
+	probe.start(t, srv, 4711, time.Hour)
+	if err := srv.Shutdown(time.Second, true); err != nil {
+		t.Errorf("Unable to shut down server: %s", err)
+	}
+	if len(probe.signals) != 1 || probe.signals[0] != syscall.SIGTERM {
+		t.Errorf("Expected only TERM to be sent, got %v", probe.signals)
+	}
+
+	// A hung server is an error unless forced
+	probe.ignoreTerm = true
+	probe.signals = nil
+	probe.start(t, srv, 4712, time.Hour)
+	if err := srv.Shutdown(10*time.Millisecond, false); err == nil {
+		t.Errorf("Expected error for hung server")
+	}
+	if err := srv.Shutdown(10*time.Millisecond, true); err != nil {
+		t.Errorf("Unable to force shut down of server: %s", err)
+	}
+	expect := []syscall.Signal{syscall.SIGTERM, syscall.SIGTERM, syscall.SIGKILL}
+	if !reflect.DeepEqual(probe.signals, expect) {
+		t.Errorf("Expected signals %v, got %v", expect, probe.signals)
+	}
+}
+
+func TestReload(t *testing.T) {
+	root, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	probe := newFakeProbe()
+	srv := &Server{
+		Name:    "my_server",
+		Host:    "localhost",
+		PidPath: filepath.Join(root, "mysqld.pid"),
+		probe:   probe,
+	}
+
+	if err := srv.Reload(); err == nil {
+		t.Errorf("Expected error when reloading stopped server")
+	}
+
+	// A crashed server is not running either
+	probe.start(t, srv, 4711, 0)
+	if err := srv.Reload(); err == nil {
+		t.Errorf("Expected error when reloading crashed server")
+	}
+
+	// A running server get HUP and keeps running
+	probe.start(t, srv, 4712, time.Hour)
+	if err := srv.Reload(); err != nil {
+		t.Errorf("Unable to reload server: %s", err)
+	}
+	expect := []syscall.Signal{syscall.SIGHUP}
+	if !reflect.DeepEqual(probe.signals, expect) {
+		t.Errorf("Expected signals %v, got %v", expect, probe.signals)
+	}
+	if srv.Status() != SERVER_RUNNING {
+		t.Errorf("Expected server to still be running after reload")
+	}
+
+	remote := &Server{Name: "remote", Host: "db.example.com", probe: probe}
+	if err := remote.Reload(); err == nil {
+		t.Errorf("Expected error when reloading non-local server")
+	}
+}
+
+func TestRemoteShutdown(t *testing.T) {
+	root, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	// The mysqladmin of the distribution records the arguments it
+	// was called with.
+	argsPath := filepath.Join(root, "args")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %s\n", argsPath)
+	makeDistTree(t, root, map[string]string{"bin/mysqladmin": script})
+
+	probe := newFakeProbe()
+	srv := &Server{
+		Name:   "remote",
+		Host:   "db.example.com",
+		Port:   3306,
+		User:   "root",
+		Socket: "/tmp/mysql.sock",
+		Dist:   &Dist{Root: root},
+		probe:  probe,
+	}
+	srv.SetPollInterval(time.Millisecond)
+
+	if err := srv.Shutdown(time.Second, true); err != nil {
+		t.Fatalf("Unable to shut down remote server: %s", err)
+	}
+	args, err := ioutil.ReadFile(argsPath)
+	if err != nil {
+		t.Fatalf("Expected mysqladmin to be executed: %s", err)
+	}
+	expect := "-S/tmp/mysql.sock -hdb.example.com -P3306 -uroot shutdown\n"
+	if string(args) != expect {
+		t.Errorf("Expected arguments %q, got %q", expect, args)
+	}
+
+	// A server that still accepts connections did not stop
+	probe.listening["tcp:db.example.com:3306"] = true
+	if err := srv.Shutdown(10*time.Millisecond, true); err == nil {
+		t.Errorf("Expected error for server still accepting connections")
+	}
+}
+
+func TestRootPassword(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	stable, err := CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+
+	// The fake servers print the statements they are given in the
+	// bootstrap log, either from the init file or from standard
+	// input.
+	initialize := &Dist{Name: "initialize", Root: filepath.Join(root, "initialize"), Version: "5.7.10"}
+	makeDistTree(t, initialize.Root, map[string]string{
+		"bin/mysqld": "#!/bin/sh\nfor arg; do case $arg in --init-file=*) cat ${arg#--init-file=};; esac; done\n",
+	})
+	installDb := &Dist{Name: "install_db", Root: filepath.Join(root, "install_db"), Version: "5.6.20"}
+	makeDistTree(t, installDb.Root, map[string]string{
+		"bin/mysqld":               "#!/bin/sh\ncat\n",
+		"scripts/mysql_install_db": "#!/bin/sh\necho 'Installed'\n",
+	})
+
+	os.Setenv("TEST_ROOT_PASSWORD", "it's secret")
+	defer os.Unsetenv("TEST_ROOT_PASSWORD")
+
+	tests := []struct {
+		dist     *Dist
+		password string
+		expected string
+	}{
+		{initialize, "", ""},
+		{initialize, "secret", "ALTER USER 'root'@'localhost' IDENTIFIED BY 'secret';\n"},
+		{installDb, "", "Installed\n"},
+		{installDb, "env:TEST_ROOT_PASSWORD",
+			"Installed\nUPDATE mysql.user SET Password = PASSWORD('it''s secret') WHERE User = 'root';\n"},
+	}
+	for i, test := range tests {
+		name := fmt.Sprintf("server%d", i)
+		srv, err := stable.AddServerOptions(name, test.dist, test.password, nil)
+		if err != nil {
+			t.Fatalf("Unable to add server %s: %s", name, err)
+		}
+		if srv.Password != test.password {
+			t.Errorf("Server %s: expected password %q, got %q", name, test.password, srv.Password)
+		}
+
+		content, err := ioutil.ReadFile(srv.log(BOOTSTRAP_LOG))
+		if err != nil {
+			t.Fatalf("Unable to read bootstrap log: %s", err)
+		}
+		if string(content) != test.expected {
+			t.Errorf("Server %s: expected bootstrap log %q, got %q", name, test.expected, content)
+		}
+
+		// The password should not be left in the configuration
+		// file or in the temporary directory.
+		config, _ := ioutil.ReadFile(srv.ConfigFile)
+		if strings.Contains(string(config), "secret") {
+			t.Errorf("Server %s: password found in configuration file", name)
+		}
+		if _, err := os.Stat(srv.tmp("init.sql")); !os.IsNotExist(err) {
+			t.Errorf("Server %s: init file not removed", name)
+		}
+	}
+}
+
+func TestExecuteReader(t *testing.T) {
+	root, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	// The fake mysql client print its arguments followed by the
+	// statements it read from standard input.
+	dist := &Dist{Name: "fake", Root: filepath.Join(root, "fake")}
+	makeDistTree(t, dist.Root, map[string]string{
+		"bin/mysql": "#!/bin/sh\necho \"-- $*\"\ncat\n",
+	})
+	srv := &Server{
+		Name:   "my_server",
+		Host:   "localhost",
+		Port:   12000,
+		Socket: "/tmp/mysql.sock",
+		User:   "root",
+		Dist:   dist,
+	}
+
+	script := "CREATE TABLE t1 (a INT);\nINSERT INTO t1 VALUES (1);\n"
+	var buf bytes.Buffer
+	if err := srv.ExecuteReaderTo(&buf, &buf, strings.NewReader(script)); err != nil {
+		t.Fatalf("Execute failed: %s", err)
+	}
+	expected := "-- -S/tmp/mysql.sock -hlocalhost -P12000 -uroot\n" + script
+	if buf.String() != expected {
+		t.Errorf("Expected output %q, got %q", expected, buf.String())
+	}
+}
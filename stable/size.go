@@ -0,0 +1,109 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// dirSize will compute the total size of all files under the
+// directory. Symbolic links inside the directory are counted as links
+// unless followLinks is set, in which case the size of the file or
+// directory they refer to is counted instead. Each directory is only
+// counted once, even if there are several links to it.
+func dirSize(path string, followLinks bool) (int64, error) {
+	root, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return 0, err
+	}
+	return walkSize(root, followLinks, make(map[string]bool))
+}
+
+func walkSize(dir string, followLinks bool, visited map[string]bool) (int64, error) {
+	if visited[dir] {
+		return 0, nil
+	}
+	visited[dir] = true
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.Mode()&os.ModeSymlink != 0 && followLinks {
+			target, err := filepath.EvalSymlinks(path)
+			if err != nil {
+				// Dangling links are counted as links
+				total += entry.Size()
+				continue
+			}
+			if entry, err = os.Stat(target); err != nil {
+				return 0, err
+			}
+			path = target
+		}
+
+		if entry.IsDir() {
+			size, err := walkSize(path, followLinks, visited)
+			if err != nil {
+				return 0, err
+			}
+			total += size
+		} else {
+			total += entry.Size()
+		}
+	}
+	return total, nil
+}
+
+// InstallSize will return the number of bytes used by the files of
+// the distribution. The size without following symbolic links is
+// cached in the distribution and is invalidated by Verify.
+func (dt *Dist) InstallSize(followLinks bool) (int64, error) {
+	if !followLinks && dt.Size > 0 {
+		return dt.Size, nil
+	}
+	size, err := dirSize(dt.Root, followLinks)
+	if err != nil {
+		return 0, err
+	}
+	if !followLinks {
+		dt.Size = size
+	}
+	return size, nil
+}
+
+// Verify will check that the distribution is still in place and has
+// the files needed to run servers. Binary-only distributions do not
+// have the include files, so only the server binary is required.
+// Since the contents of the distribution may have changed, the cached
+// install size is invalidated.
+func (dt *Dist) Verify() error {
+	dt.Size = 0
+	if _, err := os.Stat(dt.Root); err != nil {
+		return ErrInvalidDist
+	}
+	if _, err := os.Stat(dt.binary("mysqld")); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInstallSize(t *testing.T) {
+	root, err := ioutil.TempDir("", "dist")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	// A distribution with 10 + 20 + 30 bytes of files, and a
+	// directory outside the distribution with 100 bytes.
+	distDir := filepath.Join(root, "dist")
+	makeDistTree(t, distDir, map[string]string{
+		"bin/mysqld":               "0123456789",
+		"share/english/errmsg.sys": "01234567890123456789",
+		"include/mysql_version.h":  "012345678901234567890123456789",
+	})
+	extDir := filepath.Join(root, "external")
+	makeDistTree(t, extDir, map[string]string{
+		"plugin.so": string(make([]byte, 100)),
+	})
+
+	link := filepath.Join(distDir, "lib")
+	if err := os.Symlink(extDir, link); err != nil {
+		t.Fatalf("Unable to create link: %s", err)
+	}
+	finfo, err := os.Lstat(link)
+	if err != nil {
+		t.Fatalf("Unable to stat link: %s", err)
+	}
+
+	// The distribution root is a symbolic link as well, which is
+	// the case for distributions added from a directory.
+	dist := &Dist{Name: "dist", Root: filepath.Join(root, "link")}
+	if err := os.Symlink(distDir, dist.Root); err != nil {
+		t.Fatalf("Unable to create link: %s", err)
+	}
+
+	size, err := dist.InstallSize(false)
+	if err != nil {
+		t.Fatalf("Unable to compute size: %s", err)
+	}
+	if expect := 60 + finfo.Size(); size != expect {
+		t.Errorf("Expected size %d, got %d", expect, size)
+	}
+	if dist.Size != size {
+		t.Errorf("Expected cached size %d, got %d", size, dist.Size)
+	}
+
+	size, err = dist.InstallSize(true)
+	if err != nil {
+		t.Fatalf("Unable to compute size: %s", err)
+	}
+	if size != 160 {
+		t.Errorf("Expected size %d when following links, got %d", 160, size)
+	}
+
+	// The cached size is used until the distribution is verified
+	ioutil.WriteFile(filepath.Join(distDir, "bin", "mysql"), []byte("01234"), 0755)
+	if size, _ := dist.InstallSize(false); size != 60+finfo.Size() {
+		t.Errorf("Expected cached size %d, got %d", 60+finfo.Size(), size)
+	}
+	if err := dist.Verify(); err != nil {
+		t.Errorf("Unable to verify distribution: %s", err)
+	}
+	if size, _ := dist.InstallSize(false); size != 65+finfo.Size() {
+		t.Errorf("Expected size %d after verify, got %d", 65+finfo.Size(), size)
+	}
+}
+
+func TestVerifyBinaryDist(t *testing.T) {
+	root, err := ioutil.TempDir("", "dist")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	// A distribution without include files can be verified
+	dist := &Dist{Root: filepath.Join(root, "binary")}
+	makeDistTree(t, dist.Root, map[string]string{"bin/mysqld": "#!/bin/sh\n"})
+	if err := dist.Verify(); err != nil {
+		t.Errorf("Unable to verify distribution without include files: %s", err)
+	}
+
+	os.Remove(filepath.Join(dist.Root, "bin", "mysqld"))
+	if err := dist.Verify(); err == nil {
+		t.Errorf("Expected error for distribution without mysqld")
+	}
+
+	dist.Root = filepath.Join(root, "missing")
+	if err := dist.Verify(); err != ErrInvalidDist {
+		t.Errorf("Expected ErrInvalidDist for missing distribution, got %v", err)
+	}
+}
@@ -22,10 +22,14 @@ package stable
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"mysqld/cnf"
 	"mysqld/log"
+	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"syscall"
 )
 
@@ -33,9 +37,69 @@ const (
 	// STABLE_DIR is the default name for the stable directory
 	STABLE_DIR  = ".stable"
 	CONFIG_FILE = "config.json"
+
+	// MAX_PORT is the highest port number that can be allocated
+	MAX_PORT = 65535
+
+	// CONFIG_VERSION is the version of the layout of the
+	// configuration file written by this version of the package.
+	CONFIG_VERSION = 2
 )
 
+// migrations hold the functions to upgrade a configuration from older
+// layouts, where the function at index N upgrades a configuration of
+// version N to version N+1. The functions are called after the
+// configuration has been decoded into the stable, so they can fill in
+// new fields or move values from fields that were renamed.
+var migrations = []func(*Stable) error{
+	migrateUnversioned,
+	migrateServerOptions,
+}
+
+// migrateUnversioned will upgrade a configuration written before the
+// version was recorded, where the port and server id counters and the
+// maps could be missing.
+func migrateUnversioned(stable *Stable) error {
+	if stable.Distro == nil {
+		stable.Distro = make(map[string]*Dist)
+	}
+	if stable.Server == nil {
+		stable.Server = make(map[string]*Server)
+	}
+	if stable.NextPort == 0 {
+		stable.NextPort = 12000
+	}
+	if stable.NextServerId == 0 {
+		stable.NextServerId = 1
+	}
+	return nil
+}
+
+// migrateServerOptions will upgrade a configuration written before
+// the options of the servers were encoded, where only the section
+// headers were kept. The options are read back from the options file
+// of each server instead.
+func migrateServerOptions(stable *Stable) error {
+	for _, srv := range stable.Server {
+		if len(srv.ConfigFile) == 0 {
+			continue
+		}
+		options := cnf.New()
+		options.Lenient = srv.Adopted
+		if err := options.ReadFile(srv.ConfigFile); err != nil {
+			log.Warningf("Unable to read options of server %s: %s", srv.Name, err)
+			continue
+		}
+		srv.Options = options
+	}
+	return nil
+}
+
 type Stable struct {
+	// Version is the version of the layout of the configuration
+	// file that the stable was read from.
+	Version int
+
 	// Root is the directory where the stable is positioned.
 	Root string
 
@@ -46,21 +110,116 @@ type Stable struct {
 
 	NextPort, NextServerId int
 
+	// FreePorts and FreeServerIds are port numbers and server
+	// identifiers below NextPort and NextServerId that were
+	// released when servers were removed, and that are allocated
+	// again before new ones.
+	FreePorts, FreeServerIds []int
+
+	// Auditing is set if mutating commands should be recorded in
+	// the audit log of the stable.
+	Auditing bool
+
 	distDir, serverDir, tmpDir string
+	lockFile                   *os.File
 }
 
 // nextPort allocate a new port number for a server
 func (stable *Stable) fetchPortNumber() int {
+	inUse := func(port int) bool {
+		for _, srv := range stable.Server {
+			if srv.Port == port {
+				return true
+			}
+		}
+		return false
+	}
+	if port, ok := takeFree(&stable.FreePorts, inUse); ok {
+		return port
+	}
 	stable.NextPort++
 	return stable.NextPort - 1
 }
 
+// ReserveFreePort allocate a new port number for a server, skipping
+// port numbers where something is already listening. The port is
+// probed by listening on it and closing it immediately, so nothing
+// prevents another process from grabbing it afterwards.
+func (stable *Stable) ReserveFreePort() (int, error) {
+	for {
+		port := stable.fetchPortNumber()
+		if port > MAX_PORT {
+			return 0, fmt.Errorf("No free port number available")
+		}
+		if portAvailable(port) {
+			return port, nil
+		}
+	}
+}
+
+// portAvailable check if it is possible to listen on the port.
+func portAvailable(port int) bool {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return false
+	}
+	listener.Close()
+	return true
+}
+
 // fetchServerId allocate a new server identifier for a server
 func (stable *Stable) fetchServerId() int {
+	inUse := func(id int) bool {
+		for _, srv := range stable.Server {
+			if srv.ServerId == id {
+				return true
+			}
+		}
+		return false
+	}
+	if id, ok := takeFree(&stable.FreeServerIds, inUse); ok {
+		return id
+	}
 	stable.NextServerId++
 	return stable.NextServerId - 1
 }
 
+// takeFree will remove the lowest number from the free list that is
+// not in use and return it. Numbers that are in use are dropped from
+// the free list. If there is no such number, false is returned.
+func takeFree(free *[]int, inUse func(int) bool) (int, bool) {
+	sort.Ints(*free)
+	for len(*free) > 0 {
+		number := (*free)[0]
+		*free = (*free)[1:]
+		if !inUse(number) {
+			return number, true
+		}
+	}
+	return 0, false
+}
+
+// releaseServer will return the port number and server identifier of
+// the server to the free lists, so that they can be allocated to new
+// servers. Only numbers allocated by the stable are released.
+func (stable *Stable) releaseServer(srv *Server) {
+	release := func(free *[]int, number, next int) {
+		if number <= 0 || number >= next {
+			return
+		}
+		for _, n := range *free {
+			if n == number {
+				return
+			}
+		}
+		*free = append(*free, number)
+	}
+	if !srv.Adopted {
+		release(&stable.FreePorts, srv.Port, stable.NextPort)
+		release(&stable.FreeServerIds, srv.ServerId, stable.NextServerId)
+	}
+}
+
 // absPath turn a relative path into an absolute path, but leaves
 // absolute paths untouched. If the path is relative, the current
 // working directory is used as origin for the relative location.
@@ -82,20 +241,55 @@ func (stable *Stable) configFile() string {
 }
 
 // ReadConfig read a configuration file and populate the structure.
+// Configuration files written with an older layout are upgraded, but
+// it is an error to read a configuration file with a newer layout
+// than this version of the package support.
 func (stable *Stable) ReadConfig() error {
 	path := stable.configFile()
-	rd, err := os.Open(path)
+	content, err := ioutil.ReadFile(path)
 	if err != nil {
 		return err
 	}
-	decoder := json.NewDecoder(rd)
-	if err := decoder.Decode(stable); err != nil {
+
+	// Check the version before decoding the rest, so that a
+	// newer configuration is not partially decoded.
+	var header struct{ Version int }
+	if err := json.Unmarshal(content, &header); err != nil {
 		return err
 	}
+	if header.Version > CONFIG_VERSION {
+		return fmt.Errorf("Configuration file %s has version %d, but only version %d or older is supported",
+			path, header.Version, CONFIG_VERSION)
+	}
 
-	// Set the dynamic fields of the server after reading the
-	// configuration file, in case new fields were added.
+	// Decode into fresh maps since decoding only add and replace
+	// entries, so distributions and servers removed by another
+	// process would otherwise survive a re-read.
+	stable.Distro = make(map[string]*Dist)
+	stable.Server = make(map[string]*Server)
+	if err := json.Unmarshal(content, stable); err != nil {
+		return err
+	}
+	stable.Version = header.Version
+	for stable.Version < CONFIG_VERSION {
+		log.Infof("Upgrading configuration file %s from version %d", path, stable.Version)
+		if err := migrations[stable.Version](stable); err != nil {
+			return err
+		}
+		stable.Version++
+	}
+
+	// The distribution of each server is decoded separately from
+	// the distributions of the stable, so link the server to the
+	// distribution of the stable instead. Set the dynamic fields of
+	// the server after reading the configuration file, in case new
+	// fields were added.
 	for _, srv := range stable.Server {
+		if srv.Dist != nil {
+			if dist, ok := stable.Distro[srv.Dist.Name]; ok {
+				srv.Dist = dist
+			}
+		}
 		srv.fixDynamicFields()
 	}
 
@@ -109,6 +303,7 @@ func (stable *Stable) WriteConfig() error {
 	if err != nil {
 		return err
 	}
+	stable.Version = CONFIG_VERSION
 	encoder := json.NewEncoder(wr)
 	err = encoder.Encode(stable)
 	wr.Close()
@@ -18,8 +18,12 @@ package stable
 
 import (
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -112,3 +116,177 @@ func TestConfig(t *testing.T) {
 		t.Errorf("Stables not equal")
 	}
 }
+
+func TestConfigVersion(t *testing.T) {
+	root, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	stable, err := CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+	content, err := ioutil.ReadFile(stable.configFile())
+	if err != nil {
+		t.Fatalf("Unable to read configuration: %s", err)
+	}
+	if !strings.Contains(string(content), fmt.Sprintf(`"Version":%d`, CONFIG_VERSION)) {
+		t.Errorf("Expected version %d in configuration: %s", CONFIG_VERSION, content)
+	}
+
+	// A configuration without a version is upgraded when read
+	old := `{"Root":"` + stable.Root + `","Distro":{},"Server":{}}`
+	if err := ioutil.WriteFile(stable.configFile(), []byte(old), 0644); err != nil {
+		t.Fatalf("Unable to write configuration: %s", err)
+	}
+	if err := stable.ReadConfig(); err != nil {
+		t.Fatalf("Unable to read configuration: %s", err)
+	}
+	if stable.Version != CONFIG_VERSION {
+		t.Errorf("Expected version %d, got %d", CONFIG_VERSION, stable.Version)
+	}
+	if stable.NextPort != 12000 || stable.NextServerId != 1 {
+		t.Errorf("Expected counters to be set, got port %d and server id %d",
+			stable.NextPort, stable.NextServerId)
+	}
+
+	// The options of servers in a version 1 configuration are read
+	// from the options file of the server.
+	config := filepath.Join(root, "my.cnf")
+	ioutil.WriteFile(config, []byte("[mysqld]\nport = 12345\n"), 0644)
+	v1 := `{"Version":1,"Root":"` + stable.Root + `","Distro":{},` +
+		`"Server":{"srv":{"Name":"srv","Dist":{"Name":"fake","Root":"` + root + `"},"ConfigFile":"` + config + `","Options":{"Header":[],"Section":{"mysqld":{"Header":[]}}}}}}`
+	if err := ioutil.WriteFile(stable.configFile(), []byte(v1), 0644); err != nil {
+		t.Fatalf("Unable to write configuration: %s", err)
+	}
+	if err := stable.ReadConfig(); err != nil {
+		t.Fatalf("Unable to read configuration: %s", err)
+	}
+	if port, _ := stable.Server["srv"].Options.Get("mysqld", "port"); port != "12345" {
+		t.Errorf("Expected port %q from options file, got %q", "12345", port)
+	}
+
+	// A configuration from a newer version is not read
+	stable.NextPort = 13000
+	newer := fmt.Sprintf(`{"Version":%d,"Root":"%s","NextPort":14000}`, CONFIG_VERSION+1, stable.Root)
+	if err := ioutil.WriteFile(stable.configFile(), []byte(newer), 0644); err != nil {
+		t.Fatalf("Unable to write configuration: %s", err)
+	}
+	if err := stable.ReadConfig(); err == nil {
+		t.Errorf("Expected error when reading newer configuration")
+	}
+	if stable.NextPort != 13000 {
+		t.Errorf("Newer configuration was partially decoded")
+	}
+}
+
+func TestReuseNumbers(t *testing.T) {
+	stable := &Stable{
+		Server:       make(map[string]*Server),
+		NextPort:     12000,
+		NextServerId: 1,
+	}
+
+	add := func(name string) *Server {
+		srv := &Server{
+			Name:     name,
+			Port:     stable.fetchPortNumber(),
+			ServerId: stable.fetchServerId(),
+		}
+		stable.Server[name] = srv
+		return srv
+	}
+	del := func(srv *Server) {
+		delete(stable.Server, srv.Name)
+		stable.releaseServer(srv)
+	}
+
+	one, two, three := add("one"), add("two"), add("three")
+	del(three)
+	del(one)
+	del(two)
+
+	// Released numbers should be handed out lowest first and
+	// duplicates should be ignored.
+	stable.releaseServer(two)
+	if srv := add("four"); srv.Port != 12000 || srv.ServerId != 1 {
+		t.Errorf("Expected port 12000 and id 1, got port %d and id %d",
+			srv.Port, srv.ServerId)
+	}
+
+	// Numbers that are taken by other servers, for example
+	// adopted ones, should be skipped.
+	stable.Server["adopted"] = &Server{Name: "adopted", Port: 12001, ServerId: 2, Adopted: true}
+	if srv := add("five"); srv.Port != 12002 || srv.ServerId != 3 {
+		t.Errorf("Expected port 12002 and id 3, got port %d and id %d",
+			srv.Port, srv.ServerId)
+	}
+
+	// Releasing an adopted server should not add its numbers.
+	del(stable.Server["adopted"])
+	if len(stable.FreePorts) != 0 || len(stable.FreeServerIds) != 0 {
+		t.Errorf("Expected empty free lists, got %v and %v",
+			stable.FreePorts, stable.FreeServerIds)
+	}
+
+	// The free lists should survive a round trip through the
+	// configuration file.
+	del(stable.Server["four"])
+	data, err := json.Marshal(stable)
+	if err != nil {
+		t.Fatalf("Marshal failed: %s", err)
+	}
+	var loaded Stable
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("Unmarshal failed: %s", err)
+	}
+	if !stablesEqual(t, stable, &loaded) {
+		t.Errorf("Stables not equal")
+	}
+	if srv := add("six"); srv.Port != 12000 || srv.ServerId != 1 {
+		t.Errorf("Expected port 12000 and id 1, got port %d and id %d",
+			srv.Port, srv.ServerId)
+	}
+	if stable.NextPort != 12003 || stable.NextServerId != 4 {
+		t.Errorf("Expected next port 12003 and id 4, got %d and %d",
+			stable.NextPort, stable.NextServerId)
+	}
+}
+
+func TestReserveFreePort(t *testing.T) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %s", err)
+	}
+	defer listener.Close()
+	busy := listener.Addr().(*net.TCPAddr).Port
+
+	// A busy port on the free list should be skipped as well as a
+	// busy next port.
+	stable := &Stable{
+		Server:    make(map[string]*Server),
+		NextPort:  busy,
+		FreePorts: []int{busy},
+	}
+	port, err := stable.ReserveFreePort()
+	if err != nil {
+		t.Fatalf("ReserveFreePort: %s", err)
+	}
+	if port == busy {
+		t.Errorf("Port %d is in use but was allocated", busy)
+	}
+	if port <= busy || stable.NextPort != port+1 {
+		t.Errorf("Expected port after %d, got %d (next port %d)",
+			busy, port, stable.NextPort)
+	}
+	if len(stable.FreePorts) != 0 {
+		t.Errorf("Expected empty free list, got %v", stable.FreePorts)
+	}
+
+	stable.NextPort = MAX_PORT + 1
+	if _, err := stable.ReserveFreePort(); err == nil {
+		t.Errorf("Expected error when ports are exhausted")
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"fmt"
+	"time"
+)
+
+// StartAtomic will start the servers using the start function and
+// wait for all of them to become ready. If any server fails to start
+// or is not ready within the timeout, the servers that were started
+// are stopped again, so that either all servers are started or none
+// of them. The servers that were stopped again are returned together
+// with the error.
+func StartAtomic(servers []*Server, start func(*Server) error, timeout time.Duration) ([]*Server, error) {
+	started := []*Server{}
+	var failure error
+	for _, srv := range servers {
+		if err := start(srv); err != nil {
+			failure = fmt.Errorf("Server %s: %s", srv.Name, err)
+			break
+		}
+		started = append(started, srv)
+	}
+
+	if failure == nil {
+		for _, srv := range started {
+			if err := srv.WaitReady(timeout); err != nil {
+				failure = err
+				break
+			}
+		}
+	}
+
+	if failure == nil {
+		return nil, nil
+	}
+
+	// Roll back by stopping all servers that were started,
+	// including any that did not become ready. A server that was
+	// just started might not have written the PID to the PID file
+	// yet, so wait for it before stopping the server.
+	errs := MultiError{failure}
+	stopping := []*Server{}
+	for _, srv := range started {
+		if err := srv.waitPid(timeout); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := srv.Stop(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		stopping = append(stopping, srv)
+	}
+	stopped := []*Server{}
+	for _, srv := range stopping {
+		if err := srv.WaitStopped(timeout); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		stopped = append(stopped, srv)
+	}
+	return stopped, errs
+}
+
+// waitPid will wait for the PID file of a newly started server to
+// appear and contain the PID of the server.
+func (srv *Server) waitPid(timeout time.Duration) error {
+	if err := srv.WaitPidFile(timeout); err != nil {
+		return err
+	}
+	var err error
+	srv.waitFor(timeout, func() bool {
+		_, err = srv.Pid()
+		return err == nil
+	})
+	return err
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStartAtomic(t *testing.T) {
+	root, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	probe := newFakeProbe()
+	servers := []*Server{}
+	for _, name := range []string{"one", "two", "three"} {
+		srv := &Server{
+			Name:    name,
+			Host:    "localhost",
+			PidPath: filepath.Join(root, name+".pid"),
+			Socket:  filepath.Join(root, name+".sock"),
+		}
+		srv.SetProbe(probe)
+		srv.SetPollInterval(time.Millisecond)
+		servers = append(servers, srv)
+	}
+
+	// Start a server by simulating a process and creating the
+	// socket, unless the server should fail.
+	pid := 1000
+	startWith := func(fail string) func(*Server) error {
+		return func(srv *Server) error {
+			if srv.Name == fail {
+				return errors.New("Unable to start")
+			}
+			pid++
+			probe.start(t, srv, pid, time.Hour)
+			probe.listen("unix", srv.Socket, true)
+			return nil
+		}
+	}
+	reset := func() {
+		for _, srv := range servers {
+			os.Remove(srv.PidPath)
+			probe.listen("unix", srv.Socket, false)
+		}
+	}
+
+	// All servers start
+	if stopped, err := StartAtomic(servers, startWith(""), time.Second); err != nil || len(stopped) > 0 {
+		t.Errorf("Expected all servers to start, got error %v and stopped %v", err, stopped)
+	}
+	if err := RequireState(servers, STATE_READY, 3); err != nil {
+		t.Errorf("Expected all servers ready: %s", err)
+	}
+	for _, srv := range servers {
+		srv.Stop()
+	}
+	reset()
+
+	// The last server fails, so the other two should be stopped
+	stopped, err := StartAtomic(servers, startWith("three"), time.Second)
+	if err == nil {
+		t.Fatalf("Expected error when a server fails to start")
+	}
+	if len(stopped) != 2 || stopped[0] != servers[0] || stopped[1] != servers[1] {
+		t.Errorf("Expected servers one and two to be stopped, got %v", stopped)
+	}
+	if err := RequireState(servers, STATE_STOPPED, 3); err != nil {
+		t.Errorf("Expected all servers stopped: %s", err)
+	}
+	reset()
+
+	// A server that starts but does not become ready is stopped
+	// as well.
+	startNotReady := func(srv *Server) error {
+		if err := startWith("")(srv); err != nil {
+			return err
+		}
+		if srv.Name == "two" {
+			probe.listen("unix", srv.Socket, false)
+		}
+		return nil
+	}
+	stopped, err = StartAtomic(servers, startNotReady, 20*time.Millisecond)
+	if err == nil {
+		t.Fatalf("Expected error when a server is not ready")
+	}
+	if len(stopped) != 3 {
+		t.Errorf("Expected all servers to be stopped, got %v", stopped)
+	}
+	if err := RequireState(servers, STATE_STOPPED, 3); err != nil {
+		t.Errorf("Expected all servers stopped: %s", err)
+	}
+	reset()
+
+	// A server that has not written the PID file when another
+	// server fails is still stopped once the PID file appears.
+	startSlow := func(srv *Server) error {
+		if srv.Name == "three" {
+			return errors.New("Unable to start")
+		}
+		if srv.Name == "two" {
+			pid++
+			go func(pid int) {
+				time.Sleep(20 * time.Millisecond)
+				probe.start(t, srv, pid, time.Hour)
+			}(pid)
+			return nil
+		}
+		return startWith("")(srv)
+	}
+	stopped, err = StartAtomic(servers, startSlow, time.Second)
+	if err == nil {
+		t.Fatalf("Expected error when a server fails to start")
+	}
+	if len(stopped) != 2 {
+		t.Errorf("Expected servers one and two to be stopped, got %v", stopped)
+	}
+	if err := RequireState(servers, STATE_STOPPED, 3); err != nil {
+		t.Errorf("Expected all servers stopped: %s", err)
+	}
+	reset()
+
+	// A server that could not be stopped is not reported as
+	// stopped.
+	probe.ignoreTerm = true
+	stopped, err = StartAtomic(servers, startWith("three"), 20*time.Millisecond)
+	probe.ignoreTerm = false
+	if err == nil {
+		t.Fatalf("Expected error when a server fails to start")
+	}
+	if len(stopped) != 0 {
+		t.Errorf("Expected no servers to be stopped, got %v", stopped)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// States that can be required of servers.
+const (
+	STATE_RUNNING = "running"
+	STATE_READY   = "ready"
+	STATE_STOPPED = "stopped"
+)
+
+// Ready will return true if the server is running and accepts
+// connections. Local servers are connected to using the socket, while
+// the status of remote servers is already checked by connecting to
+// them over TCP.
+func (srv *Server) Ready() bool {
+	if srv.Status() != SERVER_RUNNING {
+		return false
+	}
+	if !srv.IsLocal() {
+		return true
+	}
+	return srv.prober().Connect("unix", srv.Socket) == nil
+}
+
+// WaitReady will wait for the server to accept connections. If it is
+// not ready after the timeout, an error is returned.
+func (srv *Server) WaitReady(timeout time.Duration) error {
+	if !srv.waitFor(timeout, srv.Ready) {
+		return fmt.Errorf("Server %s not ready within %v", srv.Name, timeout)
+	}
+	return nil
+}
+
+// InState will return true if the server is in the state, which is
+// one of STATE_RUNNING, STATE_READY, or STATE_STOPPED.
+func (srv *Server) InState(state string) (bool, error) {
+	switch state {
+	case STATE_RUNNING:
+		return srv.Status() == SERVER_RUNNING, nil
+	case STATE_READY:
+		return srv.Ready(), nil
+	case STATE_STOPPED:
+		return srv.Status() != SERVER_RUNNING, nil
+	default:
+		return false, fmt.Errorf("Unknown server state %q", state)
+	}
+}
+
+// RequireState will check that there are at least count servers and
+// that all of them are in the state. If not, an error is returned
+// listing the servers that are not in the state.
+func RequireState(servers []*Server, state string, count int) error {
+	failed := []string{}
+	for _, srv := range servers {
+		ok, err := srv.InState(state)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			failed = append(failed, srv.Name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("Servers not %s: %s", state, strings.Join(failed, ", "))
+	}
+	if len(servers) < count {
+		return fmt.Errorf("Expected at least %d servers %s, found %d", count, state, len(servers))
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRequireState(t *testing.T) {
+	root, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	probe := newFakeProbe()
+	servers := []*Server{}
+	for i, name := range []string{"one", "two", "three"} {
+		srv := &Server{
+			Name:    name,
+			Host:    "localhost",
+			PidPath: filepath.Join(root, name+".pid"),
+			Socket:  filepath.Join(root, name+".sock"),
+		}
+		srv.SetProbe(probe)
+		probe.start(t, srv, 1000+i, time.Hour)
+		probe.listen("unix", srv.Socket, true)
+		servers = append(servers, srv)
+	}
+
+	// All servers healthy
+	for _, state := range []string{STATE_RUNNING, STATE_READY} {
+		if err := RequireState(servers, state, 3); err != nil {
+			t.Errorf("Expected all servers %s, got error: %s", state, err)
+		}
+	}
+	if err := RequireState(servers, STATE_RUNNING, 4); err == nil {
+		t.Errorf("Expected error when requiring more servers than matched")
+	}
+	if err := RequireState(servers, "sleeping", 0); err == nil {
+		t.Errorf("Expected error for unknown state")
+	}
+
+	// One server not accepting connections
+	probe.listen("unix", servers[1].Socket, false)
+	if err := RequireState(servers, STATE_RUNNING, 0); err != nil {
+		t.Errorf("Expected all servers running, got error: %s", err)
+	}
+	if err := RequireState(servers, STATE_READY, 0); err == nil {
+		t.Errorf("Expected error when server is not ready")
+	}
+
+	// One server down
+	if err := servers[2].Stop(); err != nil {
+		t.Fatalf("Unable to stop server: %s", err)
+	}
+	err = RequireState(servers, STATE_RUNNING, 0)
+	if err == nil || err.Error() != "Servers not running: three" {
+		t.Errorf("Expected error for server three, got %v", err)
+	}
+	if err := RequireState(servers[2:], STATE_STOPPED, 1); err != nil {
+		t.Errorf("Expected server stopped, got error: %s", err)
+	}
+}
+
+func TestWaitReady(t *testing.T) {
+	root, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	probe := newFakeProbe()
+	srv := &Server{
+		Name:    "my_server",
+		Host:    "localhost",
+		PidPath: filepath.Join(root, "mysqld.pid"),
+		Socket:  filepath.Join(root, "mysqld.sock"),
+	}
+	srv.SetProbe(probe)
+	srv.SetPollInterval(time.Millisecond)
+
+	// A running server that does not accept connections is not
+	// ready.
+	probe.start(t, srv, 4711, time.Hour)
+	if err := srv.WaitReady(20 * time.Millisecond); err == nil {
+		t.Errorf("Expected error when server does not accept connections")
+	}
+
+	// The server should be ready once it accepts connections.
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		probe.listen("unix", srv.Socket, true)
+	}()
+	if err := srv.WaitReady(time.Second); err != nil {
+		t.Errorf("Expected server to be ready: %s", err)
+	}
+
+	// Remote servers are ready when they accept TCP connections.
+	remote := &Server{Name: "remote", Host: "db.example.com", Port: 3306}
+	remote.SetProbe(probe)
+	remote.SetPollInterval(time.Millisecond)
+	if err := remote.WaitReady(20 * time.Millisecond); err == nil {
+		t.Errorf("Expected error when remote server does not accept connections")
+	}
+	probe.listen("tcp", "db.example.com:3306", true)
+	if err := remote.WaitReady(time.Second); err != nil {
+		t.Errorf("Expected remote server to be ready: %s", err)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// SYSTEM_DIST is the name of the distribution for the MySQL
+// installation found on the machine.
+const SYSTEM_DIST = "system"
+
+// systemBinDirs are the directories searched for an installed mysqld,
+// before the directories in PATH.
+var systemBinDirs = []string{
+	"/usr/bin",
+	"/usr/local/mysql/bin",
+	"/usr/sbin",
+}
+
+// isExecutable will return true if the path is an executable file.
+func isExecutable(path string) bool {
+	finfo, err := os.Stat(path)
+	return err == nil && finfo.Mode().IsRegular() && finfo.Mode()&0111 != 0
+}
+
+// findShareDir will return the directory with the SQL files and
+// error messages of an installation with the prefix. Packaged
+// installations usually keep them in "share/mysql" while relocatable
+// distributions keep them directly in "share".
+func findShareDir(prefix string) string {
+	candidates := []string{
+		filepath.Join(prefix, "share", "mysql"),
+		filepath.Join(prefix, "share"),
+	}
+	for _, dir := range candidates {
+		for _, name := range append([]string{"english"}, sqlFiles...) {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				return dir
+			}
+		}
+	}
+	return filepath.Join(prefix, "share")
+}
+
+// findSystemDist will search the directories for a mysqld binary and
+// return a distribution for the first installation found, or nil if
+// there is none. The root of the distribution is the installation
+// prefix, that is, the parent of the directory where mysqld is. If
+// mysqld is not in the "bin" directory of the prefix, as for packaged
+// installations where it is in "sbin", the clients are taken from
+// the "bin" directory.
+func (stable *Stable) findSystemDist(dirs []string) *Dist {
+	for _, dir := range dirs {
+		if !isExecutable(filepath.Join(dir, "mysqld")) {
+			continue
+		}
+		dt, _ := stable.newDist()
+		dt.Name = SYSTEM_DIST
+		dt.Root = filepath.Dir(dir)
+		dt.BinDir = dir
+		if binDir := filepath.Join(dt.Root, "bin"); binDir != dir {
+			if _, err := os.Stat(binDir); err == nil {
+				dt.BinDir = binDir
+				dt.SbinDir = dir
+			}
+		}
+		dt.ShareDir = findShareDir(dt.Root)
+		return dt
+	}
+	return nil
+}
+
+// AddSystemDist will look for an existing MySQL installation on the
+// machine and add it as a distribution named "system". The known
+// installation directories are searched first, then the directories
+// in PATH. If no installation is found, ErrDistNotFound is returned.
+func (stable *Stable) AddSystemDist() (*Dist, error) {
+	dirs := append([]string{}, systemBinDirs...)
+	dirs = append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+	dt := stable.findSystemDist(dirs)
+	if dt == nil {
+		return nil, ErrDistNotFound
+	}
+	if err := dt.readVersion(); err != nil {
+		return nil, err
+	}
+	stable.Distro[dt.Name] = dt
+	return dt, nil
+}
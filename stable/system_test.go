@@ -0,0 +1,78 @@
+// Copyright (c) 2014, Oracle and/or its affiliates. All rights reserved.
+
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; version 2 of the License.
+
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program; if not, write to the Free Software
+// Foundation, Inc., 51 Franklin St, Fifth Floor, Boston, MA 02110-1301
+// USA
+
+package stable
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindSystemDist(t *testing.T) {
+	root, err := ioutil.TempDir("", "system")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	stable, err := CreateStable(root)
+	if err != nil {
+		t.Fatalf("Unable to create stable: %s", err)
+	}
+
+	// A packaged installation, with the server in sbin, the
+	// clients in bin, and the SQL files in share/mysql.
+	prefix := filepath.Join(root, "usr")
+	mysqld := "#!/bin/sh\necho 'mysqld  Ver 5.6.20 for linux-glibc2.5 on x86_64'\n"
+	makeDistTree(t, prefix, map[string]string{
+		"bin/mysql":                           "#!/bin/sh\n",
+		"share/mysql/mysql_system_tables.sql": "-- SQL",
+	})
+	os.MkdirAll(filepath.Join(prefix, "sbin"), 0755)
+	ioutil.WriteFile(filepath.Join(prefix, "sbin", "mysqld"), []byte(mysqld), 0755)
+
+	empty := filepath.Join(root, "empty")
+	os.Mkdir(empty, 0755)
+	if dt := stable.findSystemDist([]string{empty}); dt != nil {
+		t.Errorf("Expected no distribution in %s, found %+v", empty, dt)
+	}
+
+	dt := stable.findSystemDist([]string{empty, filepath.Join(prefix, "sbin")})
+	if dt == nil {
+		t.Fatalf("Expected to find distribution in %s", prefix)
+	}
+	if dt.Name != SYSTEM_DIST || dt.Root != prefix {
+		t.Errorf("Expected distribution %q in %s, got %q in %s", SYSTEM_DIST, prefix, dt.Name, dt.Root)
+	}
+	if path := dt.binary("mysqld"); path != filepath.Join(prefix, "sbin", "mysqld") {
+		t.Errorf("Expected mysqld in sbin, got %s", path)
+	}
+	if path := dt.binary("mysql"); path != filepath.Join(prefix, "bin", "mysql") {
+		t.Errorf("Expected mysql in bin, got %s", path)
+	}
+	if dir := dt.shareDir(); dir != filepath.Join(prefix, "share", "mysql") {
+		t.Errorf("Expected share directory %s, got %s", filepath.Join(prefix, "share", "mysql"), dir)
+	}
+
+	if err := dt.readVersion(); err != nil {
+		t.Fatalf("Unable to read version: %s", err)
+	}
+	if dt.ServerVersion != "5.6.20" || dt.Version != "5.6.20" {
+		t.Errorf("Expected version 5.6.20, got %q and %q", dt.ServerVersion, dt.Version)
+	}
+}